@@ -1,41 +1,156 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/migrate"
 )
 
+const usage = `Usage: go run cmd/migrate/main.go <command> [arguments]
+
+Commands:
+  up               Apply all pending migrations
+  down             Roll back the last migration
+  to <version>     Migrate up or down to version (0 rolls back everything)
+  status           Show which migrations are applied
+  create <name>    Create <dir>/<timestamp>_<name>.sql
+
+Flags:
+  -dir <path>      Directory create writes to (default: migrations/ at the
+                   root of the backend module)
+
+The database is read from DATABASE_URL.`
+
 func main() {
-	if len(os.Args) < 2 {
-		log.Fatal("Usage: go run cmd/migrate/main.go [up|down]")
+	dir := flag.String("dir", "", "directory create writes to")
+	flag.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, config.Load(), *dir, flag.Args(), os.Stdout); err != nil {
+		log.Printf("❌ %v", err)
+		os.Exit(1)
+	}
+}
+
+// run executes one migrate command. create writes to dir, or to the
+// migrations directory of the module when dir is empty.
+func run(ctx context.Context, cfg *config.Config, dir string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
 	}
 
-	cfg := config.Load()
-	direction := os.Args[1]
+	command, args := args[0], args[1:]
+	switch command {
+	case "create":
+		if len(args) != 1 {
+			return errors.New("usage: create <name>")
+		}
+		if dir == "" {
+			var err error
+			if dir, err = migrate.SourceDir("."); err != nil {
+				return err
+			}
+		}
+		path, err := migrate.Create(dir, args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "✅ Created %s\n", path)
+		return nil
+	case "up", "down", "status":
+		if len(args) != 0 {
+			return fmt.Errorf("%s takes no arguments", command)
+		}
+	case "to":
+		if len(args) != 1 {
+			return errors.New("usage: to <version>")
+		}
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", command, usage)
+	}
 
-	switch direction {
+	db, err := sql.Open("pgx", cfg.DatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return err
+	}
+
+	switch command {
 	case "up":
-		runMigrationsUp(cfg)
+		results, err := migrator.Up(ctx)
+		printResults(out, results)
+		if err != nil {
+			return fmt.Errorf("migrations failed: %w", err)
+		}
+		fmt.Fprintf(out, "✅ Applied %d migrations\n", len(results))
 	case "down":
-		runMigrationsDown(cfg)
-	default:
-		log.Fatal("Invalid direction. Use 'up' or 'down'")
+		result, err := migrator.Down(ctx)
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			return errors.New("no migration to roll back")
+		}
+		if err != nil {
+			return fmt.Errorf("rollback failed: %w", err)
+		}
+		printResults(out, []*goose.MigrationResult{result})
+	case "to":
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		results, err := migrator.To(ctx, version)
+		printResults(out, results)
+		if err != nil {
+			return fmt.Errorf("migrating to version %d failed: %w", version, err)
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get migration status: %w", err)
+		}
+		printStatus(out, statuses)
 	}
+	return nil
 }
 
-func runMigrationsUp(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations UP against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations completed successfully")
+// printResults prints one line per migration that ran
+func printResults(out io.Writer, results []*goose.MigrationResult) {
+	for _, result := range results {
+		if result != nil {
+			fmt.Fprintln(out, result)
+		}
+	}
 }
 
-func runMigrationsDown(cfg *config.Config) {
-	fmt.Printf("🔄 Running migrations DOWN against %s\n", cfg.DatabaseURL)
-	// TODO: Implement actual migration logic
-	// This would typically use a migration library like golang-migrate
-	fmt.Println("✅ Migrations rollback completed successfully")
+// printStatus prints when each migration was applied, or that it is pending
+func printStatus(out io.Writer, statuses []*goose.MigrationStatus) {
+	fmt.Fprintf(out, "%-25s %s\n", "Applied At", "Migration")
+	for _, status := range statuses {
+		appliedAt := "Pending"
+		if status.State == goose.StateApplied {
+			appliedAt = status.AppliedAt.UTC().Format(time.DateTime)
+		}
+		fmt.Fprintf(out, "%-25s %s\n", appliedAt, status.Source.Path)
+	}
 }
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
+)
+
+func TestRunBadArguments(t *testing.T) {
+	// Nothing listens on port 1: arguments must be rejected before connecting
+	cfg := &config.Config{DatabaseURL: "postgres://localhost:1/unused?connect_timeout=1"}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "Usage:"},
+		{[]string{"sideways"}, `unknown command "sideways"`},
+		{[]string{"up", "now"}, "up takes no arguments"},
+		{[]string{"down", "1"}, "down takes no arguments"},
+		{[]string{"status", "-v"}, "status takes no arguments"},
+		{[]string{"to"}, "usage: to <version>"},
+		{[]string{"to", "1", "2"}, "usage: to <version>"},
+		{[]string{"to", "latest"}, `invalid version "latest"`},
+		{[]string{"to", "-1"}, `invalid version "-1"`},
+		{[]string{"create"}, "usage: create <name>"},
+		{[]string{"create", "Add User"}, "must be snake_case"},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		err := run(context.Background(), cfg, "", tt.args, &out)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("run(%q) error = %v, want it to contain %q", tt.args, err, tt.want)
+		}
+		if out.Len() != 0 {
+			t.Errorf("run(%q) printed %q, want nothing", tt.args, out.String())
+		}
+	}
+}
+
+func TestRunCreate(t *testing.T) {
+	dir := t.TempDir()
+	var out bytes.Buffer
+	if err := run(context.Background(), &config.Config{}, dir, []string{"create", "add_user_bio"}, &out); err != nil {
+		t.Fatalf("run(create) error = %v", err)
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "*_add_user_bio.sql"))
+	if len(matches) != 1 || !strings.Contains(out.String(), matches[0]) {
+		t.Errorf("run(create) created %v and printed %q, want one migration in %s", matches, out.String(), dir)
+	}
+}
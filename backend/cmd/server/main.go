@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"log"
 	"net/http"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/config"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/handlers"
 	"github.com/timur-harin/sum25-go-flutter-course/backend/internal/middleware"
@@ -24,6 +26,13 @@ func main() {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// Open the database lazily; /health reports it while it is unreachable
+	db, err := sql.Open("pgx", cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
 	router := gin.New()
 
 	// Add middleware
@@ -32,7 +41,7 @@ func main() {
 	router.Use(middleware.CORS())
 
 	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
+	router.GET("/health", handlers.Health(handlers.NewSQLChecker(db)))
 
 	// API routes
 	api := router.Group("/api/v1")
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthTimeout bounds the database check of a health request
+const healthTimeout = 2 * time.Second
+
+// DBChecker reports on a database connection. The lab04 database package's
+// MonitoredDB implements it, as does the value returned by NewSQLChecker.
+type DBChecker interface {
+	HealthCheck(ctx context.Context) error
+	Stats() sql.DBStats
+}
+
+// sqlChecker checks a plain *sql.DB
+type sqlChecker struct {
+	*sql.DB
+}
+
+// NewSQLChecker returns a DBChecker that pings db and runs SELECT 1
+func NewSQLChecker(db *sql.DB) DBChecker {
+	return sqlChecker{db}
+}
+
+func (c sqlChecker) HealthCheck(ctx context.Context) error {
+	if err := c.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	var one int
+	if err := c.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+	return nil
+}
+
+// Health returns a handler like HealthCheck that also checks the database
+// and reports its connection pool. It responds 503 when the database check
+// fails, so load balancers and container health checks take the instance
+// out of rotation. The cause of a failure is logged, not returned, since
+// it may name hosts, users or files.
+func Health(db DBChecker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), healthTimeout)
+		defer cancel()
+
+		status, code := "healthy", http.StatusOK
+		database := gin.H{"status": "up"}
+		start := time.Now()
+		if err := db.HealthCheck(ctx); err != nil {
+			status, code = "unhealthy", http.StatusServiceUnavailable
+			database["status"] = "down"
+			log.Printf("Health check failed: %v", err)
+		}
+		database["latency_ms"] = time.Since(start).Milliseconds()
+
+		stats := db.Stats()
+		database["pool"] = gin.H{
+			"max_open":             stats.MaxOpenConnections,
+			"open":                 stats.OpenConnections,
+			"in_use":               stats.InUse,
+			"idle":                 stats.Idle,
+			"wait_count":           stats.WaitCount,
+			"wait_duration_ms":     stats.WaitDuration.Milliseconds(),
+			"max_idle_closed":      stats.MaxIdleClosed,
+			"max_idle_time_closed": stats.MaxIdleTimeClosed,
+			"max_lifetime_closed":  stats.MaxLifetimeClosed,
+		}
+
+		c.JSON(code, gin.H{
+			"status":   status,
+			"service":  "sum25-go-flutter-course-backend",
+			"version":  "1.0.0",
+			"database": database,
+		})
+	}
+}
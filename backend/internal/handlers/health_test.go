@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeDB struct {
+	err   error
+	stats sql.DBStats
+}
+
+func (f fakeDB) HealthCheck(ctx context.Context) error { return f.err }
+func (f fakeDB) Stats() sql.DBStats                    { return f.stats }
+
+func TestHealth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		db         fakeDB
+		wantCode   int
+		wantStatus string
+		wantDB     string
+	}{
+		{"healthy", fakeDB{stats: sql.DBStats{MaxOpenConnections: 10, OpenConnections: 3, InUse: 1, Idle: 2}}, http.StatusOK, "healthy", "up"},
+		{"database down", fakeDB{err: errors.New("dial tcp db.internal:5432: connection refused")}, http.StatusServiceUnavailable, "unhealthy", "down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			log.SetOutput(&logs)
+			defer log.SetOutput(os.Stderr)
+
+			router := gin.New()
+			router.GET("/health", Health(tt.db))
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if w.Code != tt.wantCode {
+				t.Errorf("Expected status code %d, got %d", tt.wantCode, w.Code)
+			}
+			var body struct {
+				Status   string `json:"status"`
+				Database struct {
+					Status string `json:"status"`
+					Pool   struct {
+						MaxOpen int `json:"max_open"`
+						Open    int `json:"open"`
+						InUse   int `json:"in_use"`
+						Idle    int `json:"idle"`
+					} `json:"pool"`
+				} `json:"database"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if body.Status != tt.wantStatus || body.Database.Status != tt.wantDB {
+				t.Errorf("Expected status %q and database %q, got %q and %q", tt.wantStatus, tt.wantDB, body.Status, body.Database.Status)
+			}
+			// The cause is logged, never shown to clients
+			if strings.Contains(w.Body.String(), "db.internal") {
+				t.Errorf("Response leaks the database error: %s", w.Body.String())
+			}
+			if tt.db.err != nil && !strings.Contains(logs.String(), tt.db.err.Error()) {
+				t.Errorf("Expected the database error to be logged, got %q", logs.String())
+			}
+			if pool := body.Database.Pool; pool.MaxOpen != tt.db.stats.MaxOpenConnections || pool.Open != tt.db.stats.OpenConnections ||
+				pool.InUse != tt.db.stats.InUse || pool.Idle != tt.db.stats.Idle {
+				t.Errorf("Expected pool %+v, got %+v", tt.db.stats, pool)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"github.com/timur-harin/sum25-go-flutter-course/backend/migrations"
+)
+
+// Migrator applies the embedded migrations to a PostgreSQL database.
+// Every run holds a session-level advisory lock, so two deploys starting
+// at the same time migrate one after the other.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// New creates a Migrator for db
+func New(db *sql.DB) (*Migrator, error) {
+	locker, err := lock.NewPostgresSessionLocker()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migration lock: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.FS,
+		goose.WithSessionLocker(locker))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Sources returns the known migrations, oldest first
+func (m *Migrator) Sources() []*goose.Source {
+	return m.provider.ListSources()
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recently applied migration
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// To migrates up or down so that version is the newest applied migration.
+// Version 0 rolls back every migration.
+func (m *Migrator) To(ctx context.Context, version int64) ([]*goose.MigrationResult, error) {
+	current, err := m.provider.GetDBVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version >= current {
+		return m.provider.UpTo(ctx, version)
+	}
+	return m.provider.DownTo(ctx, version)
+}
+
+// Status returns every migration with whether and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// migrationName matches names accepted by Create
+var migrationName = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// migrationTemplate is the content of a new SQL migration
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+`
+
+// Create writes an empty SQL migration named <timestamp>_<name>.sql to dir
+// and returns its path. name must be snake_case, e.g. add_user_bio.
+func Create(dir, name string, now time.Time) (string, error) {
+	if !migrationName.MatchString(name) {
+		return "", fmt.Errorf("migration name %q must be snake_case", name)
+	}
+
+	path := filepath.Join(dir, now.UTC().Format("20060102150405")+"_"+name+".sql")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("failed to create migration: %w", err)
+	}
+	if _, err := f.WriteString(migrationTemplate); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write migration: %w", err)
+	}
+	return path, f.Close()
+}
+
+// modulePath is the module whose migrations/ directory SourceDir finds
+const modulePath = "github.com/timur-harin/sum25-go-flutter-course/backend"
+
+// SourceDir returns the migrations directory of the backend module, found
+// by walking up from dir to the module's go.mod, so that migrations are
+// created in the same place from any directory of the module
+func SourceDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path, err := readModulePath(filepath.Join(dir, "go.mod"))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		if path == modulePath {
+			return filepath.Join(dir, "migrations"), nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go.mod of %s above the working directory; pass -dir", modulePath)
+		}
+		dir = parent
+	}
+}
+
+// readModulePath returns the module path declared by the go.mod at path
+func readModulePath(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if module, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "module "); ok {
+			return strings.Trim(strings.TrimSpace(module), `"`), nil
+		}
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,232 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+)
+
+func TestNew(t *testing.T) {
+	// sql.Open does not connect, so loading migrations needs no server
+	db, err := sql.Open("pgx", "postgres://localhost:1/unused")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	migrator, err := New(db)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if len(migrator.Sources()) == 0 {
+		t.Error("Expected embedded migrations, got none")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 7, 14, 9, 30, 0, 0, time.UTC)
+
+	path, err := Create(dir, "add_user_bio", now)
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if filepath.Base(path) != "20250714093000_add_user_bio.sql" {
+		t.Errorf("Expected 20250714093000_add_user_bio.sql, got %s", filepath.Base(path))
+	}
+	content, err := os.ReadFile(path)
+	if err != nil || !strings.Contains(string(content), "-- +goose Down") {
+		t.Errorf("Expected a goose template, got %q (%v)", content, err)
+	}
+
+	if _, err := Create(dir, "add_user_bio", now); err == nil {
+		t.Error("Expected an error when the migration already exists")
+	}
+	for _, name := range []string{"", "Add User", "add-user", "../escape"} {
+		if _, err := Create(dir, name, now); err == nil {
+			t.Errorf("Expected an error for name %q", name)
+		}
+	}
+}
+
+func TestSourceDir(t *testing.T) {
+	// The tests run in internal/migrate, two levels below the module root
+	got, err := SourceDir(".")
+	if err != nil {
+		t.Fatalf("SourceDir() error = %v", err)
+	}
+	want, _ := filepath.Abs(filepath.Join("..", "..", "migrations"))
+	if got != want {
+		t.Errorf("SourceDir() = %s, want %s", got, want)
+	}
+
+	// A nested module of another path is skipped
+	root := t.TempDir()
+	nested := filepath.Join(root, "tools", "gen")
+	if err := os.MkdirAll(nested, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(root, "go.mod"), []byte("module "+modulePath+"\n\ngo 1.24\n"), 0o644)
+	os.WriteFile(filepath.Join(root, "tools", "go.mod"), []byte("module example.com/tools\n"), 0o644)
+	if got, err := SourceDir(nested); err != nil || got != filepath.Join(root, "migrations") {
+		t.Errorf("SourceDir(%s) = %s, %v; want %s", nested, got, err, filepath.Join(root, "migrations"))
+	}
+
+	if _, err := SourceDir(t.TempDir()); err == nil {
+		t.Error("SourceDir() outside the module should fail")
+	}
+}
+
+// openTestDB connects to the PostgreSQL server of DATABASE_URL, skipping the
+// test without one. Each call gets its own schema, dropped when the test
+// ends, and returns a function opening further connections to it.
+func openTestDB(t *testing.T) func() *sql.DB {
+	t.Helper()
+	databaseURL := os.Getenv("DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("DATABASE_URL is not set")
+	}
+
+	admin, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("migrate_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		t.Fatalf("Invalid DATABASE_URL: %v", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	return func() *sql.DB {
+		db, err := sql.Open("pgx", u.String())
+		if err != nil {
+			t.Fatalf("sql.Open() error = %v", err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return db
+	}
+}
+
+func TestTo(t *testing.T) {
+	migrator, err := New(openTestDB(t)())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ctx := context.Background()
+	sources := migrator.Sources()
+	latest := sources[len(sources)-1].Version
+
+	applied := func() int {
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		n := 0
+		for _, status := range statuses {
+			if status.State == goose.StateApplied {
+				n++
+			}
+		}
+		return n
+	}
+
+	results, err := migrator.To(ctx, latest)
+	if err != nil || len(results) != len(sources) {
+		t.Fatalf("To(%d) = %d results, %v; want %d", latest, len(results), err, len(sources))
+	}
+	if got := applied(); got != len(sources) {
+		t.Errorf("After To(%d), %d migrations applied, want %d", latest, got, len(sources))
+	}
+
+	results, err = migrator.To(ctx, 0)
+	if err != nil || len(results) != len(sources) {
+		t.Fatalf("To(0) = %d results, %v; want %d", len(results), err, len(sources))
+	}
+	for _, result := range results {
+		if result.Direction != "down" {
+			t.Errorf("To(0) ran %s %s, want down", result.Source.Path, result.Direction)
+		}
+	}
+	if got := applied(); got != 0 {
+		t.Errorf("After To(0), %d migrations applied, want none", got)
+	}
+
+	if _, err := migrator.To(ctx, latest); err != nil {
+		t.Fatalf("To(%d) after rolling back error = %v", latest, err)
+	}
+	if got := applied(); got != len(sources) {
+		t.Errorf("After To(%d) again, %d migrations applied, want %d", latest, got, len(sources))
+	}
+}
+
+// TestUpConcurrent starts two migrators at once, as two deploys would. The
+// advisory lock makes one wait for the other, so each migration is applied
+// exactly once and neither fails.
+func TestUpConcurrent(t *testing.T) {
+	open := openTestDB(t)
+	ctx := context.Background()
+
+	var migrators []*Migrator
+	for i := 0; i < 2; i++ {
+		migrator, err := New(open())
+		if err != nil {
+			t.Fatalf("New() error = %v", err)
+		}
+		migrators = append(migrators, migrator)
+	}
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	counts := make([]int, len(migrators))
+	errs := make([]error, len(migrators))
+	for i, migrator := range migrators {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			results, err := migrator.Up(ctx)
+			counts[i], errs[i] = len(results), err
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("Migrator %d Up() error = %v", i, err)
+		}
+	}
+	sources := migrators[0].Sources()
+	if total := counts[0] + counts[1]; total != len(sources) {
+		t.Errorf("Up() applied %d + %d migrations, want %d in total", counts[0], counts[1], len(sources))
+	}
+
+	db := open()
+	for _, source := range sources {
+		var n int
+		err := db.QueryRow("SELECT COUNT(*) FROM goose_db_version WHERE version_id = $1 AND is_applied", source.Version).Scan(&n)
+		if err != nil || n != 1 {
+			t.Errorf("Migration %d recorded %d times (%v), want once", source.Version, n, err)
+		}
+	}
+}
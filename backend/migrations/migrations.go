@@ -0,0 +1,10 @@
+// Package migrations embeds the goose SQL migrations of the backend, so the
+// migrate binary does not depend on its working directory
+package migrations
+
+import "embed"
+
+// FS holds the SQL migrations
+//
+//go:embed *.sql
+var FS embed.FS
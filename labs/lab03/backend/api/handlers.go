@@ -1,153 +1,533 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lab03-backend/auth"
+	"lab03-backend/models"
+	"lab03-backend/moderation"
+	"lab03-backend/statuscatalog"
 	"lab03-backend/storage"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
-// Handler holds the storage instance
+// Handler holds the storage instance, the issued bearer tokens and the
+// moderation chain applied to new and edited messages
 type Handler struct {
-	// TODO: Add storage field of type *storage.MemoryStorage
+	storage   *storage.MemoryStorage
+	tokens    *auth.TokenStore
+	moderator *moderation.Chain
 }
 
 // NewHandler creates a new handler instance
-func NewHandler(storage *storage.MemoryStorage) *Handler {
-	// TODO: Return a new Handler instance with provided storage
-	return nil
+func NewHandler(storage *storage.MemoryStorage, tokens *auth.TokenStore, moderator *moderation.Chain) *Handler {
+	return &Handler{storage: storage, tokens: tokens, moderator: moderator}
 }
 
+var errForbidden = errors.New("only the author or an admin can modify this message")
+
 // SetupRoutes configures all API routes
 func (h *Handler) SetupRoutes() *mux.Router {
-	// TODO: Create a new mux router
-	// TODO: Add CORS middleware
-	// TODO: Create API v1 subrouter with prefix "/api"
-	// TODO: Add the following routes:
-	// GET /messages -> h.GetMessages
-	// POST /messages -> h.CreateMessage
-	// PUT /messages/{id} -> h.UpdateMessage
-	// DELETE /messages/{id} -> h.DeleteMessage
-	// GET /status/{code} -> h.GetHTTPStatus
-	// GET /health -> h.HealthCheck
-	// TODO: Return the router
-	return nil
+	router := mux.NewRouter()
+	router.Use(corsMiddleware)
+
+	// Reads are public, writes need a bearer token and moderation needs an admin
+	api := router.PathPrefix("/api").Subrouter()
+	api.HandleFunc("/auth/register", h.Register).Methods("POST")
+	api.HandleFunc("/messages", h.GetMessages).Methods("GET")
+	api.HandleFunc("/messages", h.authenticate(h.CreateMessage)).Methods("POST")
+	api.HandleFunc("/messages/deleted", h.requireAdmin(h.GetDeletedMessages)).Methods("GET")
+	api.HandleFunc("/messages/{id}/revisions", h.requireAdmin(h.GetMessageRevisions)).Methods("GET")
+	api.HandleFunc("/messages/{id}/restore", h.requireAdmin(h.RestoreMessage)).Methods("POST")
+	api.HandleFunc("/messages/{id}", h.GetMessage).Methods("GET")
+	api.HandleFunc("/messages/{id}", h.authenticate(h.UpdateMessage)).Methods("PUT")
+	api.HandleFunc("/messages/{id}", h.authenticate(h.DeleteMessage)).Methods("DELETE")
+	api.HandleFunc("/status", h.ListHTTPStatuses).Methods("GET")
+	api.HandleFunc("/status/{code}", h.GetHTTPStatus).Methods("GET")
+	api.HandleFunc("/status/{code}/image", h.GetHTTPStatusImage).Methods("GET")
+	api.HandleFunc("/health", h.HealthCheck).Methods("GET")
+
+	// mux only runs middleware for matched routes, so preflight requests need
+	// a route of their own; corsMiddleware answers them
+	router.PathPrefix("/").Methods(http.MethodOptions).HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	return router
+}
+
+// Register handles POST /api/auth/register
+// It claims a username and returns a bearer token for it.
+func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
+	var req models.RegisterRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	username := auth.NormalizeUsername(req.Username)
+	token, err := h.tokens.Issue(username, auth.RoleUser)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, auth.ErrUsernameTaken) {
+			status = http.StatusConflict
+		}
+		h.writeError(w, status, err.Error())
+		return
+	}
+
+	h.writeJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: models.TokenResponse{
+			Token:    token,
+			Username: username,
+			Role:     auth.RoleUser,
+		},
+	})
 }
 
 // GetMessages handles GET /api/messages
 func (h *Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetMessages handler
-	// Get all messages from storage
-	// Create successful API response
-	// Write JSON response with status 200
-	// Handle any errors appropriately
+	messages := h.storage.GetAll()
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    messages,
+	})
+}
+
+// GetMessage handles GET /api/messages/{id}
+// The response carries the message version as an ETag; a request whose
+// If-None-Match header matches it gets 304 Not Modified.
+func (h *Handler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	message, err := h.storage.GetByID(id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", message.ETag())
+	if inm := r.Header.Get("If-None-Match"); inm != "" && message.MatchesETag(inm, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    message,
+	})
 }
 
 // CreateMessage handles POST /api/messages
+// The author is taken from the bearer token, not from the request body.
 func (h *Handler) CreateMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement CreateMessage handler
-	// Parse JSON request body into CreateMessageRequest
-	// Validate the request
-	// Create message in storage
-	// Create successful API response
-	// Write JSON response with status 201
-	// Handle validation and storage errors appropriately
+	var req models.CreateMessageRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	identity, _ := auth.FromContext(r.Context())
+	req.Username = identity.Username
+	if err := req.Validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.moderate(w, identity.Username, req.Content) {
+		return
+	}
+
+	message, err := h.storage.Create(req.Username, req.Content)
+	if err != nil {
+		h.writeError(w, http.StatusInternalServerError, "Failed to create message")
+		return
+	}
+
+	w.Header().Set("ETag", message.ETag())
+	h.writeJSON(w, http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    message,
+	})
 }
 
 // UpdateMessage handles PUT /api/messages/{id}
+// Only the author or an admin may update a message. If the request carries an
+// If-Match header the update is applied only when it matches the current ETag,
+// otherwise 412 Precondition Failed is returned.
 func (h *Handler) UpdateMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement UpdateMessage handler
-	// Extract ID from URL path variables
-	// Parse JSON request body into UpdateMessageRequest
-	// Validate the request
-	// Update message in storage
-	// Create successful API response
-	// Write JSON response with status 200
-	// Handle validation, parsing, and storage errors appropriately
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	var req models.UpdateMessageRequest
+	if err := h.parseJSON(r, &req); err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if err := req.Validate(); err != nil {
+		h.writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Checked before moderation, so refused requests are not rate limit attempts
+	version, err := h.expectedVersion(r, id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	identity, _ := auth.FromContext(r.Context())
+	if !h.moderate(w, identity.Username, req.Content) {
+		return
+	}
+
+	message, err := h.storage.UpdateIfVersion(id, req.Content, version, identity.Username)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", message.ETag())
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    message,
+	})
 }
 
 // DeleteMessage handles DELETE /api/messages/{id}
+// Ownership and If-Match are checked the same way as in UpdateMessage.
 func (h *Handler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement DeleteMessage handler
-	// Extract ID from URL path variables
-	// Delete message from storage
-	// Write response with status 204 (No Content)
-	// Handle parsing and storage errors appropriately
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	version, err := h.expectedVersion(r, id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	identity, _ := auth.FromContext(r.Context())
+	if err := h.storage.DeleteIfVersion(id, version, identity.Username); err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeletedMessages handles GET /api/messages/deleted
+func (h *Handler) GetDeletedMessages(w http.ResponseWriter, r *http.Request) {
+	messages := h.storage.GetDeleted()
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    messages,
+	})
+}
+
+// GetMessageRevisions handles GET /api/messages/{id}/revisions
+func (h *Handler) GetMessageRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	revisions, err := h.storage.GetRevisions(id)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    revisions,
+	})
+}
+
+// RestoreMessage handles POST /api/messages/{id}/restore
+func (h *Handler) RestoreMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseID(r)
+	if err != nil {
+		h.writeError(w, http.StatusBadRequest, "Invalid message ID")
+		return
+	}
+
+	identity, _ := auth.FromContext(r.Context())
+	message, err := h.storage.Restore(id, identity.Username)
+	if err != nil {
+		h.writeStorageError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", message.ETag())
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    message,
+	})
+}
+
+// ListHTTPStatuses handles GET /api/status
+func (h *Handler) ListHTTPStatuses(w http.ResponseWriter, r *http.Request) {
+	statuses := statuscatalog.All()
+	responses := make([]models.HTTPStatusResponse, 0, len(statuses))
+	for _, status := range statuses {
+		responses = append(responses, newHTTPStatusResponse(r, status))
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    responses,
+	})
 }
 
 // GetHTTPStatus handles GET /api/status/{code}
 func (h *Handler) GetHTTPStatus(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement GetHTTPStatus handler
-	// Extract status code from URL path variables
-	// Validate status code (must be between 100-599)
-	// Create HTTPStatusResponse with:
-	//   - StatusCode: parsed code
-	//   - ImageURL: "https://http.cat/{code}"
-	//   - Description: HTTP status description
-	// Create successful API response
-	// Write JSON response with status 200
-	// Handle parsing and validation errors appropriately
+	status, ok := h.lookupHTTPStatus(w, r)
+	if !ok {
+		return
+	}
+
+	h.writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    newHTTPStatusResponse(r, status),
+	})
+}
+
+// GetHTTPStatusImage handles GET /api/status/{code}/image
+// The image is rendered from the embedded catalog, so no external site is needed.
+func (h *Handler) GetHTTPStatusImage(w http.ResponseWriter, r *http.Request) {
+	status, ok := h.lookupHTTPStatus(w, r)
+	if !ok {
+		return
+	}
+
+	image, ok := statuscatalog.Image(status.Code)
+	if !ok {
+		h.writeError(w, http.StatusInternalServerError, "Failed to render status image")
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.WriteHeader(http.StatusOK)
+	w.Write(image)
+}
+
+// lookupHTTPStatus resolves the {code} path variable against the catalog,
+// writing an error response when the code is invalid or not registered
+func (h *Handler) lookupHTTPStatus(w http.ResponseWriter, r *http.Request) (statuscatalog.Status, bool) {
+	code, err := strconv.Atoi(mux.Vars(r)["code"])
+	if err != nil || code < 100 || code > 599 {
+		h.writeError(w, http.StatusBadRequest, "Invalid status code")
+		return statuscatalog.Status{}, false
+	}
+
+	status, ok := statuscatalog.Lookup(code)
+	if !ok {
+		h.writeError(w, http.StatusNotFound, fmt.Sprintf("Status code %d is not registered", code))
+		return statuscatalog.Status{}, false
+	}
+	return status, true
 }
 
 // HealthCheck handles GET /api/health
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	// TODO: Implement HealthCheck handler
-	// Create a simple health check response with:
-	//   - status: "ok"
-	//   - message: "API is running"
-	//   - timestamp: current time
-	//   - total_messages: count from storage
-	// Write JSON response with status 200
+	h.writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":         "ok",
+		"message":        "API is running",
+		"timestamp":      time.Now(),
+		"total_messages": h.storage.Count(),
+	})
+}
+
+// expectedVersion checks that the caller may modify the message with the
+// given id and resolves the If-Match header of r against it. It returns 0
+// when there is no precondition to enforce and storage.ErrVersionMismatch
+// when the header does not match.
+func (h *Handler) expectedVersion(r *http.Request, id int) (int, error) {
+	current, err := h.storage.GetByID(id)
+	if err != nil {
+		return 0, err
+	}
+
+	identity, _ := auth.FromContext(r.Context())
+	if !identity.CanModify(current.Username) {
+		return 0, errForbidden
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return 0, nil
+	}
+	if !current.MatchesETag(ifMatch, false) {
+		return 0, storage.ErrVersionMismatch
+	}
+	return current.Version, nil
+}
+
+// moderate runs the moderation chain on content submitted by username.
+// On rejection it writes 422 Unprocessable Content (or 429 Too Many Requests
+// when rate limited) with the reasons and returns false.
+func (h *Handler) moderate(w http.ResponseWriter, username, content string) bool {
+	if h.moderator == nil {
+		return true
+	}
+
+	err := h.moderator.Check(moderation.Submission{Username: username, Content: content})
+	if err == nil {
+		return true
+	}
+
+	var rejected *moderation.RejectedError
+	if !errors.As(err, &rejected) {
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+
+	status := http.StatusUnprocessableEntity
+	if rejected.RateLimited() {
+		status = http.StatusTooManyRequests
+	}
+	h.writeJSON(w, status, models.APIResponse{
+		Success: false,
+		Error:   rejected.Error(),
+		Reasons: rejected.Reasons,
+	})
+	return false
+}
+
+// authenticate requires a valid bearer token and stores the caller's
+// identity in the request context
+func (h *Handler) authenticate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := auth.BearerToken(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			h.writeError(w, http.StatusUnauthorized, "Missing bearer token")
+			return
+		}
+		identity, ok := h.tokens.Lookup(token)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			h.writeError(w, http.StatusUnauthorized, "Invalid bearer token")
+			return
+		}
+		next(w, r.WithContext(auth.NewContext(r.Context(), identity)))
+	}
+}
+
+// requireAdmin is authenticate restricted to the admin role
+func (h *Handler) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return h.authenticate(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := auth.FromContext(r.Context())
+		if !identity.IsAdmin() {
+			h.writeError(w, http.StatusForbidden, "Admin role required")
+			return
+		}
+		next(w, r)
+	})
+}
+
+// Helper function to write storage errors with the matching status code
+func (h *Handler) writeStorageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrMessageNotFound):
+		h.writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, storage.ErrInvalidID):
+		h.writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, storage.ErrVersionMismatch):
+		h.writeError(w, http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, storage.ErrMessageNotDeleted):
+		h.writeError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, errForbidden):
+		h.writeError(w, http.StatusForbidden, err.Error())
+	default:
+		h.writeError(w, http.StatusInternalServerError, err.Error())
+	}
 }
 
 // Helper function to write JSON responses
 func (h *Handler) writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	// TODO: Implement writeJSON helper
-	// Set Content-Type header to "application/json"
-	// Set status code
-	// Encode data as JSON and write to response
-	// Log any encoding errors
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("failed to encode response: %v", err)
+	}
 }
 
 // Helper function to write error responses
 func (h *Handler) writeError(w http.ResponseWriter, status int, message string) {
-	// TODO: Implement writeError helper
-	// Create APIResponse with Success: false and Error: message
-	// Use writeJSON to send the error response
+	h.writeJSON(w, status, models.APIResponse{
+		Success: false,
+		Error:   message,
+	})
 }
 
 // Helper function to parse JSON request body
 func (h *Handler) parseJSON(r *http.Request, dst interface{}) error {
-	// TODO: Implement parseJSON helper
-	// Create JSON decoder from request body
-	// Decode into destination interface
-	// Return any decoding errors
-	return nil
+	return json.NewDecoder(r.Body).Decode(dst)
+}
+
+// Helper function to parse the message ID from URL path variables
+func parseID(r *http.Request) (int, error) {
+	return strconv.Atoi(mux.Vars(r)["id"])
 }
 
-// Helper function to get HTTP status description
-func getHTTPStatusDescription(code int) string {
-	// TODO: Implement getHTTPStatusDescription
-	// Return appropriate description for common HTTP status codes
-	// Use a switch statement or map to handle:
-	// 200: "OK", 201: "Created", 204: "No Content"
-	// 400: "Bad Request", 401: "Unauthorized", 404: "Not Found"
-	// 500: "Internal Server Error", etc.
-	// Return "Unknown Status" for unrecognized codes
-	return "Unknown Status"
+// Helper function to build the API representation of a catalog entry. The
+// image URL is absolute so that clients can load it as is.
+func newHTTPStatusResponse(r *http.Request, status statuscatalog.Status) models.HTTPStatusResponse {
+	return models.HTTPStatusResponse{
+		StatusCode:  status.Code,
+		ImageURL:    fmt.Sprintf("%s/api/status/%d/image", baseURL(r), status.Code),
+		Description: status.Reason,
+		Category:    status.Category,
+		Reference:   status.Reference,
+	}
+}
+
+// baseURL returns the scheme and host the request was sent to, honouring
+// X-Forwarded-Proto from a TLS-terminating proxy
+func baseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host
 }
 
 // CORS middleware
 func corsMiddleware(next http.Handler) http.Handler {
-	// TODO: Implement CORS middleware
-	// Set the following headers:
-	// Access-Control-Allow-Origin: *
-	// Access-Control-Allow-Methods: GET, POST, PUT, DELETE, OPTIONS
-	// Access-Control-Allow-Headers: Content-Type, Authorization
-	// Handle OPTIONS preflight requests
-	// Call next handler for non-OPTIONS requests
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Implement CORS logic here
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-Match, If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
 		next.ServeHTTP(w, r)
 	})
 }
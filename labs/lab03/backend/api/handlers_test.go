@@ -3,16 +3,29 @@ package api
 import (
 	"bytes"
 	"encoding/json"
+	"lab03-backend/auth"
 	"lab03-backend/models"
+	"lab03-backend/moderation"
 	"lab03-backend/storage"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+)
+
+const (
+	userToken  = "user-token"
+	otherToken = "other-token"
+	adminToken = "admin-token"
 )
 
 func setupTestHandler() *Handler {
 	storage := storage.NewMemoryStorage()
-	return NewHandler(storage)
+	tokens := auth.NewTokenStore()
+	tokens.Register(userToken, auth.Identity{Username: "testuser", Role: auth.RoleUser})
+	tokens.Register(otherToken, auth.Identity{Username: "otheruser", Role: auth.RoleUser})
+	tokens.Register(adminToken, auth.Identity{Username: "admin", Role: auth.RoleAdmin})
+	return NewHandler(storage, tokens, moderation.NewDefaultChain(moderation.DefaultConfig()))
 }
 
 func TestGetMessages(t *testing.T) {
@@ -56,6 +69,7 @@ func TestCreateMessage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("Authorization", "Bearer "+userToken)
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
@@ -88,6 +102,7 @@ func TestUpdateMessage(t *testing.T) {
 
 	jsonData, _ := json.Marshal(createReq)
 	createHttpReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
+	createHttpReq.Header.Set("Authorization", "Bearer "+userToken)
 	createHttpReq.Header.Set("Content-Type", "application/json")
 
 	createRr := httptest.NewRecorder()
@@ -103,6 +118,7 @@ func TestUpdateMessage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("Authorization", "Bearer "+userToken)
 	req.Header.Set("Content-Type", "application/json")
 
 	rr := httptest.NewRecorder()
@@ -125,6 +141,7 @@ func TestDeleteMessage(t *testing.T) {
 
 	jsonData, _ := json.Marshal(createReq)
 	createHttpReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
+	createHttpReq.Header.Set("Authorization", "Bearer "+userToken)
 	createHttpReq.Header.Set("Content-Type", "application/json")
 
 	createRr := httptest.NewRecorder()
@@ -135,6 +152,7 @@ func TestDeleteMessage(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	req.Header.Set("Authorization", "Bearer "+userToken)
 
 	rr := httptest.NewRecorder()
 	router.ServeHTTP(rr, req)
@@ -209,3 +227,397 @@ func TestHealthCheck(t *testing.T) {
 		t.Errorf("Expected Content-Type application/json, got %s", contentType)
 	}
 }
+
+func TestMessageETagPreconditions(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	jsonData, _ := json.Marshal(models.CreateMessageRequest{Username: "testuser", Content: "original"})
+	createReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
+	createReq.Header.Set("Authorization", "Bearer "+userToken)
+	createRr := httptest.NewRecorder()
+	router.ServeHTTP(createRr, createReq)
+
+	etag := createRr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header on create")
+	}
+
+	// Conditional GET with the current ETag is not modified
+	getReq, _ := http.NewRequest("GET", "/api/messages/1", nil)
+	getReq.Header.Set("If-None-Match", etag)
+	getRr := httptest.NewRecorder()
+	router.ServeHTTP(getRr, getReq)
+	if getRr.Code != http.StatusNotModified {
+		t.Errorf("Expected status %v, got %v", http.StatusNotModified, getRr.Code)
+	}
+
+	// Update with the current ETag succeeds and returns a new one
+	jsonData, _ = json.Marshal(models.UpdateMessageRequest{Content: "first edit"})
+	putReq, _ := http.NewRequest("PUT", "/api/messages/1", bytes.NewBuffer(jsonData))
+	putReq.Header.Set("Authorization", "Bearer "+userToken)
+	putReq.Header.Set("If-Match", etag)
+	putRr := httptest.NewRecorder()
+	router.ServeHTTP(putRr, putReq)
+	if putRr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, putRr.Code)
+	}
+	newETag := putRr.Header().Get("ETag")
+	if newETag == "" || newETag == etag {
+		t.Errorf("Expected a new ETag after update, got %q", newETag)
+	}
+
+	// A second writer still holding the stale ETag is rejected
+	jsonData, _ = json.Marshal(models.UpdateMessageRequest{Content: "second edit"})
+	staleReq, _ := http.NewRequest("PUT", "/api/messages/1", bytes.NewBuffer(jsonData))
+	staleReq.Header.Set("Authorization", "Bearer "+userToken)
+	staleReq.Header.Set("If-Match", etag)
+	staleRr := httptest.NewRecorder()
+	router.ServeHTTP(staleRr, staleReq)
+	if staleRr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %v, got %v", http.StatusPreconditionFailed, staleRr.Code)
+	}
+
+	// Stale delete is rejected, current delete succeeds
+	delReq, _ := http.NewRequest("DELETE", "/api/messages/1", nil)
+	delReq.Header.Set("Authorization", "Bearer "+userToken)
+	delReq.Header.Set("If-Match", etag)
+	delRr := httptest.NewRecorder()
+	router.ServeHTTP(delRr, delReq)
+	if delRr.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %v, got %v", http.StatusPreconditionFailed, delRr.Code)
+	}
+
+	delReq, _ = http.NewRequest("DELETE", "/api/messages/1", nil)
+	delReq.Header.Set("Authorization", "Bearer "+userToken)
+	delReq.Header.Set("If-Match", newETag)
+	delRr = httptest.NewRecorder()
+	router.ServeHTTP(delRr, delReq)
+	if delRr.Code != http.StatusNoContent {
+		t.Errorf("Expected status %v, got %v", http.StatusNoContent, delRr.Code)
+	}
+}
+
+func TestMessageRevisionsAndRestore(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	jsonData, _ := json.Marshal(models.CreateMessageRequest{Username: "testuser", Content: "original"})
+	createReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
+	createReq.Header.Set("Authorization", "Bearer "+userToken)
+	router.ServeHTTP(httptest.NewRecorder(), createReq)
+
+	jsonData, _ = json.Marshal(models.UpdateMessageRequest{Content: "edited"})
+	putReq, _ := http.NewRequest("PUT", "/api/messages/1", bytes.NewBuffer(jsonData))
+	putReq.Header.Set("Authorization", "Bearer "+userToken)
+	router.ServeHTTP(httptest.NewRecorder(), putReq)
+
+	delReq, _ := http.NewRequest("DELETE", "/api/messages/1", nil)
+	delReq.Header.Set("Authorization", "Bearer "+userToken)
+	router.ServeHTTP(httptest.NewRecorder(), delReq)
+
+	revReq, _ := http.NewRequest("GET", "/api/messages/1/revisions", nil)
+	revReq.Header.Set("Authorization", "Bearer "+adminToken)
+	revRr := httptest.NewRecorder()
+	router.ServeHTTP(revRr, revReq)
+	if revRr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, revRr.Code)
+	}
+	var revisions struct {
+		Data []models.Revision `json:"data"`
+	}
+	if err := json.NewDecoder(revRr.Body).Decode(&revisions); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(revisions.Data) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(revisions.Data))
+	}
+	if edit := revisions.Data[1]; edit.EditedBy != "testuser" || edit.Action != models.RevisionEdited {
+		t.Errorf("Expected an edit by testuser, got %+v", edit)
+	}
+	if last := revisions.Data[2]; last.EditedBy != "testuser" || last.Action != models.RevisionDeleted || last.Version != 3 {
+		t.Errorf("Expected a delete by testuser, got %+v", last)
+	}
+
+	restoreReq, _ := http.NewRequest("POST", "/api/messages/1/restore", nil)
+	restoreReq.Header.Set("Authorization", "Bearer "+adminToken)
+	restoreRr := httptest.NewRecorder()
+	router.ServeHTTP(restoreRr, restoreReq)
+	if restoreRr.Code != http.StatusOK {
+		t.Errorf("Expected status %v, got %v", http.StatusOK, restoreRr.Code)
+	}
+	if etag := restoreRr.Header().Get("ETag"); etag != `"4"` {
+		t.Errorf("Expected the restore to be version 4, got ETag %s", etag)
+	}
+
+	restoreRr = httptest.NewRecorder()
+	router.ServeHTTP(restoreRr, restoreReq)
+	if restoreRr.Code != http.StatusConflict {
+		t.Errorf("Expected status %v, got %v", http.StatusConflict, restoreRr.Code)
+	}
+}
+
+func TestMessageOwnership(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	// Username in the body is ignored in favor of the token's identity
+	jsonData, _ := json.Marshal(models.CreateMessageRequest{Username: "impostor", Content: "hello"})
+	createReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
+	createReq.Header.Set("Authorization", "Bearer "+userToken)
+	createRr := httptest.NewRecorder()
+	router.ServeHTTP(createRr, createReq)
+	var created struct {
+		Data models.Message `json:"data"`
+	}
+	json.NewDecoder(createRr.Body).Decode(&created)
+	if created.Data.Username != "testuser" {
+		t.Errorf("Expected author testuser, got %q", created.Data.Username)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		token          string
+		expectedStatus int
+	}{
+		{"update without token", "PUT", "", http.StatusUnauthorized},
+		{"update with unknown token", "PUT", "bogus", http.StatusUnauthorized},
+		{"update by another user", "PUT", otherToken, http.StatusForbidden},
+		{"delete by another user", "DELETE", otherToken, http.StatusForbidden},
+		{"update by admin", "PUT", adminToken, http.StatusOK},
+		{"delete by author", "DELETE", userToken, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonData, _ := json.Marshal(models.UpdateMessageRequest{Content: "edited"})
+			req, _ := http.NewRequest(tt.method, "/api/messages/1", bytes.NewBuffer(jsonData))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != tt.expectedStatus {
+				t.Errorf("Expected status %v, got %v", tt.expectedStatus, rr.Code)
+			}
+		})
+	}
+
+	restoreReq, _ := http.NewRequest("POST", "/api/messages/1/restore", nil)
+	restoreReq.Header.Set("Authorization", "Bearer "+userToken)
+	restoreRr := httptest.NewRecorder()
+	router.ServeHTTP(restoreRr, restoreReq)
+	if restoreRr.Code != http.StatusForbidden {
+		t.Errorf("Expected status %v for non-admin restore, got %v", http.StatusForbidden, restoreRr.Code)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	jsonData, _ := json.Marshal(models.RegisterRequest{Username: "newuser"})
+	req, _ := http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("Expected status %v, got %v", http.StatusCreated, rr.Code)
+	}
+
+	var response struct {
+		Data models.TokenResponse `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Data.Token == "" {
+		t.Fatal("Expected a token in the response")
+	}
+
+	jsonData, _ = json.Marshal(models.CreateMessageRequest{Content: "hi"})
+	createReq, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
+	createReq.Header.Set("Authorization", "Bearer "+response.Data.Token)
+	createRr := httptest.NewRecorder()
+	router.ServeHTTP(createRr, createReq)
+	if createRr.Code != http.StatusCreated {
+		t.Errorf("Expected status %v with issued token, got %v", http.StatusCreated, createRr.Code)
+	}
+
+	// The same username cannot be claimed twice, whatever its case or spacing
+	for _, username := range []string{"newuser", " NewUser "} {
+		jsonData, _ = json.Marshal(models.RegisterRequest{Username: username})
+		req, _ = http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+		rr = httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Register(%q): expected status %v, got %v", username, http.StatusConflict, rr.Code)
+		}
+	}
+
+	jsonData, _ = json.Marshal(models.RegisterRequest{Username: "  Mixed Case "})
+	req, _ = http.NewRequest("POST", "/api/auth/register", bytes.NewBuffer(jsonData))
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&response)
+	if rr.Code != http.StatusCreated || response.Data.Username != "mixed case" {
+		t.Errorf("Expected mixed case to be registered, got status %v and %q", rr.Code, response.Data.Username)
+	}
+}
+
+func TestHTTPStatusCatalog(t *testing.T) {
+	handler := setupTestHandler()
+	router := handler.SetupRoutes()
+
+	req, _ := http.NewRequest("GET", "/api/status", nil)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %v, got %v", http.StatusOK, rr.Code)
+	}
+	var list struct {
+		Data []models.HTTPStatusResponse `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&list); err != nil {
+		t.Fatalf("Could not decode response: %v", err)
+	}
+	if len(list.Data) < 60 {
+		t.Errorf("Expected the full catalog, got %d entries", len(list.Data))
+	}
+
+	req = httptest.NewRequest("GET", "http://localhost:8080/api/status/429", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	var single struct {
+		Data models.HTTPStatusResponse `json:"data"`
+	}
+	json.NewDecoder(rr.Body).Decode(&single)
+	if single.Data.Description != "Too Many Requests" || single.Data.Category != "Client Error" {
+		t.Errorf("Unexpected status entry: %+v", single.Data)
+	}
+	if single.Data.ImageURL != "http://localhost:8080/api/status/429/image" {
+		t.Errorf("Expected an absolute image URL on the request host, got %q", single.Data.ImageURL)
+	}
+
+	req = httptest.NewRequest("GET", "http://api.example.com/api/status/429", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	json.NewDecoder(rr.Body).Decode(&single)
+	if single.Data.ImageURL != "https://api.example.com/api/status/429/image" {
+		t.Errorf("Expected the forwarded scheme in the image URL, got %q", single.Data.ImageURL)
+	}
+
+	req = httptest.NewRequest("GET", single.Data.ImageURL, nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Errorf("Expected SVG image, got status %v and type %q", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	req, _ = http.NewRequest("GET", "/api/status/299", nil)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %v for unregistered code, got %v", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestMessageModeration(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	tokens := auth.NewTokenStore()
+	tokens.Register(userToken, auth.Identity{Username: "testuser", Role: auth.RoleUser})
+	moderator := moderation.NewDefaultChain(&moderation.Config{
+		BannedWords: []string{"spam"},
+		MaxLength:   50,
+		RateLimit:   2,
+		RateWindow:  time.Minute,
+	})
+	router := NewHandler(store, tokens, moderator).SetupRoutes()
+
+	post := func(content string) *httptest.ResponseRecorder {
+		jsonData, _ := json.Marshal(models.CreateMessageRequest{Content: content})
+		req, _ := http.NewRequest("POST", "/api/messages", bytes.NewBuffer(jsonData))
+		req.Header.Set("Authorization", "Bearer "+userToken)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := post("buy 5P4M today")
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %v, got %v", http.StatusUnprocessableEntity, rr.Code)
+	}
+	var response models.APIResponse
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Success || len(response.Reasons) != 1 || response.Reasons[0].Rule != moderation.RuleBannedWords {
+		t.Errorf("Unexpected rejection response: %+v", response)
+	}
+
+	if rr := post("hello"); rr.Code != http.StatusCreated {
+		t.Errorf("Expected status %v, got %v", http.StatusCreated, rr.Code)
+	}
+	if rr := post("hello again"); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %v, got %v", http.StatusTooManyRequests, rr.Code)
+	}
+	if store.Count() != 1 {
+		t.Errorf("Expected only the accepted message to be stored, got %d", store.Count())
+	}
+}
+
+func TestRateLimitAfterPreconditions(t *testing.T) {
+	tokens := auth.NewTokenStore()
+	tokens.Register(userToken, auth.Identity{Username: "testuser", Role: auth.RoleUser})
+	tokens.Register(otherToken, auth.Identity{Username: "otheruser", Role: auth.RoleUser})
+	moderator := moderation.NewDefaultChain(&moderation.Config{RateLimit: 1, RateWindow: time.Minute})
+	router := NewHandler(storage.NewMemoryStorage(), tokens, moderator).SetupRoutes()
+
+	send := func(method, path, token, ifMatch, content string) int {
+		jsonData, _ := json.Marshal(models.CreateMessageRequest{Content: content})
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(jsonData))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	if code := send("POST", "/api/messages", userToken, "", "hello"); code != http.StatusCreated {
+		t.Fatalf("Expected status %v, got %v", http.StatusCreated, code)
+	}
+
+	// Requests failing ownership or If-Match are not attempts
+	for i := 0; i < 2; i++ {
+		if code := send("PUT", "/api/messages/1", otherToken, "", "mine now"); code != http.StatusForbidden {
+			t.Errorf("Expected status %v, got %v", http.StatusForbidden, code)
+		}
+	}
+	if code := send("POST", "/api/messages", otherToken, "", "hi"); code != http.StatusCreated {
+		t.Errorf("Forbidden updates should not count against the rate limit, got status %v", code)
+	}
+	if code := send("PUT", "/api/messages/1", userToken, `"9"`, "edited"); code != http.StatusPreconditionFailed {
+		t.Errorf("Expected status %v before the rate limit, got %v", http.StatusPreconditionFailed, code)
+	}
+	if code := send("PUT", "/api/messages/1", userToken, "", "edited"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected status %v, got %v", http.StatusTooManyRequests, code)
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	router := setupTestHandler().SetupRoutes()
+
+	for _, path := range []string{"/api/messages", "/api/messages/1", "/api/status/404/image"} {
+		req := httptest.NewRequest(http.MethodOptions, path, nil)
+		req.Header.Set("Origin", "http://localhost:3000")
+		req.Header.Set("Access-Control-Request-Method", "PUT")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("OPTIONS %s: expected status %v, got %v", path, http.StatusOK, rr.Code)
+		}
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+			t.Errorf("OPTIONS %s: expected Access-Control-Allow-Origin *, got %q", path, got)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Roles known to the API
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Identity is the authenticated caller behind a bearer token
+type Identity struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// IsAdmin reports whether the identity has the admin role
+func (i Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+// CanModify reports whether the identity may change content owned by author
+func (i Identity) CanModify(author string) bool {
+	return i.IsAdmin() || i.Username == author
+}
+
+// TokenStore keeps issued bearer tokens in memory
+type TokenStore struct {
+	mutex     sync.RWMutex
+	tokens    map[string]Identity
+	usernames map[string]bool
+}
+
+// NewTokenStore creates an empty token store
+func NewTokenStore() *TokenStore {
+	return &TokenStore{
+		tokens:    make(map[string]Identity),
+		usernames: make(map[string]bool),
+	}
+}
+
+// NormalizeUsername trims surrounding space and lowercases username, so
+// "Alice " and "alice" are the same user
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// Issue creates a random token for a new username.
+// Each username can only be claimed once, ignoring case and surrounding space.
+func (s *TokenStore) Issue(username, role string) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.Register(token, Identity{Username: username, Role: role}); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// Register stores a known token, e.g. an admin token from configuration.
+// The username is stored normalized by NormalizeUsername.
+func (s *TokenStore) Register(token string, identity Identity) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+	identity.Username = NormalizeUsername(identity.Username)
+	if identity.Username == "" {
+		return ErrInvalidUsername
+	}
+	if identity.Role != RoleUser && identity.Role != RoleAdmin {
+		return ErrInvalidRole
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.usernames[identity.Username] {
+		return ErrUsernameTaken
+	}
+	s.tokens[token] = identity
+	s.usernames[identity.Username] = true
+	return nil
+}
+
+// Lookup returns the identity a token was issued for
+func (s *TokenStore) Lookup(token string) (Identity, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	identity, ok := s.tokens[token]
+	return identity, ok
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer" header
+func BearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying identity
+func NewContext(ctx context.Context, identity Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity stored by NewContext
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Common errors
+var (
+	ErrInvalidToken    = errors.New("invalid token")
+	ErrInvalidUsername = errors.New("username is required")
+	ErrInvalidRole     = errors.New("invalid role")
+	ErrUsernameTaken   = errors.New("username is already taken")
+)
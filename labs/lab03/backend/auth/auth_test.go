@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestTokenStore(t *testing.T) {
+	store := NewTokenStore()
+
+	token, err := store.Issue("alice", RoleUser)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+
+	identity, ok := store.Lookup(token)
+	if !ok {
+		t.Fatal("Lookup did not find issued token")
+	}
+	if identity.Username != "alice" || identity.Role != RoleUser {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+
+	for _, username := range []string{"alice", " Alice", "ALICE\t"} {
+		if _, err := store.Issue(username, RoleUser); err != ErrUsernameTaken {
+			t.Errorf("Issue(%q): expected ErrUsernameTaken, got %v", username, err)
+		}
+	}
+	if _, err := store.Issue(" \t ", RoleUser); err != ErrInvalidUsername {
+		t.Errorf("Expected ErrInvalidUsername for a blank username, got %v", err)
+	}
+
+	token, err = store.Issue("  Carol ", RoleUser)
+	if err != nil {
+		t.Fatalf("Issue failed: %v", err)
+	}
+	if identity, _ := store.Lookup(token); identity.Username != "carol" {
+		t.Errorf("Expected the username stored as carol, got %q", identity.Username)
+	}
+	if _, err := store.Issue("bob", "superuser"); err != ErrInvalidRole {
+		t.Errorf("Expected ErrInvalidRole, got %v", err)
+	}
+	if _, ok := store.Lookup("unknown"); ok {
+		t.Error("Lookup should fail for unknown token")
+	}
+}
+
+func TestIdentityCanModify(t *testing.T) {
+	user := Identity{Username: "alice", Role: RoleUser}
+	admin := Identity{Username: "root", Role: RoleAdmin}
+
+	if !user.CanModify("alice") {
+		t.Error("Author should be able to modify own content")
+	}
+	if user.CanModify("bob") {
+		t.Error("User should not modify someone else's content")
+	}
+	if !admin.CanModify("bob") {
+		t.Error("Admin should be able to modify any content")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		ok     bool
+	}{
+		{"Bearer abc", "abc", true},
+		{"bearer abc", "abc", true},
+		{"Basic abc", "", false},
+		{"Bearer ", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", tt.header)
+		token, ok := BearerToken(req)
+		if token != tt.token || ok != tt.ok {
+			t.Errorf("BearerToken(%q) = %q, %v; want %q, %v", tt.header, token, ok, tt.token, tt.ok)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("Empty context should not carry an identity")
+	}
+
+	ctx := NewContext(context.Background(), Identity{Username: "alice", Role: RoleUser})
+	identity, ok := FromContext(ctx)
+	if !ok || identity.Username != "alice" {
+		t.Errorf("Unexpected identity from context: %+v, %v", identity, ok)
+	}
+}
@@ -1,21 +1,49 @@
 package main
 
 import (
+	"lab03-backend/api"
+	"lab03-backend/auth"
+	"lab03-backend/moderation"
+	"lab03-backend/storage"
 	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
 )
 
 func main() {
-	// TODO: Create a new memory storage instance
-	// TODO: Create a new API handler with the storage
-	// TODO: Setup routes using the handler
-	// TODO: Configure server with:
-	//   - Address: ":8080"
-	//   - Handler: the router
-	//   - ReadTimeout: 15 seconds
-	//   - WriteTimeout: 15 seconds
-	//   - IdleTimeout: 60 seconds
-	// TODO: Add logging to show server is starting
-	// TODO: Start the server and handle any errors
+	store := storage.NewMemoryStorage()
+	tokens := auth.NewTokenStore()
 
-	log.Println("TODO: Implement main function")
+	// ADMIN_TOKEN grants the admin role to the "admin" username
+	if adminToken := os.Getenv("ADMIN_TOKEN"); adminToken != "" {
+		admin := auth.Identity{Username: "admin", Role: auth.RoleAdmin}
+		if err := tokens.Register(adminToken, admin); err != nil {
+			log.Fatalf("Failed to register admin token: %v", err)
+		}
+	}
+
+	// BANNED_WORDS is a comma-separated list of words rejected by moderation
+	moderationConfig := moderation.DefaultConfig()
+	if bannedWords := os.Getenv("BANNED_WORDS"); bannedWords != "" {
+		moderationConfig.BannedWords = strings.Split(bannedWords, ",")
+	}
+	moderator := moderation.NewDefaultChain(moderationConfig)
+
+	handler := api.NewHandler(store, tokens, moderator)
+	router := handler.SetupRoutes()
+
+	server := &http.Server{
+		Addr:         ":8080",
+		Handler:      router,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15 * time.Second,
+		IdleTimeout:  60 * time.Second,
+	}
+
+	log.Printf("Server starting on %s", server.Addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalf("Server failed: %v", err)
+	}
 }
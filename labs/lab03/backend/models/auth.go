@@ -0,0 +1,26 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+// RegisterRequest represents the request to claim a username and get a token
+type RegisterRequest struct {
+	Username string `json:"username" validate:"required"`
+}
+
+// TokenResponse represents an issued bearer token
+type TokenResponse struct {
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+// Validate checks if the register request is valid
+func (r *RegisterRequest) Validate() error {
+	if strings.TrimSpace(r.Username) == "" {
+		return errors.New("username is required")
+	}
+	return nil
+}
@@ -1,57 +1,150 @@
 package models
 
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
 // Message represents a chat message
 type Message struct {
-	// TODO: Add ID field of type int with json tag "id"
-	// TODO: Add Username field of type string with json tag "username"
-	// TODO: Add Content field of type string with json tag "content"
-	// TODO: Add Timestamp field of type time.Time with json tag "timestamp"
+	ID        int        `json:"id"`
+	Username  string     `json:"username"`
+	Content   string     `json:"content"`
+	Timestamp time.Time  `json:"timestamp"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
+}
+
+// RevisionAction is the change that produced a revision
+type RevisionAction string
+
+const (
+	RevisionCreated  RevisionAction = "created"
+	RevisionEdited   RevisionAction = "edited"
+	RevisionDeleted  RevisionAction = "deleted"
+	RevisionRestored RevisionAction = "restored"
+)
+
+// Revision represents one stored version of a message's content
+type Revision struct {
+	Version  int            `json:"version"`
+	Content  string         `json:"content"`
+	Action   RevisionAction `json:"action"`
+	EditedBy string         `json:"edited_by"`
+	EditedAt time.Time      `json:"edited_at"`
 }
 
 // CreateMessageRequest represents the request to create a new message
 type CreateMessageRequest struct {
-	// TODO: Add Username field of type string with json tag "username" and validation tag "required"
-	// TODO: Add Content field of type string with json tag "content" and validation tag "required"
+	Username string `json:"username" validate:"required"`
+	Content  string `json:"content" validate:"required"`
 }
 
 // UpdateMessageRequest represents the request to update a message
 type UpdateMessageRequest struct {
-	// TODO: Add Content field of type string with json tag "content" and validation tag "required"
+	Content string `json:"content" validate:"required"`
 }
 
 // HTTPStatusResponse represents the response for HTTP status code endpoint
 type HTTPStatusResponse struct {
-	// TODO: Add StatusCode field of type int with json tag "status_code"
-	// TODO: Add ImageURL field of type string with json tag "image_url"
-	// TODO: Add Description field of type string with json tag "description"
+	StatusCode  int    `json:"status_code"`
+	ImageURL    string `json:"image_url"`
+	Description string `json:"description"`
+	Category    string `json:"category"`
+	Reference   string `json:"reference"`
 }
 
 // APIResponse represents a generic API response
 type APIResponse struct {
-	// TODO: Add Success field of type bool with json tag "success"
-	// TODO: Add Data field of type interface{} with json tag "data,omitempty"
-	// TODO: Add Error field of type string with json tag "error,omitempty"
+	Success bool              `json:"success"`
+	Data    interface{}       `json:"data,omitempty"`
+	Error   string            `json:"error,omitempty"`
+	Reasons []RejectionReason `json:"reasons,omitempty"`
+}
+
+// RejectionReason explains why content moderation rejected a message
+type RejectionReason struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
 }
 
 // NewMessage creates a new message with the current timestamp
 func NewMessage(id int, username, content string) *Message {
-	// TODO: Return a new Message instance with provided parameters and current timestamp
-	return nil
+	now := time.Now()
+	return &Message{
+		ID:        id,
+		Username:  username,
+		Content:   content,
+		Timestamp: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+}
+
+// IsDeleted reports whether the message has been soft deleted
+func (m *Message) IsDeleted() bool {
+	return m.DeletedAt != nil
+}
+
+// Revision returns the current content of the message as a revision made
+// by editor
+func (m *Message) Revision(action RevisionAction, editor string) Revision {
+	return Revision{
+		Version:  m.Version,
+		Content:  m.Content,
+		Action:   action,
+		EditedBy: editor,
+		EditedAt: m.UpdatedAt,
+	}
+}
+
+// ETag returns the strong entity tag for the current version of the message
+func (m *Message) ETag() string {
+	return `"` + strconv.Itoa(m.Version) + `"`
+}
+
+// MatchesETag reports whether the message matches an If-Match or
+// If-None-Match header value. The header may hold a comma-separated list of
+// entity tags or "*", which matches any existing message. Weak tags are
+// compared by their opaque value when weak is true, as If-None-Match requires.
+func (m *Message) MatchesETag(header string, weak bool) bool {
+	current := m.ETag()
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == current {
+			return true
+		}
+	}
+	return false
 }
 
 // Validate checks if the create message request is valid
 func (r *CreateMessageRequest) Validate() error {
-	// TODO: Implement validation logic
-	// Check if Username is not empty
-	// Check if Content is not empty
-	// Return appropriate error messages
+	if strings.TrimSpace(r.Username) == "" {
+		return errors.New("username is required")
+	}
+	if strings.TrimSpace(r.Content) == "" {
+		return errors.New("content is required")
+	}
 	return nil
 }
 
 // Validate checks if the update message request is valid
 func (r *UpdateMessageRequest) Validate() error {
-	// TODO: Implement validation logic
-	// Check if Content is not empty
-	// Return appropriate error messages
+	if strings.TrimSpace(r.Content) == "" {
+		return errors.New("content is required")
+	}
 	return nil
 }
@@ -0,0 +1,127 @@
+package moderation
+
+import (
+	"fmt"
+	"lab03-backend/models"
+	"strings"
+	"time"
+)
+
+// Submission is a message as seen by the moderation rules
+type Submission struct {
+	Username string
+	Content  string
+}
+
+// Rule checks a submission and returns a reason when it must be rejected
+type Rule interface {
+	Check(s Submission) *models.RejectionReason
+}
+
+// RuleFunc adapts an ordinary function to the Rule interface
+type RuleFunc func(s Submission) *models.RejectionReason
+
+// Check calls f(s)
+func (f RuleFunc) Check(s Submission) *models.RejectionReason {
+	return f(s)
+}
+
+// Chain runs a list of rules and collects every rejection reason
+type Chain struct {
+	rules []Rule
+}
+
+// NewChain creates a chain running rules in the given order
+func NewChain(rules ...Rule) *Chain {
+	return &Chain{rules: rules}
+}
+
+// Use appends rules to the chain
+func (c *Chain) Use(rules ...Rule) {
+	c.rules = append(c.rules, rules...)
+}
+
+// Check runs all rules and returns a *RejectedError if any of them rejects
+// the submission
+func (c *Chain) Check(s Submission) error {
+	var reasons []models.RejectionReason
+	for _, rule := range c.rules {
+		if reason := rule.Check(s); reason != nil {
+			reasons = append(reasons, *reason)
+		}
+	}
+	if len(reasons) > 0 {
+		return &RejectedError{Reasons: reasons}
+	}
+	return nil
+}
+
+// Config configures the default moderation chain. Zero values disable the
+// corresponding rule.
+type Config struct {
+	BannedWords []string
+	MaxLength   int
+	MaxLinks    int
+	RateLimit   int
+	RateWindow  time.Duration
+}
+
+// DefaultConfig returns a default moderation configuration
+func DefaultConfig() *Config {
+	return &Config{
+		MaxLength:  1000,
+		MaxLinks:   3,
+		RateLimit:  30,
+		RateWindow: time.Minute,
+	}
+}
+
+// NewDefaultChain builds the standard chain from config. The rate limit
+// runs last so that it sees every attempt, including rejected ones.
+func NewDefaultChain(config *Config) *Chain {
+	chain := NewChain()
+	if len(config.BannedWords) > 0 {
+		chain.Use(NewBannedWords(config.BannedWords))
+	}
+	if config.MaxLength > 0 {
+		chain.Use(MaxLength(config.MaxLength))
+	}
+	if config.MaxLinks > 0 {
+		chain.Use(MaxLinks(config.MaxLinks))
+	}
+	if config.RateLimit > 0 && config.RateWindow > 0 {
+		chain.Use(NewRateLimiter(config.RateLimit, config.RateWindow))
+	}
+	return chain
+}
+
+// Rule names reported in rejection reasons
+const (
+	RuleBannedWords = "banned_words"
+	RuleMaxLength   = "max_length"
+	RuleMaxLinks    = "max_links"
+	RuleRateLimit   = "rate_limit"
+)
+
+// RejectedError is returned by Chain.Check when a submission is rejected
+type RejectedError struct {
+	Reasons []models.RejectionReason
+}
+
+func (e *RejectedError) Error() string {
+	messages := make([]string, len(e.Reasons))
+	for i, reason := range e.Reasons {
+		messages[i] = reason.Message
+	}
+	return fmt.Sprintf("message rejected: %s", strings.Join(messages, "; "))
+}
+
+// RateLimited reports whether the rejection includes the rate limit rule
+func (e *RejectedError) RateLimited() bool {
+	for _, reason := range e.Reasons {
+		if reason.Rule == RuleRateLimit {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package moderation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Hello", "hello"},
+		{"B4D W0RD", "bad word"},
+		{"$p@m", "spam"},
+		{"baaaad", "baaaad"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.expected {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestBannedWords(t *testing.T) {
+	rule := NewBannedWords([]string{"spam", "scam"})
+
+	tests := []struct {
+		content  string
+		rejected bool
+	}{
+		{"hello there", false},
+		{"buy spam now", true},
+		{"buy $P4M now", true},
+		{"sssscaaaam alert", true},
+		{"spammer", false},
+		{"sspam", false},
+		{"spaaam", true},
+	}
+
+	for _, tt := range tests {
+		reason := rule.Check(Submission{Username: "user", Content: tt.content})
+		if (reason != nil) != tt.rejected {
+			t.Errorf("Check(%q) rejected = %v, want %v", tt.content, reason != nil, tt.rejected)
+		}
+		if reason != nil && reason.Rule != RuleBannedWords {
+			t.Errorf("Check(%q) rule = %q, want %q", tt.content, reason.Rule, RuleBannedWords)
+		}
+	}
+}
+
+func TestBannedWordsRepeatedLetters(t *testing.T) {
+	rule := NewBannedWords([]string{"ass", "god"})
+
+	tests := []struct {
+		content  string
+		rejected bool
+	}{
+		// Collapsing "ass" to "as" or "good" to "god" must not match
+		{"as far as I know", false},
+		{"good morning", false},
+		{"ass", true},
+		{"@$$", true},
+		{"asssss", true},
+		{"gooood", true},
+	}
+
+	for _, tt := range tests {
+		if reason := rule.Check(Submission{Username: "user", Content: tt.content}); (reason != nil) != tt.rejected {
+			t.Errorf("Check(%q) rejected = %v, want %v", tt.content, reason != nil, tt.rejected)
+		}
+	}
+}
+
+func TestMaxLengthAndLinks(t *testing.T) {
+	if MaxLength(5).Check(Submission{Content: "héllo"}) != nil {
+		t.Error("MaxLength should count characters, not bytes")
+	}
+	if MaxLength(5).Check(Submission{Content: "toolong"}) == nil {
+		t.Error("MaxLength should reject long content")
+	}
+
+	content := "see https://a.example and www.b.example"
+	if MaxLinks(2).Check(Submission{Content: content}) != nil {
+		t.Error("MaxLinks(2) should allow two links")
+	}
+	if MaxLinks(1).Check(Submission{Content: content}) == nil {
+		t.Error("MaxLinks(1) should reject two links")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if reason := limiter.Check(Submission{Username: "alice"}); reason != nil {
+			t.Fatalf("Attempt %d rejected: %v", i+1, reason.Message)
+		}
+	}
+	if limiter.Check(Submission{Username: "alice"}) == nil {
+		t.Error("Third attempt within the window should be rejected")
+	}
+	if limiter.Check(Submission{Username: "bob"}) != nil {
+		t.Error("Limits should be tracked per user")
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if limiter.Check(Submission{Username: "alice"}) != nil {
+		t.Error("Attempts should be allowed again after the window")
+	}
+	if _, ok := limiter.attempts["bob"]; ok || len(limiter.attempts) != 1 {
+		t.Errorf("Users idle for longer than the window should be forgotten, got %v", limiter.attempts)
+	}
+}
+
+func TestChainCollectsAllReasons(t *testing.T) {
+	chain := NewDefaultChain(&Config{
+		BannedWords: []string{"spam"},
+		MaxLength:   10,
+		MaxLinks:    0,
+	})
+
+	err := chain.Check(Submission{Username: "alice", Content: "spam spam spam spam"})
+	var rejected *RejectedError
+	if !errors.As(err, &rejected) {
+		t.Fatalf("Expected *RejectedError, got %v", err)
+	}
+	if len(rejected.Reasons) != 2 {
+		t.Fatalf("Expected 2 reasons, got %+v", rejected.Reasons)
+	}
+	if rejected.RateLimited() {
+		t.Error("Rejection should not be reported as rate limited")
+	}
+	if !strings.Contains(rejected.Error(), "banned words") {
+		t.Errorf("Unexpected error message: %v", rejected.Error())
+	}
+
+	if err := chain.Check(Submission{Username: "alice", Content: "hi"}); err != nil {
+		t.Errorf("Expected clean content to pass, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package moderation
+
+import (
+	"fmt"
+	"lab03-backend/models"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// leetspeak maps common character substitutions back to letters
+var leetspeak = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'9': 'g',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'l',
+	'+': 't',
+}
+
+// Normalize lowercases text and undoes leetspeak substitutions, so
+// "B4D W0RD" becomes "bad word"
+func Normalize(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		if mapped, ok := leetspeak[r]; ok {
+			r = mapped
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// words splits normalized text into letter-only words
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+}
+
+// run is a letter and the number of times it is repeated
+type run struct {
+	letter rune
+	count  int
+}
+
+// runs splits a word into runs of repeated letters, so "baaad" becomes
+// b, aaa, d
+func runs(word string) []run {
+	var result []run
+	for _, r := range word {
+		if n := len(result); n > 0 && result[n-1].letter == r {
+			result[n-1].count++
+			continue
+		}
+		result = append(result, run{letter: r, count: 1})
+	}
+	return result
+}
+
+// collapse removes repeated letters, so "baaad" becomes "bad"
+func collapse(rs []run) string {
+	var b strings.Builder
+	for _, r := range rs {
+		b.WriteRune(r.letter)
+	}
+	return b.String()
+}
+
+// minStretch is how many times a letter must be repeated to count as
+// stretched. Doubled letters are common in ordinary words ("good" is not a
+// stretched "god"), tripled ones rarely are.
+const minStretch = 3
+
+// stretches reports whether token is banned stretched by repeating letters,
+// like "baaad" for "bad". Both have the same collapsed form; every run of
+// token must match banned's, or be stretched to at least as long.
+func stretches(token, banned []run) bool {
+	if len(token) != len(banned) {
+		return false
+	}
+	for i := range token {
+		if token[i].letter != banned[i].letter {
+			return false
+		}
+		if token[i].count != banned[i].count && (token[i].count < minStretch || token[i].count < banned[i].count) {
+			return false
+		}
+	}
+	return true
+}
+
+// BannedWords rejects content containing any of a list of words
+type BannedWords struct {
+	mutex sync.RWMutex
+	words map[string]bool
+	// collapsed indexes the runs of banned words by their collapsed form
+	collapsed map[string][][]run
+}
+
+// NewBannedWords creates the rule from a word list
+func NewBannedWords(list []string) *BannedWords {
+	b := &BannedWords{}
+	b.Set(list)
+	return b
+}
+
+// Set replaces the banned word list
+func (b *BannedWords) Set(list []string) {
+	banned := make(map[string]bool, len(list))
+	collapsed := make(map[string][][]run, len(list))
+	for _, word := range list {
+		for _, w := range words(Normalize(word)) {
+			if banned[w] {
+				continue
+			}
+			banned[w] = true
+			rs := runs(w)
+			key := collapse(rs)
+			collapsed[key] = append(collapsed[key], rs)
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.words = banned
+	b.collapsed = collapsed
+}
+
+// contains reports whether word is banned, as is or stretched
+func (b *BannedWords) contains(word string) bool {
+	if b.words[word] {
+		return true
+	}
+	rs := runs(word)
+	for _, banned := range b.collapsed[collapse(rs)] {
+		if stretches(rs, banned) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check implements Rule
+func (b *BannedWords) Check(s Submission) *models.RejectionReason {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for _, word := range words(Normalize(s.Content)) {
+		if b.contains(word) {
+			return &models.RejectionReason{
+				Rule:    RuleBannedWords,
+				Message: "content contains banned words",
+			}
+		}
+	}
+	return nil
+}
+
+// MaxLength rejects content longer than max characters
+func MaxLength(max int) Rule {
+	return RuleFunc(func(s Submission) *models.RejectionReason {
+		if length := utf8.RuneCountInString(s.Content); length > max {
+			return &models.RejectionReason{
+				Rule:    RuleMaxLength,
+				Message: fmt.Sprintf("content is %d characters long, the maximum is %d", length, max),
+			}
+		}
+		return nil
+	})
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// MaxLinks rejects content with more than max links
+func MaxLinks(max int) Rule {
+	return RuleFunc(func(s Submission) *models.RejectionReason {
+		if links := len(linkPattern.FindAllString(s.Content, -1)); links > max {
+			return &models.RejectionReason{
+				Rule:    RuleMaxLinks,
+				Message: fmt.Sprintf("content has %d links, the maximum is %d", links, max),
+			}
+		}
+		return nil
+	})
+}
+
+// RateLimiter rejects users submitting more than limit messages per window.
+// Submissions rejected by earlier rules in the chain still count as attempts.
+// Users idle for longer than the window are forgotten.
+type RateLimiter struct {
+	mutex     sync.Mutex
+	limit     int
+	window    time.Duration
+	attempts  map[string][]time.Time
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a sliding window rate limiter
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string][]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Check implements Rule
+func (l *RateLimiter) Check(s Submission) *models.RejectionReason {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	cutoff := now.Add(-l.window)
+	if now.Sub(l.lastPrune) >= l.window {
+		l.prune(cutoff)
+		l.lastPrune = now
+	}
+
+	recent := l.attempts[s.Username][:0]
+	for _, t := range l.attempts[s.Username] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= l.limit {
+		l.attempts[s.Username] = recent
+		retryAfter := recent[0].Add(l.window).Sub(now).Round(time.Second)
+		return &models.RejectionReason{
+			Rule:    RuleRateLimit,
+			Message: fmt.Sprintf("rate limit of %d messages per %s exceeded, retry in %s", l.limit, l.window, retryAfter),
+		}
+	}
+
+	l.attempts[s.Username] = append(recent, now)
+	return nil
+}
+
+// prune drops the users whose last attempt is not after cutoff
+func (l *RateLimiter) prune(cutoff time.Time) {
+	for username, attempts := range l.attempts {
+		if len(attempts) == 0 || !attempts[len(attempts)-1].After(cutoff) {
+			delete(l.attempts, username)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package statuscatalog
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"html"
+	"sort"
+	"text/template"
+)
+
+// Status categories as named by RFC 9110, Section 15
+const (
+	CategoryInformational = "Informational"
+	CategorySuccessful    = "Successful"
+	CategoryRedirection   = "Redirection"
+	CategoryClientError   = "Client Error"
+	CategoryServerError   = "Server Error"
+)
+
+// Status describes one IANA-registered HTTP status code
+type Status struct {
+	Code      int    `json:"code"`
+	Reason    string `json:"reason"`
+	Category  string `json:"category"`
+	Reference string `json:"reference"`
+}
+
+//go:embed catalog.json
+var catalogJSON []byte
+
+//go:embed image.svg.tmpl
+var imageSource string
+
+var (
+	statuses []Status
+	byCode   map[int]Status
+
+	imageTemplate = template.Must(template.New("image").Parse(imageSource))
+)
+
+func init() {
+	if err := json.Unmarshal(catalogJSON, &statuses); err != nil {
+		panic("statuscatalog: invalid catalog.json: " + err.Error())
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Code < statuses[j].Code
+	})
+
+	byCode = make(map[int]Status, len(statuses))
+	for i := range statuses {
+		statuses[i].Category = CategoryOf(statuses[i].Code)
+		byCode[statuses[i].Code] = statuses[i]
+	}
+}
+
+// All returns every registered status, ordered by code
+func All() []Status {
+	result := make([]Status, len(statuses))
+	copy(result, statuses)
+	return result
+}
+
+// Lookup returns the registered status for code
+func Lookup(code int) (Status, bool) {
+	status, ok := byCode[code]
+	return status, ok
+}
+
+// CategoryOf returns the category a status code belongs to, or an empty
+// string for codes outside 100-599
+func CategoryOf(code int) string {
+	switch code / 100 {
+	case 1:
+		return CategoryInformational
+	case 2:
+		return CategorySuccessful
+	case 3:
+		return CategoryRedirection
+	case 4:
+		return CategoryClientError
+	case 5:
+		return CategoryServerError
+	default:
+		return ""
+	}
+}
+
+// Image renders the SVG image for a registered status code
+func Image(code int) ([]byte, bool) {
+	status, ok := Lookup(code)
+	if !ok {
+		return nil, false
+	}
+
+	var buf bytes.Buffer
+	err := imageTemplate.Execute(&buf, struct {
+		Code       int
+		Reason     string
+		Background string
+	}{
+		Code:       status.Code,
+		Reason:     html.EscapeString(status.Reason),
+		Background: backgrounds[status.Category],
+	})
+	if err != nil {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+var backgrounds = map[string]string{
+	CategoryInformational: "#3b82f6",
+	CategorySuccessful:    "#16a34a",
+	CategoryRedirection:   "#9333ea",
+	CategoryClientError:   "#ea580c",
+	CategoryServerError:   "#dc2626",
+}
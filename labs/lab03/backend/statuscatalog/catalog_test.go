@@ -0,0 +1,63 @@
+package statuscatalog
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		code     int
+		reason   string
+		category string
+	}{
+		{200, "OK", CategorySuccessful},
+		{304, "Not Modified", CategoryRedirection},
+		{404, "Not Found", CategoryClientError},
+		{422, "Unprocessable Content", CategoryClientError},
+		{503, "Service Unavailable", CategoryServerError},
+	}
+
+	for _, tt := range tests {
+		status, ok := Lookup(tt.code)
+		if !ok {
+			t.Errorf("Lookup(%d) not found", tt.code)
+			continue
+		}
+		if status.Reason != tt.reason || status.Category != tt.category || status.Reference == "" {
+			t.Errorf("Lookup(%d) = %+v", tt.code, status)
+		}
+	}
+
+	// 299 is unassigned; 306 and 418 are reserved by IANA as "(Unused)"
+	for _, code := range []int{299, 306, 418} {
+		if _, ok := Lookup(code); ok {
+			t.Errorf("Lookup(%d) should not find an unregistered code", code)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	statuses := All()
+	if len(statuses) < 60 {
+		t.Fatalf("Expected the full IANA registry, got %d entries", len(statuses))
+	}
+	for i := 1; i < len(statuses); i++ {
+		if statuses[i-1].Code >= statuses[i].Code {
+			t.Fatalf("Statuses not sorted at %d: %d >= %d", i, statuses[i-1].Code, statuses[i].Code)
+		}
+	}
+}
+
+func TestImage(t *testing.T) {
+	image, ok := Image(451)
+	if !ok {
+		t.Fatal("Image(451) not found")
+	}
+	if !bytes.HasPrefix(image, []byte("<svg")) || !bytes.Contains(image, []byte("451")) {
+		t.Errorf("Unexpected image: %s", image)
+	}
+	if _, ok := Image(999); ok {
+		t.Error("Image(999) should not exist")
+	}
+}
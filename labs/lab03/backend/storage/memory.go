@@ -3,83 +3,219 @@ package storage
 import (
 	"errors"
 	"lab03-backend/models"
+	"sort"
+	"sync"
+	"time"
 )
 
-// MemoryStorage implements in-memory storage for messages
+// MemoryStorage implements in-memory storage for messages.
+// Deleted messages are kept as tombstones and every content change is kept
+// as a revision, so moderators can audit and restore them.
 type MemoryStorage struct {
-	// TODO: Add mutex field for thread safety (sync.RWMutex)
-	// TODO: Add messages field as map[int]*models.Message
-	// TODO: Add nextID field of type int for auto-incrementing IDs
+	mutex     sync.RWMutex
+	messages  map[int]*models.Message
+	revisions map[int][]models.Revision
+	nextID    int
 }
 
 // NewMemoryStorage creates a new in-memory storage instance
 func NewMemoryStorage() *MemoryStorage {
-	// TODO: Return a new MemoryStorage instance with initialized fields
-	// Initialize messages as empty map
-	// Set nextID to 1
-	return nil
+	return &MemoryStorage{
+		messages:  make(map[int]*models.Message),
+		revisions: make(map[int][]models.Revision),
+		nextID:    1,
+	}
 }
 
-// GetAll returns all messages
+// GetAll returns all messages that have not been deleted
 func (ms *MemoryStorage) GetAll() []*models.Message {
-	// TODO: Implement GetAll method
-	// Use read lock for thread safety
-	// Convert map values to slice
-	// Return slice of all messages
-	return nil
+	return ms.list(func(msg *models.Message) bool { return !msg.IsDeleted() })
+}
+
+// GetDeleted returns all soft deleted messages
+func (ms *MemoryStorage) GetDeleted() []*models.Message {
+	return ms.list(func(msg *models.Message) bool { return msg.IsDeleted() })
+}
+
+// list returns copies of the messages accepted by keep, ordered by ID
+func (ms *MemoryStorage) list(keep func(*models.Message) bool) []*models.Message {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	messages := make([]*models.Message, 0, len(ms.messages))
+	for _, msg := range ms.messages {
+		if !keep(msg) {
+			continue
+		}
+		copied := *msg
+		messages = append(messages, &copied)
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+	return messages
 }
 
 // GetByID returns a message by its ID
 func (ms *MemoryStorage) GetByID(id int) (*models.Message, error) {
-	// TODO: Implement GetByID method
-	// Use read lock for thread safety
-	// Check if message exists in map
-	// Return message or error if not found
-	return nil, nil
+	if id <= 0 {
+		return nil, ErrInvalidID
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	msg, ok := ms.messages[id]
+	if !ok || msg.IsDeleted() {
+		return nil, ErrMessageNotFound
+	}
+	copied := *msg
+	return &copied, nil
+}
+
+// GetRevisions returns every stored revision of a message, oldest first.
+// Revisions of deleted messages remain available.
+func (ms *MemoryStorage) GetRevisions(id int) ([]models.Revision, error) {
+	if id <= 0 {
+		return nil, ErrInvalidID
+	}
+
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	if _, ok := ms.messages[id]; !ok {
+		return nil, ErrMessageNotFound
+	}
+	revisions := make([]models.Revision, len(ms.revisions[id]))
+	copy(revisions, ms.revisions[id])
+	return revisions, nil
 }
 
 // Create adds a new message to storage
 func (ms *MemoryStorage) Create(username, content string) (*models.Message, error) {
-	// TODO: Implement Create method
-	// Use write lock for thread safety
-	// Get next available ID
-	// Create new message using models.NewMessage
-	// Add message to map
-	// Increment nextID
-	// Return created message
-	return nil, nil
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	msg := models.NewMessage(ms.nextID, username, content)
+	ms.messages[msg.ID] = msg
+	ms.revisions[msg.ID] = []models.Revision{msg.Revision(models.RevisionCreated, username)}
+	ms.nextID++
+
+	copied := *msg
+	return &copied, nil
 }
 
-// Update modifies an existing message
-func (ms *MemoryStorage) Update(id int, content string) (*models.Message, error) {
-	// TODO: Implement Update method
-	// Use write lock for thread safety
-	// Check if message exists
-	// Update the content field
-	// Return updated message or error if not found
-	return nil, nil
+// Update modifies an existing message on behalf of editor
+func (ms *MemoryStorage) Update(id int, content, editor string) (*models.Message, error) {
+	return ms.UpdateIfVersion(id, content, 0, editor)
 }
 
-// Delete removes a message from storage
-func (ms *MemoryStorage) Delete(id int) error {
-	// TODO: Implement Delete method
-	// Use write lock for thread safety
-	// Check if message exists
-	// Delete from map
-	// Return error if message not found
+// UpdateIfVersion modifies an existing message on behalf of editor only if
+// its current version equals version. A version of 0 skips the check.
+func (ms *MemoryStorage) UpdateIfVersion(id int, content string, version int, editor string) (*models.Message, error) {
+	if id <= 0 {
+		return nil, ErrInvalidID
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	msg, ok := ms.messages[id]
+	if !ok || msg.IsDeleted() {
+		return nil, ErrMessageNotFound
+	}
+	if version != 0 && msg.Version != version {
+		return nil, ErrVersionMismatch
+	}
+
+	msg.Content = content
+	msg.UpdatedAt = time.Now()
+	msg.Version++
+	ms.revisions[id] = append(ms.revisions[id], msg.Revision(models.RevisionEdited, editor))
+
+	copied := *msg
+	return &copied, nil
+}
+
+// Delete soft deletes a message on behalf of editor, leaving a tombstone
+// that can be restored
+func (ms *MemoryStorage) Delete(id int, editor string) error {
+	return ms.DeleteIfVersion(id, 0, editor)
+}
+
+// DeleteIfVersion soft deletes a message on behalf of editor only if its
+// current version equals version. A version of 0 skips the check. The delete
+// is a new version, recorded as a revision.
+func (ms *MemoryStorage) DeleteIfVersion(id int, version int, editor string) error {
+	if id <= 0 {
+		return ErrInvalidID
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	msg, ok := ms.messages[id]
+	if !ok || msg.IsDeleted() {
+		return ErrMessageNotFound
+	}
+	if version != 0 && msg.Version != version {
+		return ErrVersionMismatch
+	}
+
+	now := time.Now()
+	msg.DeletedAt = &now
+	msg.UpdatedAt = now
+	msg.Version++
+	ms.revisions[id] = append(ms.revisions[id], msg.Revision(models.RevisionDeleted, editor))
 	return nil
 }
 
-// Count returns the total number of messages
+// Restore brings back a soft deleted message with its last content on
+// behalf of editor. The restore is a new version, so ETags taken before it
+// no longer match.
+func (ms *MemoryStorage) Restore(id int, editor string) (*models.Message, error) {
+	if id <= 0 {
+		return nil, ErrInvalidID
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	msg, ok := ms.messages[id]
+	if !ok {
+		return nil, ErrMessageNotFound
+	}
+	if !msg.IsDeleted() {
+		return nil, ErrMessageNotDeleted
+	}
+
+	msg.DeletedAt = nil
+	msg.UpdatedAt = time.Now()
+	msg.Version++
+	ms.revisions[id] = append(ms.revisions[id], msg.Revision(models.RevisionRestored, editor))
+
+	copied := *msg
+	return &copied, nil
+}
+
+// Count returns the total number of messages that have not been deleted
 func (ms *MemoryStorage) Count() int {
-	// TODO: Implement Count method
-	// Use read lock for thread safety
-	// Return length of messages map
-	return 0
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	count := 0
+	for _, msg := range ms.messages {
+		if !msg.IsDeleted() {
+			count++
+		}
+	}
+	return count
 }
 
 // Common errors
 var (
-	ErrMessageNotFound = errors.New("message not found")
-	ErrInvalidID       = errors.New("invalid message ID")
+	ErrMessageNotFound   = errors.New("message not found")
+	ErrInvalidID         = errors.New("invalid message ID")
+	ErrVersionMismatch   = errors.New("message version mismatch")
+	ErrMessageNotDeleted = errors.New("message is not deleted")
 )
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"lab03-backend/models"
 	"testing"
 )
 
@@ -47,7 +48,7 @@ func TestMemoryStorageCRUD(t *testing.T) {
 	}
 
 	// Test Update
-	updated, err := storage.Update(1, "updated content")
+	updated, err := storage.Update(1, "updated content", "user")
 	if err != nil {
 		t.Fatalf("Update failed: %v", err)
 	}
@@ -57,7 +58,7 @@ func TestMemoryStorageCRUD(t *testing.T) {
 	}
 
 	// Test Delete
-	err = storage.Delete(1)
+	err = storage.Delete(1, "user")
 	if err != nil {
 		t.Fatalf("Delete failed: %v", err)
 	}
@@ -79,13 +80,13 @@ func TestMemoryStorageErrors(t *testing.T) {
 	}
 
 	// Test Update with non-existent ID
-	_, err = storage.Update(999, "content")
+	_, err = storage.Update(999, "content", "user")
 	if err == nil {
 		t.Error("Expected error for updating non-existent message")
 	}
 
 	// Test Delete with non-existent ID
-	err = storage.Delete(999)
+	err = storage.Delete(999, "user")
 	if err == nil {
 		t.Error("Expected error for deleting non-existent message")
 	}
@@ -116,3 +117,99 @@ func TestMemoryStorageConcurrency(t *testing.T) {
 		t.Errorf("Expected 10 messages after concurrent writes, got %d", count)
 	}
 }
+
+func TestMemoryStorageVersioning(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	message, _ := storage.Create("user", "content")
+	if message.Version != 1 {
+		t.Fatalf("Expected version 1 after create, got %d", message.Version)
+	}
+
+	updated, err := storage.UpdateIfVersion(message.ID, "edited", 1, "user")
+	if err != nil {
+		t.Fatalf("UpdateIfVersion failed: %v", err)
+	}
+	if updated.Version != 2 {
+		t.Errorf("Expected version 2 after update, got %d", updated.Version)
+	}
+
+	if _, err := storage.UpdateIfVersion(message.ID, "stale", 1, "user"); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+
+	if err := storage.DeleteIfVersion(message.ID, 1, "user"); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+
+	if err := storage.DeleteIfVersion(message.ID, 2, "user"); err != nil {
+		t.Errorf("DeleteIfVersion failed: %v", err)
+	}
+}
+
+func TestMemoryStorageRevisionsAndRestore(t *testing.T) {
+	storage := NewMemoryStorage()
+
+	message, _ := storage.Create("user", "first")
+	storage.Update(message.ID, "second", "user")
+	storage.Update(message.ID, "third", "user")
+
+	revisions, err := storage.GetRevisions(message.ID)
+	if err != nil {
+		t.Fatalf("GetRevisions failed: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions, got %d", len(revisions))
+	}
+	for i, want := range []string{"first", "second", "third"} {
+		if revisions[i].Content != want || revisions[i].Version != i+1 {
+			t.Errorf("Revision %d = %+v, want content %q version %d", i, revisions[i], want, i+1)
+		}
+	}
+
+	if err := storage.Delete(message.ID, "moderator"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := storage.GetByID(message.ID); err != ErrMessageNotFound {
+		t.Errorf("Expected ErrMessageNotFound for deleted message, got %v", err)
+	}
+	if deleted := storage.GetDeleted(); len(deleted) != 1 || deleted[0].DeletedAt == nil {
+		t.Errorf("Expected one tombstone, got %+v", deleted)
+	}
+	revisions, err = storage.GetRevisions(message.ID)
+	if err != nil {
+		t.Fatalf("Revisions should survive deletion, got %v", err)
+	}
+	if last := revisions[len(revisions)-1]; last.Action != models.RevisionDeleted || last.EditedBy != "moderator" ||
+		last.Version != 4 || last.EditedAt.IsZero() {
+		t.Errorf("Unexpected delete revision: %+v", last)
+	}
+
+	restored, err := storage.Restore(message.ID, "admin")
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if restored.Content != "third" || restored.DeletedAt != nil || restored.Version != 5 {
+		t.Errorf("Unexpected restored message: %+v", restored)
+	}
+	// A client holding the ETag from before the delete cannot overwrite the restore
+	if _, err := storage.UpdateIfVersion(message.ID, "stale", 4, "user"); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch after restore, got %v", err)
+	}
+	revisions, _ = storage.GetRevisions(message.ID)
+	if len(revisions) != 5 {
+		t.Fatalf("Expected 5 revisions after restore, got %d", len(revisions))
+	}
+	if last := revisions[4]; last.Action != models.RevisionRestored || last.EditedBy != "admin" || last.Version != 5 {
+		t.Errorf("Unexpected restore revision: %+v", last)
+	}
+	if first := revisions[0]; first.Action != models.RevisionCreated || first.EditedBy != "user" {
+		t.Errorf("Unexpected first revision: %+v", first)
+	}
+	if storage.Count() != 1 {
+		t.Errorf("Expected 1 message after restore, got %d", storage.Count())
+	}
+	if _, err := storage.Restore(message.ID, "admin"); err != ErrMessageNotDeleted {
+		t.Errorf("Expected ErrMessageNotDeleted, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Package audit records who changed which user, post, comment or category
+// and how. Entries are written to the audit_log table in the same
+// transaction as the change they describe.
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"lab04-backend/database"
+
+	"gorm.io/gorm"
+)
+
+// Action is the kind of change an entry records
+type Action string
+
+const (
+	ActionCreate  Action = "create"
+	ActionUpdate  Action = "update"
+	ActionDelete  Action = "delete"
+	ActionRestore Action = "restore"
+	ActionPurge   Action = "purge"
+)
+
+// Entity types recorded in the audit log
+const (
+	EntityUser     = "user"
+	EntityPost     = "post"
+	EntityComment  = "comment" // Only recorded when purged along with its post or author
+	EntityCategory = "category"
+)
+
+// SystemActor is recorded when the context does not name an actor
+const SystemActor = "system"
+
+type actorKey struct{}
+
+// WithActor returns a context whose changes are recorded as made by actor,
+// e.g. the email of the signed in user
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor set by WithActor, or SystemActor
+func ActorFrom(ctx context.Context) string {
+	if ctx != nil {
+		if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+			return actor
+		}
+	}
+	return SystemActor
+}
+
+// Change is the old and new value of one field. Old is nil for created
+// fields and New is nil for removed ones.
+type Change struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// Changes maps JSON field names to their change. It is stored as a JSON
+// object.
+type Changes map[string]Change
+
+// Value implements driver.Valuer
+func (c Changes) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner
+func (c *Changes) Scan(src interface{}) error {
+	var b []byte
+	switch src := src.(type) {
+	case nil:
+		*c = nil
+		return nil
+	case string:
+		b = []byte(src)
+	case []byte:
+		b = src
+	default:
+		return fmt.Errorf("cannot scan %T into audit.Changes", src)
+	}
+	return json.Unmarshal(b, c)
+}
+
+// Entry is one row of the audit log
+type Entry struct {
+	ID         int64     `json:"id" db:"id"`
+	Actor      string    `json:"actor" db:"actor"`
+	EntityType string    `json:"entity_type" db:"entity_type"`
+	EntityID   int64     `json:"entity_id" db:"entity_id"`
+	Action     Action    `json:"action" db:"action"`
+	Changes    Changes   `json:"changes" db:"changes"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
+// ignoredFields change on every write and are left out of diffs
+var ignoredFields = map[string]bool{"created_at": true, "updated_at": true}
+
+// Diff compares the JSON forms of before and after, either of which may be
+// nil, and returns the fields that differ
+func Diff(before, after interface{}) (Changes, error) {
+	old, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := Changes{}
+	for name, value := range updated {
+		if !ignoredFields[name] && !reflect.DeepEqual(old[name], value) {
+			changes[name] = Change{Old: old[name], New: value}
+		}
+	}
+	for name, value := range old {
+		if _, ok := updated[name]; !ok && !ignoredFields[name] {
+			changes[name] = Change{Old: value}
+		}
+	}
+	return changes, nil
+}
+
+// fields returns the JSON object form of v
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || reflect.ValueOf(v).Kind() == reflect.Pointer && reflect.ValueOf(v).IsNil() {
+		return map[string]interface{}{}, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %T: %w", v, err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to diff %T: %w", v, err)
+	}
+	return m, nil
+}
+
+// NewEntry builds an entry for a change made by the actor of ctx, with the
+// diff between before and after
+func NewEntry(ctx context.Context, entityType string, entityID int64, action Action, before, after interface{}) (Entry, error) {
+	changes, err := Diff(before, after)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{
+		Actor:      ActorFrom(ctx),
+		EntityType: entityType,
+		EntityID:   entityID,
+		Action:     action,
+		Changes:    changes,
+		CreatedAt:  time.Now().UTC(),
+	}, nil
+}
+
+const insertEntry = `
+	INSERT INTO audit_log (actor, entity_type, entity_id, action, changes, created_at)
+	VALUES (?, ?, ?, ?, ?, ?)`
+
+func (e Entry) args() []interface{} {
+	return []interface{}{e.Actor, e.EntityType, e.EntityID, e.Action, e.Changes, e.CreatedAt}
+}
+
+// Record writes entries with db, which should be the transaction making the
+// change
+func Record(ctx context.Context, db database.DBTX, entries ...Entry) error {
+	query := database.DialectOf(db).Rebind(insertEntry)
+	for _, entry := range entries {
+		if _, err := db.ExecContext(ctx, query, entry.args()...); err != nil {
+			return fmt.Errorf("failed to record audit entry: %w", err)
+		}
+	}
+	return nil
+}
+
+// RecordGORM writes entries with the GORM handle of a hook or transaction
+func RecordGORM(tx *gorm.DB, entries ...Entry) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	for _, entry := range entries {
+		if err := db.Exec(insertEntry, entry.args()...).Error; err != nil {
+			return fmt.Errorf("failed to record audit entry: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type record struct {
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Count     int       `json:"count"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after interface{}
+		want          Changes
+	}{
+		{
+			name:  "create",
+			after: &record{Name: "Alice", Count: 1},
+			want:  Changes{"name": {New: "Alice"}, "count": {New: 1.0}},
+		},
+		{
+			name:   "update",
+			before: &record{Name: "Alice", Email: "a@example.com", Count: 1, UpdatedAt: time.Now()},
+			after:  &record{Name: "Bob", Count: 1},
+			want:   Changes{"name": {Old: "Alice", New: "Bob"}, "email": {Old: "a@example.com"}},
+		},
+		{
+			name:   "unchanged",
+			before: record{Name: "Alice"},
+			after:  record{Name: "Alice", UpdatedAt: time.Now()},
+			want:   Changes{},
+		},
+		{
+			name:   "typed nil",
+			before: (*record)(nil),
+			want:   Changes{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChanges_ValueScan(t *testing.T) {
+	changes := Changes{"name": {Old: "Alice", New: "Bob"}}
+	value, err := changes.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+
+	var scanned Changes
+	if err := scanned.Scan(value); err != nil {
+		t.Fatalf("Scan() error = %v", err)
+	}
+	if !reflect.DeepEqual(scanned, changes) {
+		t.Errorf("Scan() = %v, want %v", scanned, changes)
+	}
+}
+
+func TestActorFrom(t *testing.T) {
+	if got := ActorFrom(context.Background()); got != SystemActor {
+		t.Errorf("ActorFrom() = %q, want %q", got, SystemActor)
+	}
+	if got := ActorFrom(WithActor(context.Background(), "alice@example.com")); got != "alice@example.com" {
+		t.Errorf("ActorFrom() = %q, want alice@example.com", got)
+	}
+}
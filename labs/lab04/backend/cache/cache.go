@@ -0,0 +1,62 @@
+// Package cache provides the key/value caches behind the read-through
+// repository decorators: an in-process LRU with TTL and a client for any
+// server speaking the Redis protocol.
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// ErrMiss is returned by Get when the key is not cached or has expired
+var ErrMiss = errors.New("cache: miss")
+
+// Cache stores byte values by key. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	// Get returns the value of key or ErrMiss
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Set stores value under key. A ttl <= 0 keeps the value until it is
+	// deleted or evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes keys; missing keys are ignored
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Stats is a snapshot of cache metrics. Lookups that failed with a cache
+// error count as misses and as errors.
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// HitRatio returns the share of lookups served from the cache, or 0 before
+// the first lookup
+func (s Stats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
+
+// Metrics counts hits, misses and errors. The zero value is ready to use.
+type Metrics struct {
+	hits, misses, errors atomic.Uint64
+}
+
+// Hit records a lookup served from the cache
+func (m *Metrics) Hit() { m.hits.Add(1) }
+
+// Miss records a lookup that had to go to the database
+func (m *Metrics) Miss() { m.misses.Add(1) }
+
+// Error records a failed cache operation
+func (m *Metrics) Error() { m.errors.Add(1) }
+
+// Stats returns the current counts
+func (m *Metrics) Stats() Stats {
+	return Stats{Hits: m.hits.Load(), Misses: m.misses.Load(), Errors: m.errors.Load()}
+}
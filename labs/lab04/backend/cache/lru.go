@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// LRU is an in-process Cache holding at most capacity entries. Adding to a
+// full cache evicts the least recently used entry; expired entries are
+// removed when they are next looked up or evicted.
+type LRU struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // front is the most recently used
+	items    map[string]*list.Element
+	now      func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero when the entry does not expire
+}
+
+// NewLRU creates an LRU holding at most capacity entries (at least 1)
+func NewLRU(capacity int) *LRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LRU{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+		now:      time.Now,
+	}
+}
+
+// Get implements Cache
+func (c *LRU) Get(_ context.Context, key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	entry := elem.Value.(*lruEntry)
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		c.remove(elem)
+		return nil, ErrMiss
+	}
+	c.order.MoveToFront(elem)
+	return append([]byte(nil), entry.value...), nil
+}
+
+// Set implements Cache
+func (c *LRU) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &lruEntry{key: key, value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		entry.expires = c.now().Add(ttl)
+	}
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return nil
+	}
+	c.items[key] = c.order.PushFront(entry)
+	if c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+// Delete implements Cache
+func (c *LRU) Delete(_ context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := c.items[key]; ok {
+			c.remove(elem)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of entries, including expired ones not yet removed
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.items, elem.Value.(*lruEntry).key)
+}
@@ -0,0 +1,79 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), 0)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+
+	t.Run("Get", func(t *testing.T) {
+		value, err := c.Get(ctx, "a")
+		if err != nil || string(value) != "1" {
+			t.Fatalf("Get(a) = %q, %v; want 1", value, err)
+		}
+		value[0] = 'x'
+		if value, _ := c.Get(ctx, "a"); string(value) != "1" {
+			t.Errorf("changing a returned value changed the cache to %q", value)
+		}
+		if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(missing) error = %v, want ErrMiss", err)
+		}
+	})
+
+	t.Run("Evict", func(t *testing.T) {
+		// a was used last, so adding c evicts b
+		c.Set(ctx, "c", []byte("3"), 0)
+		if _, err := c.Get(ctx, "b"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(b) error = %v, want the least recently used entry evicted", err)
+		}
+		if _, err := c.Get(ctx, "a"); err != nil {
+			t.Errorf("Get(a) error = %v, want it kept", err)
+		}
+		if c.Len() != 2 {
+			t.Errorf("Len() = %d, want 2", c.Len())
+		}
+	})
+
+	t.Run("Expire", func(t *testing.T) {
+		c.Set(ctx, "c", []byte("3"), time.Minute)
+		now = now.Add(time.Minute)
+		if _, err := c.Get(ctx, "c"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(c) error = %v, want expired", err)
+		}
+		if _, err := c.Get(ctx, "a"); err != nil {
+			t.Errorf("Get(a) error = %v, want entries without a TTL kept", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		c.Delete(ctx, "a", "missing")
+		if _, err := c.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(a) error = %v, want deleted", err)
+		}
+	})
+}
+
+func TestMetrics(t *testing.T) {
+	var m Metrics
+	if ratio := m.Stats().HitRatio(); ratio != 0 {
+		t.Errorf("HitRatio() = %v, want 0 before any lookup", ratio)
+	}
+	m.Hit()
+	m.Hit()
+	m.Hit()
+	m.Miss()
+	m.Error()
+	if stats := m.Stats(); stats != (Stats{Hits: 3, Misses: 1, Errors: 1}) || stats.HitRatio() != 0.75 {
+		t.Errorf("Stats() = %+v with ratio %v, want 3 hits, 1 miss, 1 error", stats, stats.HitRatio())
+	}
+}
@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxIdleRedisConns is the number of idle connections a Redis client keeps
+const maxIdleRedisConns = 4
+
+// DefaultRedisTimeout bounds a command, dial included, when its context has
+// no deadline
+const DefaultRedisTimeout = time.Second
+
+// Redis is a Cache backed by a server speaking the Redis protocol (RESP),
+// such as Redis, Valkey or KeyDB. It only uses GET, SET with PX and DEL.
+type Redis struct {
+	// Timeout bounds commands whose context has no deadline, so that an
+	// unresponsive server cannot block a request forever
+	Timeout time.Duration
+
+	addr   string
+	prefix string
+	dialer net.Dialer
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+}
+
+// RedisError is an error reply from the server
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// NewRedis creates a client for the server at addr ("host:port"). Every key
+// is prefixed with prefix, so several applications can share one server.
+// Connections are opened on first use.
+func NewRedis(addr, prefix string) *Redis {
+	return &Redis{Timeout: DefaultRedisTimeout, addr: addr, prefix: prefix}
+}
+
+// Get implements Cache
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, error) {
+	reply, err := c.do(ctx, "GET", c.prefix+key)
+	if err != nil {
+		return nil, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrMiss
+	}
+	return value, nil
+}
+
+// Set implements Cache
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", c.prefix + key, string(value)}
+	if ttl > 0 {
+		// PX 0 is rejected, so sub-millisecond ttls keep the entry for 1ms
+		ttl = max(ttl, time.Millisecond)
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// Delete implements Cache
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, c.prefix+key)
+	}
+	_, err := c.do(ctx, args...)
+	return err
+}
+
+// Ping checks that the server is reachable
+func (c *Redis) Ping(ctx context.Context) error {
+	_, err := c.do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections. Commands fail after Close.
+func (c *Redis) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	var err error
+	for _, conn := range c.idle {
+		err = errors.Join(err, conn.Close())
+	}
+	c.idle = nil
+	return err
+}
+
+// do sends one command and reads its reply: nil, a string, an int64 or a
+// []byte. Error replies are returned as RedisError.
+func (c *Redis) do(ctx context.Context, args ...string) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok && c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	conn, err := c.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	reply, err := conn.roundTrip(args)
+	var redisErr RedisError
+	if err != nil && !errors.As(err, &redisErr) {
+		// The connection is in an unknown state after an I/O error
+		conn.Close()
+		return nil, fmt.Errorf("redis %s: %w", args[0], err)
+	}
+	c.release(conn)
+	return reply, err
+}
+
+// conn returns an idle connection or dials a new one
+func (c *Redis) conn(ctx context.Context) (*redisConn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("redis: client is closed")
+	}
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+
+	conn, err := c.dialer.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	return &redisConn{Conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}, nil
+}
+
+// release returns conn to the idle pool, or closes it when the pool is full
+func (c *Redis) release(conn *redisConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= maxIdleRedisConns {
+		conn.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// roundTrip writes args as a RESP array of bulk strings and reads the reply
+func (conn *redisConn) roundTrip(args []string) (interface{}, error) {
+	fmt.Fprintf(conn.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(conn.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := conn.w.Flush(); err != nil {
+		return nil, err
+	}
+	return conn.readReply()
+}
+
+func (conn *redisConn) readReply() (interface{}, error) {
+	line, err := conn.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, RedisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("malformed bulk length %q", body)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(conn.r, value); err != nil {
+			return nil, err
+		}
+		return value[:size], nil
+	default:
+		return nil, fmt.Errorf("unsupported reply type %q", kind)
+	}
+}
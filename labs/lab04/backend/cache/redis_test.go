@@ -0,0 +1,233 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is a local stand-in for a Redis server supporting PING, GET,
+// SET with PX, and DEL
+type fakeRedis struct {
+	mu      sync.Mutex
+	last    []string
+	values  map[string]string
+	expires map[string]time.Time
+}
+
+// startFakeRedis serves the stand-in on a random local port until the test ends
+func startFakeRedis(t *testing.T) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on localhost: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &fakeRedis{values: map[string]string{}, expires: map[string]time.Time{}}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s, ln.Addr().String()
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		fmt.Fprint(conn, s.exec(args))
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+	for i := range args {
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (s *fakeRedis) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.last = args
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, ok := s.values[args[1]]
+		if expires, ok := s.expires[args[1]]; ok && !time.Now().Before(expires) {
+			return "$-1\r\n"
+		}
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		s.values[args[1]] = args[2]
+		delete(s.expires, args[1])
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			ms, _ := strconv.Atoi(args[4])
+			s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "+OK\r\n"
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				deleted++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", deleted)
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func TestRedis(t *testing.T) {
+	server, addr := startFakeRedis(t)
+	c := NewRedis(addr, "test:")
+	defer c.Close()
+	ctx := context.Background()
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	t.Run("GetSet", func(t *testing.T) {
+		value := []byte("line one\r\nline two")
+		if err := c.Set(ctx, "a", value, 0); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		got, err := c.Get(ctx, "a")
+		if err != nil || string(got) != string(value) {
+			t.Errorf("Get(a) = %q, %v; want %q", got, err, value)
+		}
+		server.mu.Lock()
+		_, ok := server.values["test:a"]
+		server.mu.Unlock()
+		if !ok {
+			t.Error("Set() did not prefix the key")
+		}
+		if _, err := c.Get(ctx, "missing"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(missing) error = %v, want ErrMiss", err)
+		}
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		if err := c.Set(ctx, "short", []byte("1"), 10*time.Millisecond); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if _, err := c.Get(ctx, "short"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(short) error = %v, want expired", err)
+		}
+
+		// PX 0 is an error on a real server
+		if err := c.Set(ctx, "shorter", []byte("1"), 500*time.Microsecond); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		server.mu.Lock()
+		last := server.last
+		server.mu.Unlock()
+		if got := strings.Join(last[3:], " "); got != "PX 1" {
+			t.Errorf("Set() with a sub-millisecond ttl sent %q, want PX 1", got)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := c.Delete(ctx, "a", "missing"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if _, err := c.Get(ctx, "a"); !errors.Is(err, ErrMiss) {
+			t.Errorf("Get(a) error = %v, want deleted", err)
+		}
+	})
+
+	t.Run("ErrorReply", func(t *testing.T) {
+		var redisErr RedisError
+		if _, err := c.do(ctx, "FLUSHALL"); !errors.As(err, &redisErr) {
+			t.Errorf("do(FLUSHALL) error = %v, want a RedisError", err)
+		}
+		if err := c.Ping(ctx); err != nil {
+			t.Errorf("Ping() after an error reply error = %v", err)
+		}
+	})
+}
+
+func TestRedis_Unavailable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on localhost: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := NewRedis(addr, "")
+	if _, err := c.Get(context.Background(), "a"); err == nil || errors.Is(err, ErrMiss) {
+		t.Errorf("Get() without a server error = %v, want a connection error", err)
+	}
+}
+
+func TestRedis_Timeout(t *testing.T) {
+	// A server that accepts connections and never replies
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("cannot listen on localhost: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	c := NewRedis(ln.Addr().String(), "")
+	c.Timeout = 50 * time.Millisecond
+	defer c.Close()
+
+	start := time.Now()
+	if _, err := c.Get(context.Background(), "a"); err == nil || errors.Is(err, ErrMiss) {
+		t.Errorf("Get() from an unresponsive server error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Get() took %v, want it bounded by Timeout", elapsed)
+	}
+}
@@ -0,0 +1,57 @@
+// Command migrate is the goose CLI built with the Go migrations of package
+// migrations, which the stock goose binary cannot run:
+//
+//	go run -tags sqlite_fts5 ./cmd/migrate -dir ./migrations sqlite3 ./lab04.db up
+//
+// It takes the arguments of goose: a directory, a dialect accepted by
+// database.ParseDialect, a database file or URL, and a goose command.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"lab04-backend/database"
+	_ "lab04-backend/migrations"
+
+	"github.com/pressly/goose/v3"
+)
+
+func main() {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dir := flags.String("dir", "./migrations", "directory of the SQL migrations")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: migrate [-dir DIR] DIALECT DATABASE COMMAND [ARGS...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if flags.NArg() < 3 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	dialect, err := database.ParseDialect(flags.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	config := database.DefaultConfig()
+	config.Dialect = dialect
+	config.DatabasePath = flags.Arg(1)
+	config.DatabaseURL = flags.Arg(1)
+	db, err := database.InitDBWithConfig(config)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer db.Close()
+
+	if err := goose.SetDialect(dialect.GooseDialect()); err != nil {
+		log.Fatal(err)
+	}
+	command := flags.Arg(2)
+	if err := goose.RunContext(context.Background(), command, db, *dir, flags.Args()[3:]...); err != nil {
+		log.Fatalf("Failed to run %s: %v", command, err)
+	}
+}
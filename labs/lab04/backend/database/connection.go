@@ -3,56 +3,111 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	_ "github.com/jackc/pgx/v5/stdlib"
 	_ "github.com/mattn/go-sqlite3"
 )
 
 // Config holds database configuration
 type Config struct {
-	DatabasePath    string
+	Dialect         Dialect // Defaults to SQLite when empty
+	DatabasePath    string  // SQLite database file
+	DatabaseURL     string  // PostgreSQL connection URL
 	MaxOpenConns    int
 	MaxIdleConns    int
 	ConnMaxLifetime time.Duration
 	ConnMaxIdleTime time.Duration
+	QueryTimeout    time.Duration // Default deadline of each repository query, 0 disables it
+
+	SlowQueryThreshold time.Duration // Queries slower than this are logged by InitMonitoredDB; 0 uses the default, negative disables the log
 }
 
 // DefaultConfig returns a default database configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Dialect:         DialectSQLite,
 		DatabasePath:    "./lab04.db",
 		MaxOpenConns:    25,
 		MaxIdleConns:    5,
 		ConnMaxLifetime: 5 * time.Minute,
 		ConnMaxIdleTime: 2 * time.Minute,
+		QueryTimeout:    5 * time.Second,
+
+		SlowQueryThreshold: DefaultSlowQueryThreshold,
+	}
+}
+
+// GetDialect returns the configured dialect normalized by ParseDialect, so
+// "postgresql", "pgx" and "POSTGRES" all select PostgreSQL. Empty defaults
+// to SQLite; unsupported names are returned as is and rejected by
+// InitDBWithConfig.
+func (c *Config) GetDialect() Dialect {
+	dialect, err := ParseDialect(string(c.Dialect))
+	if err != nil {
+		return c.Dialect
+	}
+	return dialect
+}
+
+// DSN returns the data source name passed to sql.Open
+func (c *Config) DSN() string {
+	if c.GetDialect() == DialectPostgres {
+		return c.DatabaseURL
+	}
+
+	// Enforce foreign keys and wait on locks instead of failing immediately.
+	// Transactions take the write lock when they begin: two that read first
+	// and write later would otherwise deadlock, and SQLite fails one of them
+	// without waiting.
+	separator := "?"
+	if strings.Contains(c.DatabasePath, "?") {
+		separator = "&"
 	}
+	return c.DatabasePath + separator + "_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"
 }
 
-// TODO: Implement InitDB function
+// InitDB initializes a database connection with the default configuration
 func InitDB() (*sql.DB, error) {
-	// TODO: Initialize database connection with SQLite
-	// - Open database connection using sqlite3 driver
-	// - Apply connection pool configuration from DefaultConfig()
-	// - Test connection with Ping()
-	// - Return the database connection or error
-	return nil, fmt.Errorf("TODO: implement InitDB function")
+	return InitDBWithConfig(DefaultConfig())
 }
 
-// TODO: Implement InitDBWithConfig function
+// InitDBWithConfig initializes a database connection with custom configuration
 func InitDBWithConfig(config *Config) (*sql.DB, error) {
-	// TODO: Initialize database connection with custom configuration
-	// - Open database connection using the provided config
-	// - Apply all connection pool settings
-	// - Test connection with Ping()
-	// - Return the database connection or error
-	return nil, fmt.Errorf("TODO: implement InitDBWithConfig function")
+	if config == nil {
+		return nil, fmt.Errorf("database config cannot be nil")
+	}
+
+	dialect, err := ParseDialect(string(config.Dialect))
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(dialect.DriverName(), config.DSN())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %v", err)
+	}
+
+	db.SetMaxOpenConns(config.MaxOpenConns)
+	db.SetMaxIdleConns(config.MaxIdleConns)
+	db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	SetQueryTimeout(db, config.QueryTimeout)
+	return db, nil
 }
 
-// TODO: Implement CloseDB function
+// CloseDB properly closes the database connection
 func CloseDB(db *sql.DB) error {
-	// TODO: Properly close database connection
-	// - Check if db is not nil
-	// - Close the database connection
-	// - Return any error that occurs
-	return fmt.Errorf("TODO: implement CloseDB function")
+	if db == nil {
+		return fmt.Errorf("database connection cannot be nil")
+	}
+	queryTimeouts.Delete(db)
+	return db.Close()
 }
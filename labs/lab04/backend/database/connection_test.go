@@ -96,6 +96,58 @@ func TestInitDBWithConfig(t *testing.T) {
 	}
 }
 
+func TestConfigDialectAliases(t *testing.T) {
+	const url = "postgres://lab04@127.0.0.1:1/lab04?connect_timeout=1"
+	tests := []struct {
+		dialect Dialect
+		want    Dialect
+		dsn     string
+	}{
+		{"", DialectSQLite, "lab04.db?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"},
+		{"sqlite", DialectSQLite, "lab04.db?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"},
+		{"SQLite3", DialectSQLite, "lab04.db?_foreign_keys=on&_busy_timeout=5000&_txlock=immediate"},
+		{"postgres", DialectPostgres, url},
+		{"postgresql", DialectPostgres, url},
+		{"pgx", DialectPostgres, url},
+		{"POSTGRES", DialectPostgres, url},
+	}
+
+	for _, tt := range tests {
+		config := &Config{Dialect: tt.dialect, DatabasePath: "lab04.db", DatabaseURL: url}
+		if got := config.GetDialect(); got != tt.want {
+			t.Errorf("GetDialect() with %q = %q, want %q", tt.dialect, got, tt.want)
+		}
+		if got := config.DSN(); got != tt.dsn {
+			t.Errorf("DSN() with %q = %q, want %q", tt.dialect, got, tt.dsn)
+		}
+	}
+
+	// Aliases must reach PostgreSQL, not open a SQLite file named after the
+	// DSN options
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+	for _, alias := range []Dialect{"postgresql", "pgx", "POSTGRES"} {
+		if db, err := InitDBWithConfig(&Config{Dialect: alias, DatabaseURL: url}); err == nil {
+			CloseDB(db)
+			t.Errorf("InitDBWithConfig() with %q connected without a PostgreSQL server", alias)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("InitDBWithConfig() with a PostgreSQL alias created %s", entries[0].Name())
+	}
+
+	if _, err := InitDBWithConfig(&Config{Dialect: "mysql"}); err == nil {
+		t.Error("InitDBWithConfig() should reject an unsupported dialect")
+	}
+}
+
 func TestMigrate(t *testing.T) {
 	// Clean up test database before and after
 	testDB := "./test_migrate.db"
@@ -0,0 +1,239 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Dialect identifies the SQL flavour spoken by a database
+type Dialect string
+
+// Supported dialects
+const (
+	DialectSQLite   Dialect = "sqlite3"
+	DialectPostgres Dialect = "postgres"
+)
+
+// ParseDialect converts a driver or dialect name into a Dialect
+func ParseDialect(name string) (Dialect, error) {
+	switch strings.ToLower(name) {
+	case "", "sqlite", "sqlite3":
+		return DialectSQLite, nil
+	case "postgres", "postgresql", "pgx":
+		return DialectPostgres, nil
+	default:
+		return "", fmt.Errorf("unsupported database dialect: %q", name)
+	}
+}
+
+// DialectOf detects the dialect of a connection from its driver, or asks a
+// connection that knows it: a transaction started by TxManager, a
+// MonitoredDB, or a connection wrapped by WithDialect. A raw *sql.Tx, or any
+// other connection, does not say which database it runs on, so it is asked
+// with probeDialect.
+func DialectOf(db DBTX) Dialect {
+	switch db := db.(type) {
+	case nil:
+		return DialectSQLite
+	case interface{ Dialect() Dialect }:
+		return db.Dialect()
+	case *sql.DB:
+		if db == nil {
+			return DialectSQLite
+		}
+		switch db.Driver().(type) {
+		case *stdlib.Driver:
+			return DialectPostgres
+		case *sqlite3.SQLiteDriver:
+			return DialectSQLite
+		}
+	}
+	return probeDialect(db)
+}
+
+// probeDialect runs SELECT version(), which only PostgreSQL answers. SQLite
+// rejects the statement when preparing it, which leaves an open
+// transaction usable, whereas a failed statement would abort a PostgreSQL
+// one.
+func probeDialect(db DBTX) Dialect {
+	var version string
+	if err := db.QueryRow("SELECT version()").Scan(&version); err == nil && strings.HasPrefix(version, "PostgreSQL") {
+		return DialectPostgres
+	}
+	return DialectSQLite
+}
+
+// WithDialect tells DialectOf the dialect of a connection, sparing the
+// probe of a transaction begun directly on a *sql.DB
+func WithDialect(db DBTX, dialect Dialect) DBTX {
+	return dialectConn{DBTX: db, dialect: dialect}
+}
+
+type dialectConn struct {
+	DBTX
+	dialect Dialect
+}
+
+func (c dialectConn) Dialect() Dialect {
+	return c.dialect
+}
+
+// DriverName returns the database/sql driver name for the dialect
+func (d Dialect) DriverName() string {
+	if d == DialectPostgres {
+		return "pgx"
+	}
+	return "sqlite3"
+}
+
+// GooseDialect returns the dialect name understood by goose
+func (d Dialect) GooseDialect() string {
+	if d == DialectPostgres {
+		return "postgres"
+	}
+	return "sqlite3"
+}
+
+// Placeholder returns the bind parameter format of the dialect
+func (d Dialect) Placeholder() squirrel.PlaceholderFormat {
+	if d == DialectPostgres {
+		return squirrel.Dollar
+	}
+	return squirrel.Question
+}
+
+// StatementBuilder returns a squirrel builder using the dialect's placeholders
+func (d Dialect) StatementBuilder() squirrel.StatementBuilderType {
+	return squirrel.StatementBuilder.PlaceholderFormat(d.Placeholder())
+}
+
+// Rebind rewrites a query written with ? placeholders for the dialect.
+// The query must not contain literal question marks.
+func (d Dialect) Rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// StringAgg returns an aggregate joining the values of expr with separator,
+// which must not contain a single quote
+func (d Dialect) StringAgg(expr, separator string) string {
+	if d == DialectPostgres {
+		return "STRING_AGG(" + expr + ", '" + separator + "')"
+	}
+	return "GROUP_CONCAT(" + expr + ", '" + separator + "')"
+}
+
+// Period is the width of a time bucket
+type Period string
+
+// Supported periods. Weeks start on Monday, as in ISO 8601.
+const (
+	PeriodDay   Period = "day"
+	PeriodWeek  Period = "week"
+	PeriodMonth Period = "month"
+)
+
+// ParsePeriod converts "day", "week" or "month" into a Period
+func ParsePeriod(name string) (Period, error) {
+	switch period := Period(strings.ToLower(name)); period {
+	case PeriodDay, PeriodWeek, PeriodMonth:
+		return period, nil
+	default:
+		return "", fmt.Errorf("unsupported period: %q, want day, week or month", name)
+	}
+}
+
+// Truncate returns the start of the period containing t, in UTC
+func (p Period) Truncate(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case PeriodMonth:
+		return day.AddDate(0, 0, 1-day.Day())
+	default:
+		return day
+	}
+}
+
+// Add moves t by n periods
+func (p Period) Add(t time.Time, n int) time.Time {
+	switch p {
+	case PeriodWeek:
+		return t.AddDate(0, 0, 7*n)
+	case PeriodMonth:
+		return t.AddDate(0, n, 0)
+	default:
+		return t.AddDate(0, 0, n)
+	}
+}
+
+// Timestamp returns t as a parameter compared with a timestamp column.
+// SQLite stores timestamps as UTC text, e.g. '2025-07-14 09:30:00' with
+// optional fractional seconds and offset, which sorts with t in the same
+// layout; PostgreSQL compares times.
+func (d Dialect) Timestamp(t time.Time) interface{} {
+	if d == DialectPostgres {
+		return t
+	}
+	return t.UTC().Format(time.DateTime)
+}
+
+// DateTrunc returns an expression truncating the timestamp expr to the start
+// of its period, in UTC, as a 'YYYY-MM-DD' string that both dialects compare
+// and group the same way
+func (d Dialect) DateTrunc(period Period, expr string) string {
+	if d == DialectPostgres {
+		return "TO_CHAR(DATE_TRUNC('" + string(period) + "', " + expr + " AT TIME ZONE 'UTC'), 'YYYY-MM-DD')"
+	}
+	switch period {
+	case PeriodWeek:
+		// The next Sunday, or the day itself if it is one, minus six days
+		return "DATE(" + expr + ", 'weekday 0', '-6 days')"
+	case PeriodMonth:
+		return "STRFTIME('%Y-%m-01', " + expr + ")"
+	default:
+		return "DATE(" + expr + ")"
+	}
+}
+
+// ILike returns a case-insensitive LIKE condition. PostgreSQL has ILIKE;
+// SQLite's LIKE is already case-insensitive for ASCII. Backslash is the
+// escape character of pattern, so user input should go through EscapeLike.
+func (d Dialect) ILike(column, pattern string) squirrel.Sqlizer {
+	if d == DialectPostgres {
+		return squirrel.Expr(column+` ILIKE ? ESCAPE '\'`, pattern)
+	}
+	return squirrel.Expr(column+` LIKE ? ESCAPE '\'`, pattern)
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern and its escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// EscapeLike escapes s so that it matches itself in a LIKE pattern with
+// ESCAPE '\', e.g. "%" + EscapeLike(query) + "%" for a substring search
+func EscapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
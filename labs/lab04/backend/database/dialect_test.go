@@ -0,0 +1,203 @@
+package database
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Masterminds/squirrel"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Dialect
+		wantErr bool
+	}{
+		{"", DialectSQLite, false},
+		{"sqlite3", DialectSQLite, false},
+		{"postgres", DialectPostgres, false},
+		{"pgx", DialectPostgres, false},
+		{"mysql", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDialect(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDialect(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDialect(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDialectRebind(t *testing.T) {
+	query := "SELECT * FROM users WHERE id = ? AND email = ?"
+
+	if got := DialectSQLite.Rebind(query); got != query {
+		t.Errorf("SQLite Rebind() = %q, want unchanged", got)
+	}
+
+	want := "SELECT * FROM users WHERE id = $1 AND email = $2"
+	if got := DialectPostgres.Rebind(query); got != want {
+		t.Errorf("Postgres Rebind() = %q, want %q", got, want)
+	}
+}
+
+func TestDialectStringAgg(t *testing.T) {
+	if got, want := DialectSQLite.StringAgg("c.name", "|"), "GROUP_CONCAT(c.name, '|')"; got != want {
+		t.Errorf("SQLite StringAgg() = %q, want %q", got, want)
+	}
+	if got, want := DialectPostgres.StringAgg("c.name", "|"), "STRING_AGG(c.name, '|')"; got != want {
+		t.Errorf("Postgres StringAgg() = %q, want %q", got, want)
+	}
+}
+
+func TestDialectDateTrunc(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		period Period
+		value  string
+		want   string
+	}{
+		{PeriodDay, "2025-07-16 23:30:00.123456789+00:00", "2025-07-16"},
+		{PeriodDay, "2025-07-16 23:30:00-02:00", "2025-07-17"},
+		{PeriodWeek, "2025-07-16 09:00:00", "2025-07-14"}, // Wednesday
+		{PeriodWeek, "2025-07-14 09:00:00", "2025-07-14"}, // Monday
+		{PeriodWeek, "2025-07-20 09:00:00", "2025-07-14"}, // Sunday
+		{PeriodMonth, "2025-07-16 09:00:00", "2025-07-01"},
+	}
+	for _, tt := range tests {
+		var got string
+		if err := db.QueryRow("SELECT "+DialectSQLite.DateTrunc(tt.period, "?"), tt.value).Scan(&got); err != nil {
+			t.Fatalf("DateTrunc(%s) error = %v", tt.period, err)
+		}
+		if got != tt.want {
+			t.Errorf("DateTrunc(%s, %q) = %q, want %q", tt.period, tt.value, got, tt.want)
+		}
+	}
+
+	want := "TO_CHAR(DATE_TRUNC('week', created_at AT TIME ZONE 'UTC'), 'YYYY-MM-DD')"
+	if got := DialectPostgres.DateTrunc(PeriodWeek, "created_at"); got != want {
+		t.Errorf("Postgres DateTrunc() = %q, want %q", got, want)
+	}
+	if _, err := ParsePeriod("year"); err == nil {
+		t.Error("ParsePeriod(year) should fail")
+	}
+}
+
+func TestPeriodTruncate(t *testing.T) {
+	at := time.Date(2025, 7, 20, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60)) // Monday in UTC
+	tests := []struct {
+		period Period
+		want   time.Time
+	}{
+		{PeriodDay, time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC)},
+		{PeriodWeek, time.Date(2025, 7, 21, 0, 0, 0, 0, time.UTC)},
+		{PeriodMonth, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.period.Truncate(at); !got.Equal(tt.want) {
+			t.Errorf("%s.Truncate() = %v, want %v", tt.period, got, tt.want)
+		}
+	}
+	if got := PeriodWeek.Truncate(time.Date(2025, 7, 20, 12, 0, 0, 0, time.UTC)); got.Day() != 14 {
+		t.Errorf("week.Truncate(Sunday) = %v, want Monday the 14th", got)
+	}
+	if got := PeriodMonth.Add(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), -1); got.Month() != time.December {
+		t.Errorf("month.Add(-1) = %v, want December", got)
+	}
+}
+
+func TestDialectStatementBuilder(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{DialectSQLite, `SELECT id FROM posts WHERE title LIKE ? ESCAPE '\' AND user_id = ?`},
+		{DialectPostgres, `SELECT id FROM posts WHERE title ILIKE $1 ESCAPE '\' AND user_id = $2`},
+	}
+
+	for _, tt := range tests {
+		sql, _, err := tt.dialect.StatementBuilder().
+			Select("id").
+			From("posts").
+			Where(tt.dialect.ILike("title", "%go%")).
+			Where(squirrel.Eq{"user_id": 1}).
+			ToSql()
+		if err != nil {
+			t.Fatalf("%s: ToSql() error = %v", tt.dialect, err)
+		}
+		if sql != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.dialect, sql, tt.want)
+		}
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	db, err := InitDBWithConfig(&Config{DatabasePath: ":memory:", MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE t (title TEXT); INSERT INTO t VALUES ('100% Go'), ('100 Go'), ('a_b'), ('axb'), ('C:\Go'), ('C:Go')`); err != nil {
+		t.Fatalf("Exec() error = %v", err)
+	}
+
+	for search, want := range map[string]string{"0%": "100% Go", "a_b": "a_b", `:\`: `C:\Go`} {
+		query, args, err := DialectSQLite.StatementBuilder().Select("title").From("t").
+			Where(DialectSQLite.ILike("title", "%"+EscapeLike(search)+"%")).ToSql()
+		if err != nil {
+			t.Fatalf("ToSql() error = %v", err)
+		}
+		var titles []string
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		for rows.Next() {
+			var title string
+			rows.Scan(&title)
+			titles = append(titles, title)
+		}
+		rows.Close()
+		if len(titles) != 1 || titles[0] != want {
+			t.Errorf("search for %q matched %q, want only %q", search, titles, want)
+		}
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	db, err := InitDBWithConfig(&Config{DatabasePath: ":memory:", MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	defer db.Close()
+
+	if got := DialectOf(db); got != DialectSQLite {
+		t.Errorf("DialectOf() = %q, want %q", got, DialectSQLite)
+	}
+
+	// A raw transaction is probed, and stays usable
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	defer tx.Rollback()
+	if got := DialectOf(tx); got != DialectSQLite {
+		t.Errorf("DialectOf(*sql.Tx) = %q, want %q", got, DialectSQLite)
+	}
+	if _, err := tx.Exec("CREATE TABLE probed (id INTEGER)"); err != nil {
+		t.Errorf("transaction unusable after the probe: %v", err)
+	}
+	if got := DialectOf(WithDialect(tx, DialectPostgres)); got != DialectPostgres {
+		t.Errorf("DialectOf(WithDialect()) = %q, want %q", got, DialectPostgres)
+	}
+}
@@ -0,0 +1,30 @@
+package database
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// pgUniqueViolation is the SQLSTATE of a unique constraint violation
+const pgUniqueViolation = "23505"
+
+// IsUniqueViolation reports whether err is a unique constraint violation on
+// column of table, as reported by SQLite or PostgreSQL
+func IsUniqueViolation(err error, table, column string) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		// SQLite names the columns: "UNIQUE constraint failed: posts.slug"
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+			strings.Contains(sqliteErr.Error(), table+"."+column)
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// PostgreSQL names the key: "Key (slug)=(hello) already exists."
+		return pgErr.Code == pgUniqueViolation && pgErr.TableName == table &&
+			strings.Contains(pgErr.Detail, "("+column+")")
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsUniqueViolation(t *testing.T) {
+	db, err := InitDBWithConfig(&Config{DatabasePath: ":memory:", MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE posts (slug TEXT UNIQUE, title TEXT NOT NULL)`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO posts (slug, title) VALUES ('hello', 'Hello')`); err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	_, duplicate := db.Exec(`INSERT INTO posts (slug, title) VALUES ('hello', 'Hello again')`)
+	_, notNull := db.Exec(`INSERT INTO posts (slug) VALUES ('other')`)
+
+	tests := []struct {
+		name          string
+		err           error
+		table, column string
+		want          bool
+	}{
+		{"SQLite duplicate", fmt.Errorf("wrapped: %w", duplicate), "posts", "slug", true},
+		{"SQLite other column", duplicate, "posts", "title", false},
+		{"SQLite other constraint", notNull, "posts", "slug", false},
+		{"PostgreSQL duplicate", fmt.Errorf("wrapped: %w", &pgconn.PgError{
+			Code: "23505", TableName: "posts", Detail: "Key (slug)=(hello) already exists.",
+		}), "posts", "slug", true},
+		{"PostgreSQL other table", &pgconn.PgError{
+			Code: "23505", TableName: "users", Detail: "Key (slug)=(hello) already exists.",
+		}, "posts", "slug", false},
+		{"other error", errors.New("UNIQUE constraint failed: posts.slug"), "posts", "slug", false},
+		{"nil", nil, "posts", "slug", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUniqueViolation(tt.err, tt.table, tt.column); got != tt.want {
+				t.Errorf("IsUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+package database
+
+import "context"
+
+// FTS5Available reports whether db is SQLite compiled with FTS5. The
+// posts_fts index is created by its migration only when it is.
+func FTS5Available(ctx context.Context, db DBTX) (bool, error) {
+	if DialectOf(db) != DialectSQLite {
+		return false, nil
+	}
+	var enabled bool
+	err := db.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	return enabled, err
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+const postsFTSVersion = 20250712090000
+
+func TestPostsFTSMigration(t *testing.T) {
+	db, err := InitDBWithConfig(&Config{DatabasePath: filepath.Join(t.TempDir(), "fts.db"), MaxOpenConns: 2})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	statuses, err := GetMigrationStatus(db)
+	if err != nil {
+		t.Fatalf("GetMigrationStatus() error = %v", err)
+	}
+	found := false
+	for _, status := range statuses {
+		if status.Version == postsFTSVersion {
+			found = true
+			if status.Type != "go" || !status.Applied {
+				t.Errorf("migration %s type = %s, applied = %v; want an applied Go migration", status.Source, status.Type, status.Applied)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("GetMigrationStatus() has no migration %d", postsFTSVersion)
+	}
+
+	available, err := FTS5Available(ctx, db)
+	if err != nil {
+		t.Fatalf("FTS5Available() error = %v", err)
+	}
+	t.Logf("FTS5 available: %v", available)
+	if got := postsFTSExists(t, db); got != available {
+		t.Fatalf("posts_fts exists = %v, want %v", got, available)
+	}
+	if !available {
+		return
+	}
+
+	// Rolling back drops the index; migrating again fills it with the
+	// posts written meanwhile
+	if err := RollbackTo(db, postsFTSVersion-1); err != nil {
+		t.Fatalf("RollbackTo() error = %v", err)
+	}
+	if postsFTSExists(t, db) {
+		t.Fatal("posts_fts should be dropped by its Down migration")
+	}
+	if _, err := db.Exec(`INSERT INTO users (name, email) VALUES ('Ada', 'ada@example.com')`); err != nil {
+		t.Fatalf("failed to insert user: %v", err)
+	}
+	if _, err := db.Exec(`INSERT INTO posts (user_id, title, content) VALUES (1, 'Engines', 'Analytical engines')`); err != nil {
+		t.Fatalf("failed to insert post: %v", err)
+	}
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	var matches int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM posts_fts WHERE posts_fts MATCH 'analytical'`).Scan(&matches); err != nil || matches != 1 {
+		t.Errorf("posts_fts matches = %d, %v; want the existing post indexed", matches, err)
+	}
+}
+
+func postsFTSExists(t *testing.T, db DBTX) bool {
+	var exists bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'posts_fts')`).Scan(&exists)
+	if err != nil {
+		t.Fatalf("failed to look up posts_fts: %v", err)
+	}
+	return exists
+}
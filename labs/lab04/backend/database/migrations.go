@@ -1,48 +1,267 @@
 package database
 
 import (
+	"bufio"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"lab04-backend/migrations"
 
 	"github.com/pressly/goose/v3"
 )
 
-// RunMigrations runs database migrations using goose
-func RunMigrations(db *sql.DB) error {
+// MigrationStatus describes one migration and whether it has been applied
+type MigrationStatus struct {
+	Version   int64     `json:"version"`
+	Source    string    `json:"source"` // File name, e.g. 20250708090008_create_users_table.sql
+	Type      string    `json:"type"`   // "sql" or "go"
+	Applied   bool      `json:"applied"`
+	AppliedAt time.Time `json:"applied_at,omitempty"` // Zero while pending
+}
+
+// migrationsFS returns the embedded migrations of a dialect
+func migrationsFS(dialect Dialect) (fs.FS, error) {
+	if dialect == DialectPostgres {
+		return fs.Sub(migrations.FS, "postgres")
+	}
+	return migrations.FS, nil
+}
+
+// newProvider returns a goose provider for db reading the embedded
+// migrations of its dialect
+func newProvider(db *sql.DB) (*goose.Provider, error) {
 	if db == nil {
-		return fmt.Errorf("database connection cannot be nil")
+		return nil, fmt.Errorf("database connection cannot be nil")
 	}
 
-	// Set goose dialect for SQLite
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("failed to set goose dialect: %v", err)
+	dialect := DialectOf(db)
+	fsys, err := migrationsFS(dialect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %v", err)
+	}
+	provider, err := goose.NewProvider(goose.Dialect(dialect.GooseDialect()), db, fsys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %v", err)
 	}
+	return provider, nil
+}
 
-	// Get path to migrations directory (relative to backend directory)
-	migrationsDir := "../migrations"
+// RunMigrations applies all pending migrations
+func RunMigrations(db *sql.DB) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
 
-	// Run migrations from the migrations directory
-	if err := goose.Up(db, migrationsDir); err != nil {
+	if _, err := provider.Up(context.Background()); err != nil {
 		return fmt.Errorf("failed to run migrations: %v", err)
 	}
-
 	return nil
 }
 
-// TODO: Implement this function
-// RollbackMigration rolls back the last migration using goose
+// RollbackMigration rolls back the most recently applied migration
 func RollbackMigration(db *sql.DB) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+
+	if _, err := provider.Down(context.Background()); err != nil {
+		if errors.Is(err, goose.ErrNoNextVersion) {
+			return fmt.Errorf("no migration to roll back")
+		}
+		return fmt.Errorf("failed to roll back migration: %v", err)
+	}
 	return nil
 }
 
-// TODO: Implement this function
-// GetMigrationStatus checks migration status using goose
-func GetMigrationStatus(db *sql.DB) error {
+// RollbackTo rolls back applied migrations newer than version, keeping
+// version itself applied. Version 0 rolls back every migration.
+func RollbackTo(db *sql.DB, version int64) error {
+	provider, err := newProvider(db)
+	if err != nil {
+		return err
+	}
+
+	if _, err := provider.DownTo(context.Background(), version); err != nil {
+		return fmt.Errorf("failed to roll back to version %d: %v", version, err)
+	}
 	return nil
 }
 
-// TODO: Implement this function
-// CreateMigration creates a new migration file
-func CreateMigration(name string) error {
+// GetMigrationStatus returns every known migration, oldest first, with
+// whether and when it was applied
+func GetMigrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	provider, err := newProvider(db)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := provider.Status(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %v", err)
+	}
+
+	statuses := make([]MigrationStatus, len(results))
+	for i, result := range results {
+		statuses[i] = MigrationStatus{
+			Version:   result.Source.Version,
+			Source:    filepath.Base(result.Source.Path),
+			Type:      string(result.Source.Type),
+			Applied:   result.State == goose.StateApplied,
+			AppliedAt: result.AppliedAt,
+		}
+	}
+	return statuses, nil
+}
+
+// DryRunMigrations writes the SQL that RunMigrations would apply to w
+// without changing the database
+func DryRunMigrations(db *sql.DB, w io.Writer) error {
+	statuses, err := GetMigrationStatus(db)
+	if err != nil {
+		return err
+	}
+
+	var pending []MigrationStatus
+	for _, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, status)
+		}
+	}
+	return writeMigrationSQL(w, DialectOf(db), pending, true)
+}
+
+// DryRunRollbackTo writes the SQL that RollbackTo would run to w without
+// changing the database
+func DryRunRollbackTo(db *sql.DB, version int64, w io.Writer) error {
+	statuses, err := GetMigrationStatus(db)
+	if err != nil {
+		return err
+	}
+
+	// Migrations are rolled back newest first
+	var rollback []MigrationStatus
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if statuses[i].Applied && statuses[i].Version > version {
+			rollback = append(rollback, statuses[i])
+		}
+	}
+	return writeMigrationSQL(w, DialectOf(db), rollback, false)
+}
+
+// writeMigrationSQL writes the up or down section of each migration
+func writeMigrationSQL(w io.Writer, dialect Dialect, list []MigrationStatus, up bool) error {
+	if len(list) == 0 {
+		_, err := fmt.Fprintln(w, "-- Nothing to do")
+		return err
+	}
+
+	fsys, err := migrationsFS(dialect)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range list {
+		if _, err := fmt.Fprintf(w, "-- %s\n", m.Source); err != nil {
+			return err
+		}
+
+		statements := "-- Go migration, its SQL is only known when it runs"
+		if m.Type == string(goose.TypeSQL) {
+			content, err := fs.ReadFile(fsys, m.Source)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %v", m.Source, err)
+			}
+			statements = migrationSection(string(content), up)
+		}
+		if _, err := fmt.Fprintf(w, "%s\n\n", statements); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// migrationSection returns the statements of the Up or Down section of a
+// goose SQL migration, without goose annotations
+func migrationSection(content string, up bool) string {
+	want := "-- +goose Down"
+	if up {
+		want = "-- +goose Up"
+	}
+
+	var lines []string
+	inSection := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "-- +goose Up") || strings.HasPrefix(trimmed, "-- +goose Down") {
+			inSection = strings.HasPrefix(trimmed, want)
+			continue
+		}
+		if inSection && !strings.HasPrefix(trimmed, "-- +goose") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// migrationName matches names accepted by CreateMigration
+var migrationName = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)*$`)
+
+// migrationTemplate is the content of a new SQL migration
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+`
+
+// CreateMigration creates an empty SQL migration named
+// <timestamp>_<name>.sql in dir and a PostgreSQL copy in dir/postgres, and
+// returns their paths. name must be snake_case, e.g. add_user_bio.
+func CreateMigration(dir, name string) ([]string, error) {
+	if !migrationName.MatchString(name) {
+		return nil, fmt.Errorf("migration name %q must be snake_case", name)
+	}
+
+	file := time.Now().UTC().Format("20060102150405") + "_" + name + ".sql"
+	paths := []string{filepath.Join(dir, file), filepath.Join(dir, "postgres", file)}
+	for i, path := range paths {
+		if err := writeNewFile(path, migrationTemplate); err != nil {
+			// Do not leave one dialect without the migration
+			for _, created := range paths[:i] {
+				os.Remove(created)
+			}
+			return nil, fmt.Errorf("failed to create migration: %v", err)
+		}
+	}
+	return paths, nil
+}
+
+// writeNewFile writes content to path, failing if the file exists
+func writeNewFile(path, content string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrations(t *testing.T) {
+	db, err := InitDBWithConfig(&Config{DatabasePath: filepath.Join(t.TempDir(), "migrations.db"), MaxOpenConns: 2})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var plan bytes.Buffer
+	if err := DryRunMigrations(db, &plan); err != nil {
+		t.Fatalf("DryRunMigrations() error = %v", err)
+	}
+	if !strings.Contains(plan.String(), "CREATE TABLE users") || strings.Contains(plan.String(), "DROP TABLE") {
+		t.Errorf("DryRunMigrations() should print the up SQL only, got:\n%s", plan.String())
+	}
+	if _, err := db.Exec(`SELECT COUNT(*) FROM users`); err == nil {
+		t.Error("DryRunMigrations() should not change the database")
+	}
+
+	if err := RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	statuses, err := GetMigrationStatus(db)
+	if err != nil {
+		t.Fatalf("GetMigrationStatus() error = %v", err)
+	}
+	if len(statuses) == 0 {
+		t.Fatal("GetMigrationStatus() returned no migrations")
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() {
+			t.Errorf("migration %s is not applied after RunMigrations()", status.Source)
+		}
+	}
+
+	last := statuses[len(statuses)-1]
+	if err := RollbackMigration(db); err != nil {
+		t.Fatalf("RollbackMigration() error = %v", err)
+	}
+	statuses, _ = GetMigrationStatus(db)
+	if statuses[len(statuses)-1].Applied {
+		t.Errorf("RollbackMigration() left %s applied", last.Source)
+	}
+
+	first := statuses[0]
+	plan.Reset()
+	if err := DryRunRollbackTo(db, first.Version, &plan); err != nil {
+		t.Fatalf("DryRunRollbackTo() error = %v", err)
+	}
+	if !strings.Contains(plan.String(), "DROP TABLE posts") || strings.Contains(plan.String(), "DROP TABLE users") {
+		t.Errorf("DryRunRollbackTo() should drop everything after the users table, got:\n%s", plan.String())
+	}
+
+	if err := RollbackTo(db, first.Version); err != nil {
+		t.Fatalf("RollbackTo() error = %v", err)
+	}
+	statuses, _ = GetMigrationStatus(db)
+	for _, status := range statuses {
+		if want := status.Version <= first.Version; status.Applied != want {
+			t.Errorf("after RollbackTo(%d), %s applied = %v, want %v", first.Version, status.Source, status.Applied, want)
+		}
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "postgres"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	paths, err := CreateMigration(dir, "add_user_bio")
+	if err != nil {
+		t.Fatalf("CreateMigration() error = %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[0]) != filepath.Base(paths[1]) {
+		t.Fatalf("CreateMigration() = %v, want a SQLite and a PostgreSQL file with the same name", paths)
+	}
+	if !strings.HasSuffix(paths[0], "_add_user_bio.sql") {
+		t.Errorf("CreateMigration() file %s does not end in _add_user_bio.sql", paths[0])
+	}
+	content, err := os.ReadFile(paths[1])
+	if err != nil || !strings.Contains(string(content), "-- +goose Up") {
+		t.Errorf("CreateMigration() wrote %q, %v; want a goose template", content, err)
+	}
+
+	for _, name := range []string{"", "Add User", "add-user", "../escape"} {
+		if _, err := CreateMigration(dir, name); err == nil {
+			t.Errorf("CreateMigration(%q) should fail", name)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSlowQueryThreshold is the latency above which queries are logged
+// when Config.SlowQueryThreshold is not set
+const DefaultSlowQueryThreshold = 200 * time.Millisecond
+
+// DefaultHealthTimeout bounds HealthCheck when ctx has no deadline
+const DefaultHealthTimeout = 2 * time.Second
+
+// MaxTrackedQueries bounds the number of distinct statements with their own
+// histogram; further statements are counted under OtherQueries
+const MaxTrackedQueries = 500
+
+// OtherQueries is the key of the statements past MaxTrackedQueries
+const OtherQueries = "(other)"
+
+// LatencyBuckets are the upper bounds of the histogram buckets, in
+// increasing order. Slower queries fall in the overflow bucket. Change it
+// before any query is recorded, if at all.
+var LatencyBuckets = []time.Duration{
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Histogram counts query latencies in LatencyBuckets. The zero value is
+// ready to use.
+type Histogram struct {
+	mu     sync.Mutex
+	counts []uint64 // One per bucket, then the overflow bucket
+	count  uint64
+	errors uint64
+	sum    time.Duration
+	max    time.Duration
+}
+
+// Observe records one query
+func (h *Histogram) Observe(d time.Duration, err error) {
+	i := sort.Search(len(LatencyBuckets), func(i int) bool { return d <= LatencyBuckets[i] })
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.counts == nil {
+		h.counts = make([]uint64, len(LatencyBuckets)+1)
+	}
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+	if err != nil {
+		h.errors++
+	}
+}
+
+// Snapshot returns a copy of the histogram
+func (h *Histogram) Snapshot() HistogramSnapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := HistogramSnapshot{
+		Count:   h.count,
+		Errors:  h.errors,
+		Sum:     h.sum,
+		Max:     h.max,
+		Buckets: make([]Bucket, len(LatencyBuckets)),
+	}
+	for i, bound := range LatencyBuckets {
+		s.Buckets[i].UpperBound = bound
+		if h.counts != nil {
+			s.Buckets[i].Count = h.counts[i]
+		}
+	}
+	if h.counts != nil {
+		s.Overflow = h.counts[len(LatencyBuckets)]
+	}
+	return s
+}
+
+func (h *Histogram) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.counts, h.count, h.errors, h.sum, h.max = nil, 0, 0, 0, 0
+}
+
+// Bucket counts the queries slower than the previous bucket and at most
+// as slow as UpperBound
+type Bucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      uint64        `json:"count"`
+}
+
+// HistogramSnapshot is a point-in-time copy of a Histogram
+type HistogramSnapshot struct {
+	Count    uint64        `json:"count"`
+	Errors   uint64        `json:"errors"` // Queries that failed, timeouts included
+	Sum      time.Duration `json:"sum"`
+	Max      time.Duration `json:"max"`
+	Buckets  []Bucket      `json:"buckets"`
+	Overflow uint64        `json:"overflow"` // Queries slower than the last bucket
+}
+
+// Mean returns the average latency, or 0 without queries
+func (s HistogramSnapshot) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(s.Count)
+}
+
+// Quantile estimates the q-quantile (0 to 1) as the upper bound of the bucket
+// it falls in; quantiles in the overflow bucket return Max
+func (s HistogramSnapshot) Quantile(q float64) time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(s.Count))
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for _, bucket := range s.Buckets {
+		seen += bucket.Count
+		if seen >= rank {
+			return bucket.UpperBound
+		}
+	}
+	return s.Max
+}
+
+// QueryStats is the latency histogram of one statement
+type QueryStats struct {
+	Query string `json:"query"` // The statement with its whitespace collapsed
+	HistogramSnapshot
+}
+
+// Monitor collects query latencies and logs slow queries. The zero value
+// logs nothing and only collects histograms.
+type Monitor struct {
+	// SlowQueryThreshold is the latency above which a query is logged; 0
+	// disables the log
+	SlowQueryThreshold time.Duration
+	// Logger receives slow queries; defaults to log.Default()
+	Logger *log.Logger
+	// Redact replaces an argument of a logged query; defaults to RedactArg
+	Redact func(arg interface{}) interface{}
+
+	mu      sync.RWMutex
+	queries map[string]*Histogram
+	all     Histogram
+	slow    atomic.Uint64
+}
+
+// NewMonitor creates a monitor that logs queries slower than threshold
+func NewMonitor(threshold time.Duration) *Monitor {
+	return &Monitor{SlowQueryThreshold: threshold}
+}
+
+// RedactArg keeps numbers, booleans, times and nulls, which rarely identify
+// anyone, and hides everything else
+func RedactArg(arg interface{}) interface{} {
+	switch arg.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, time.Time:
+		return arg
+	default:
+		return "[redacted]"
+	}
+}
+
+// Observe records a query that took d and logs it if it was slow
+func (m *Monitor) Observe(query string, args []interface{}, d time.Duration, err error) {
+	query = normalizeQuery(query)
+	m.all.Observe(d, err)
+	m.histogram(query).Observe(d, err)
+
+	if m.SlowQueryThreshold <= 0 || d < m.SlowQueryThreshold {
+		return
+	}
+	m.slow.Add(1)
+	redact := m.Redact
+	if redact == nil {
+		redact = RedactArg
+	}
+	redacted := make([]interface{}, len(args))
+	for i, arg := range args {
+		redacted[i] = redact(arg)
+	}
+	logger := m.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	if err != nil {
+		logger.Printf("slow query (%s, failed: %v): %s %v", d.Round(time.Microsecond), err, query, redacted)
+	} else {
+		logger.Printf("slow query (%s): %s %v", d.Round(time.Microsecond), query, redacted)
+	}
+}
+
+// histogram returns the histogram of a normalized query, creating it if needed
+func (m *Monitor) histogram(query string) *Histogram {
+	m.mu.RLock()
+	h, ok := m.queries[query]
+	m.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if h, ok := m.queries[query]; ok {
+		return h
+	}
+	if m.queries == nil {
+		m.queries = make(map[string]*Histogram)
+	}
+	if len(m.queries) >= MaxTrackedQueries {
+		query = OtherQueries
+		if h, ok := m.queries[query]; ok {
+			return h
+		}
+	}
+	h = &Histogram{}
+	m.queries[query] = h
+	return h
+}
+
+// Latency returns the histogram of all queries
+func (m *Monitor) Latency() HistogramSnapshot {
+	return m.all.Snapshot()
+}
+
+// SlowQueries returns the number of queries logged as slow
+func (m *Monitor) SlowQueries() uint64 {
+	return m.slow.Load()
+}
+
+// QueryStats returns the histogram of every statement, the most time
+// consuming first
+func (m *Monitor) QueryStats() []QueryStats {
+	m.mu.RLock()
+	stats := make([]QueryStats, 0, len(m.queries))
+	for query, h := range m.queries {
+		stats = append(stats, QueryStats{Query: query, HistogramSnapshot: h.Snapshot()})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Sum != stats[j].Sum {
+			return stats[i].Sum > stats[j].Sum
+		}
+		return stats[i].Query < stats[j].Query
+	})
+	return stats
+}
+
+// Reset forgets every recorded query
+func (m *Monitor) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queries = nil
+	m.all.reset()
+	m.slow.Store(0)
+}
+
+// normalizeQuery collapses whitespace so that the same statement written
+// across several lines is counted once
+func normalizeQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
+
+// observe times a query run by fn on behalf of m, which may be nil
+func observe[T any](m *Monitor, query string, args []interface{}, fn func() (T, error)) (T, error) {
+	if m == nil {
+		return fn()
+	}
+	start := time.Now()
+	result, err := fn()
+	m.Observe(query, args, time.Since(start), err)
+	return result, err
+}
+
+// MonitoredDB is a *sql.DB whose queries are timed by a Monitor. It can be
+// passed wherever a DBTX is accepted; transactions started by BeginTx or its
+// TxManager are timed too. The connection is not embedded, so that nothing
+// reaches it without going through the monitor.
+type MonitoredDB struct {
+	db      *sql.DB
+	Monitor *Monitor
+	dialect Dialect
+}
+
+// NewMonitoredDB wraps db. A nil monitor creates one with
+// DefaultSlowQueryThreshold.
+func NewMonitoredDB(db *sql.DB, monitor *Monitor) *MonitoredDB {
+	if monitor == nil {
+		monitor = NewMonitor(DefaultSlowQueryThreshold)
+	}
+	return &MonitoredDB{db: db, Monitor: monitor, dialect: DialectOf(db)}
+}
+
+// InitMonitoredDB initializes a connection like InitDBWithConfig and wraps
+// it with a monitor that logs queries slower than Config.SlowQueryThreshold
+func InitMonitoredDB(config *Config) (*MonitoredDB, error) {
+	db, err := InitDBWithConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	threshold := config.SlowQueryThreshold
+	if threshold == 0 {
+		threshold = DefaultSlowQueryThreshold
+	}
+	return NewMonitoredDB(db, NewMonitor(threshold)), nil
+}
+
+// Dialect returns the dialect of the wrapped connection
+func (db *MonitoredDB) Dialect() Dialect {
+	return db.dialect
+}
+
+// TxManager returns a TxManager whose transactions report to the monitor
+func (db *MonitoredDB) TxManager() *TxManager {
+	m := NewTxManager(db.db)
+	m.monitor = db.Monitor
+	return m
+}
+
+// BeginTx starts a transaction whose queries report to the monitor
+func (db *MonitoredDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	return db.TxManager().BeginTx(ctx, opts)
+}
+
+// Stats returns the statistics of the connection pool
+func (db *MonitoredDB) Stats() sql.DBStats {
+	return db.db.Stats()
+}
+
+// Close closes the wrapped connection like CloseDB
+func (db *MonitoredDB) Close() error {
+	return CloseDB(db.db)
+}
+
+// HealthCheck pings the database and runs a trivial query, bounded by
+// DefaultHealthTimeout unless ctx has an earlier deadline. The queries are
+// not recorded by the monitor.
+func (db *MonitoredDB) HealthCheck(ctx context.Context) error {
+	return HealthCheck(ctx, db.db)
+}
+
+// HealthCheck pings db and runs a trivial query, bounded by
+// DefaultHealthTimeout unless ctx has an earlier deadline
+func HealthCheck(ctx context.Context, db *sql.DB) error {
+	if db == nil {
+		return errors.New("database connection cannot be nil")
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultHealthTimeout)
+		defer cancel()
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+	var one int
+	if err := db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		return fmt.Errorf("failed to query database: %w", err)
+	}
+	return nil
+}
+
+// Exec executes a query and records its latency
+func (db *MonitoredDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.ExecContext(context.Background(), query, args...)
+}
+
+// Query runs a query and records its latency
+func (db *MonitoredDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow runs a query and records its latency
+func (db *MonitoredDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query and records its latency
+func (db *MonitoredDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return observe(db.Monitor, query, args, func() (sql.Result, error) {
+		return db.db.ExecContext(ctx, query, args...)
+	})
+}
+
+// QueryContext runs a query and records the latency until its first row is
+// available
+func (db *MonitoredDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return observe(db.Monitor, query, args, func() (*sql.Rows, error) {
+		return db.db.QueryContext(ctx, query, args...)
+	})
+}
+
+// QueryRowContext runs a query and records its latency
+func (db *MonitoredDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row, _ := observe(db.Monitor, query, args, func() (*sql.Row, error) {
+		row := db.db.QueryRowContext(ctx, query, args...)
+		return row, row.Err()
+	})
+	return row
+}
+
+// PrepareContext prepares a statement and records the latency of the
+// preparation. Executions of the statement are not timed, so prefer the
+// query methods; PrepareContext is there for DBTX and GORM.
+func (db *MonitoredDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return observe(db.Monitor, query, nil, func() (*sql.Stmt, error) {
+		return db.db.PrepareContext(ctx, query)
+	})
+}
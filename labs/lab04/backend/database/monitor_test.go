@@ -0,0 +1,165 @@
+package database
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMonitoredDB(t *testing.T) {
+	db, err := InitMonitoredDB(&Config{
+		DatabasePath:       ":memory:",
+		MaxOpenConns:       1,
+		MaxIdleConns:       1,
+		QueryTimeout:       time.Second,
+		SlowQueryThreshold: time.Nanosecond, // Every query is slow
+	})
+	if err != nil {
+		t.Fatalf("InitMonitoredDB() error = %v", err)
+	}
+	defer db.Close()
+	var logs bytes.Buffer
+	db.Monitor.Logger = log.New(&logs, "", 0)
+	ctx := context.Background()
+
+	if got := QueryTimeoutOf(db); got != time.Second {
+		t.Errorf("QueryTimeoutOf() = %v, want 1s", got)
+	}
+	if got := DialectOf(db); got != DialectSQLite {
+		t.Errorf("DialectOf() = %v, want sqlite", got)
+	}
+
+	if _, err := db.ExecContext(ctx, "CREATE TABLE secrets (id INTEGER, value TEXT)"); err != nil {
+		t.Fatalf("ExecContext() error = %v", err)
+	}
+	insert := "INSERT INTO secrets (id, value)\n\tVALUES (?, ?)"
+	if _, err := db.ExecContext(ctx, insert, 1, "hunter2"); err != nil {
+		t.Fatalf("ExecContext() error = %v", err)
+	}
+	err = db.TxManager().WithTx(ctx, func(tx *Tx) error {
+		_, err := tx.ExecContext(ctx, insert, 2, "swordfish")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	var count int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM secrets").Scan(&count); err != nil || count != 2 {
+		t.Fatalf("QueryRowContext() = %d, %v; want 2 rows", count, err)
+	}
+	if _, err := db.QueryContext(ctx, "SELECT * FROM missing"); err == nil {
+		t.Fatal("QueryContext() on a missing table should fail")
+	}
+
+	// Slow queries are logged with their arguments redacted
+	if strings.Contains(logs.String(), "hunter2") || strings.Contains(logs.String(), "swordfish") {
+		t.Errorf("slow query log leaks arguments:\n%s", logs.String())
+	}
+	if !strings.Contains(logs.String(), "INSERT INTO secrets (id, value) VALUES (?, ?) [1 [redacted]]") {
+		t.Errorf("slow query log should show the query and redacted arguments:\n%s", logs.String())
+	}
+	if got := db.Monitor.SlowQueries(); got != 5 {
+		t.Errorf("SlowQueries() = %d, want 5", got)
+	}
+
+	// Statements are counted once whatever their layout, transactions included
+	stats := make(map[string]QueryStats)
+	for _, s := range db.Monitor.QueryStats() {
+		stats[s.Query] = s
+	}
+	if got := stats["INSERT INTO secrets (id, value) VALUES (?, ?)"]; got.Count != 2 {
+		t.Errorf("insert count = %d, want 2", got.Count)
+	}
+	if got := stats["SELECT * FROM missing"]; got.Count != 1 || got.Errors != 1 {
+		t.Errorf("failed query count = %d, errors = %d; want 1, 1", got.Count, got.Errors)
+	}
+	if got := db.Monitor.Latency(); got.Count != 5 || got.Errors != 1 {
+		t.Errorf("Latency() count = %d, errors = %d; want 5, 1", got.Count, got.Errors)
+	}
+	if got := db.Stats().OpenConnections; got != 1 {
+		t.Errorf("Stats().OpenConnections = %d, want 1", got)
+	}
+
+	if err := db.HealthCheck(ctx); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+	if got := db.Monitor.Latency().Count; got != 5 {
+		t.Errorf("HealthCheck() should not be recorded, got %d queries", got)
+	}
+
+	db.Monitor.Reset()
+	if got := db.Monitor.QueryStats(); len(got) != 0 {
+		t.Errorf("QueryStats() after Reset() = %v, want none", got)
+	}
+
+	// Transactions and prepared statements go through the monitor too
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM secrets").Scan(&count); err != nil {
+		t.Fatalf("QueryRowContext() in a transaction error = %v", err)
+	}
+	tx.Rollback()
+	stmt, err := db.PrepareContext(ctx, "SELECT value FROM secrets WHERE id = ?")
+	if err != nil {
+		t.Fatalf("PrepareContext() error = %v", err)
+	}
+	stmt.Close()
+	if got := db.Monitor.Latency().Count; got != 2 {
+		t.Errorf("Latency().Count after a transaction and a prepare = %d, want 2", got)
+	}
+
+	db.Close()
+	if err := db.HealthCheck(ctx); err == nil {
+		t.Error("HealthCheck() on a closed database should fail")
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	for _, d := range []time.Duration{
+		500 * time.Microsecond, 3 * time.Millisecond, 4 * time.Millisecond, 20 * time.Millisecond, 10 * time.Second,
+	} {
+		h.Observe(d, nil)
+	}
+	s := h.Snapshot()
+	if s.Count != 5 || s.Overflow != 1 || s.Max != 10*time.Second {
+		t.Fatalf("Snapshot() = %+v", s)
+	}
+	for _, tt := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0, time.Millisecond},
+		{0.5, 5 * time.Millisecond},
+		{0.8, 25 * time.Millisecond},
+		{1, 10 * time.Second},
+	} {
+		if got := s.Quantile(tt.q); got != tt.want {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if got := (HistogramSnapshot{}).Quantile(0.5); got != 0 {
+		t.Errorf("Quantile() without queries = %v, want 0", got)
+	}
+}
+
+func TestMonitorTrackedQueries(t *testing.T) {
+	m := NewMonitor(0)
+	for i := 0; i < MaxTrackedQueries+10; i++ {
+		m.Observe("SELECT "+strings.Repeat("1,", i)+"1", nil, time.Millisecond, nil)
+	}
+	stats := m.QueryStats()
+	if len(stats) != MaxTrackedQueries+1 {
+		t.Fatalf("len(QueryStats()) = %d, want %d", len(stats), MaxTrackedQueries+1)
+	}
+	for _, s := range stats {
+		if s.Query == OtherQueries && s.Count != 10 {
+			t.Errorf("%s count = %d, want 10", OtherQueries, s.Count)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// queryTimeouts holds the per-query timeout of each open *sql.DB
+var queryTimeouts sync.Map
+
+// SetQueryTimeout sets the default per-query timeout used by repositories
+// for db. InitDBWithConfig sets it from Config.QueryTimeout.
+func SetQueryTimeout(db *sql.DB, timeout time.Duration) {
+	if timeout > 0 {
+		queryTimeouts.Store(db, timeout)
+	} else {
+		queryTimeouts.Delete(db)
+	}
+}
+
+// QueryTimeoutOf returns the per-query timeout of a connection, monitored,
+// wrapped by WithDialect or not, or of a transaction started by TxManager,
+// or 0 if there is none
+func QueryTimeoutOf(db DBTX) time.Duration {
+	switch db := db.(type) {
+	case *sql.DB:
+		if timeout, ok := queryTimeouts.Load(db); ok {
+			return timeout.(time.Duration)
+		}
+	case *Tx:
+		return db.queryTimeout
+	case *MonitoredDB:
+		return QueryTimeoutOf(db.db)
+	case dialectConn:
+		return QueryTimeoutOf(db.DBTX)
+	}
+	return 0
+}
+
+// WithQueryTimeout derives a context bounded by the per-query timeout of db.
+// An earlier deadline already set on ctx is kept.
+func WithQueryTimeout(ctx context.Context, db DBTX) (context.Context, context.CancelFunc) {
+	if timeout := QueryTimeoutOf(db); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueryTimeout(t *testing.T) {
+	db, err := InitDBWithConfig(&Config{
+		DatabasePath: ":memory:",
+		MaxOpenConns: 1,
+		MaxIdleConns: 1,
+		QueryTimeout: time.Second,
+	})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	defer CloseDB(db)
+
+	if got := QueryTimeoutOf(db); got != time.Second {
+		t.Errorf("QueryTimeoutOf(db) = %v, want 1s", got)
+	}
+	if got := QueryTimeoutOf(WithDialect(db, DialectSQLite)); got != time.Second {
+		t.Errorf("QueryTimeoutOf(WithDialect(db)) = %v, want 1s", got)
+	}
+
+	err = NewTxManager(db).WithTx(context.Background(), func(tx *Tx) error {
+		if got := QueryTimeoutOf(tx); got != time.Second {
+			t.Errorf("QueryTimeoutOf(tx) = %v, want 1s", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+
+	ctx, cancel := WithQueryTimeout(context.Background(), db)
+	defer cancel()
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > time.Second {
+		t.Errorf("WithQueryTimeout() deadline = %v, %v; want within 1s", deadline, ok)
+	}
+
+	// An earlier deadline of the caller wins
+	parent, cancelParent := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelParent()
+	ctx, cancel = WithQueryTimeout(parent, db)
+	defer cancel()
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) > time.Millisecond {
+		t.Errorf("WithQueryTimeout() should keep the earlier parent deadline")
+	}
+
+	SetQueryTimeout(db, 0)
+	if got := QueryTimeoutOf(db); got != 0 {
+		t.Errorf("QueryTimeoutOf() after SetQueryTimeout(0) = %v, want 0", got)
+	}
+}
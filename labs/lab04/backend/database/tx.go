@@ -0,0 +1,223 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DBTX is implemented by both *sql.DB and *sql.Tx, so repositories built on
+// it work inside and outside of transactions
+type DBTX interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// Tx is a transaction that remembers its dialect, per-query timeout and
+// savepoint depth
+type Tx struct {
+	*sql.Tx
+	dialect      Dialect
+	queryTimeout time.Duration
+	depth        int
+	monitor      *Monitor // Set for transactions of a MonitoredDB
+}
+
+// Dialect returns the dialect of the connection the transaction runs on
+func (t *Tx) Dialect() Dialect {
+	return t.dialect
+}
+
+// Savepoint runs fn inside a nested savepoint. If fn returns an error or
+// panics, only the work done since the savepoint is rolled back and the
+// outer transaction can continue.
+func (t *Tx) Savepoint(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	nested, err := t.BeginSavepoint(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			nested.RollbackSavepoint(ctx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(nested); err != nil {
+		if rbErr := nested.RollbackSavepoint(ctx); rbErr != nil {
+			return fmt.Errorf("%w (%v)", err, rbErr)
+		}
+		return err
+	}
+	return nested.ReleaseSavepoint(ctx)
+}
+
+// BeginSavepoint creates a savepoint and returns the transaction nested in
+// it, which the caller must release or roll back. Prefer Savepoint, which
+// cannot leak one.
+func (t *Tx) BeginSavepoint(ctx context.Context) (*Tx, error) {
+	nested := &Tx{Tx: t.Tx, dialect: t.dialect, queryTimeout: t.queryTimeout, depth: t.depth + 1, monitor: t.monitor}
+	if _, err := t.ExecContext(ctx, "SAVEPOINT "+nested.savepoint()); err != nil {
+		return nil, fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	return nested, nil
+}
+
+// ReleaseSavepoint keeps the work done since BeginSavepoint returned t
+func (t *Tx) ReleaseSavepoint(ctx context.Context) error {
+	if t.depth == 0 {
+		return errors.New("transaction is not a savepoint")
+	}
+	if _, err := t.ExecContext(ctx, "RELEASE SAVEPOINT "+t.savepoint()); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// RollbackSavepoint undoes the work done since BeginSavepoint returned t
+// and releases the savepoint
+func (t *Tx) RollbackSavepoint(ctx context.Context) error {
+	if t.depth == 0 {
+		return errors.New("transaction is not a savepoint")
+	}
+	if _, err := t.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+t.savepoint()); err != nil {
+		return fmt.Errorf("rollback to savepoint failed: %w", err)
+	}
+	// ROLLBACK TO keeps the savepoint open
+	if _, err := t.ExecContext(ctx, "RELEASE SAVEPOINT "+t.savepoint()); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// savepoint names the savepoint of a nested transaction by its depth
+func (t *Tx) savepoint() string {
+	return fmt.Sprintf("sp_%d", t.depth)
+}
+
+// InTx runs fn in a new transaction on a connection pool, or in a savepoint
+// when db already is a transaction, raw *sql.Tx included. Connections
+// wrapped by WithDialect are unwrapped. Any other connection fails rather
+// than run fn without atomicity.
+func InTx(ctx context.Context, db DBTX, fn func(tx *Tx) error) error {
+	switch conn := db.(type) {
+	case *sql.DB:
+		return NewTxManager(conn).WithTx(ctx, fn)
+	case *MonitoredDB:
+		return conn.TxManager().WithTx(ctx, fn)
+	case *Tx:
+		return conn.Savepoint(ctx, fn)
+	case *sql.Tx:
+		return (&Tx{Tx: conn, dialect: DialectOf(conn)}).Savepoint(ctx, fn)
+	case dialectConn:
+		if tx, ok := conn.DBTX.(*sql.Tx); ok {
+			return (&Tx{Tx: tx, dialect: conn.dialect}).Savepoint(ctx, fn)
+		}
+		return InTx(ctx, conn.DBTX, fn)
+	default:
+		return fmt.Errorf("database: cannot begin a transaction or savepoint on %T", db)
+	}
+}
+
+// TxManager runs functions inside database transactions
+type TxManager struct {
+	db      *sql.DB
+	dialect Dialect
+	monitor *Monitor
+}
+
+// NewTxManager creates a TxManager for db
+func NewTxManager(db *sql.DB) *TxManager {
+	return &TxManager{db: db, dialect: DialectOf(db)}
+}
+
+// Begin starts a transaction that the caller must commit or roll back.
+// Prefer WithTx, which cannot leak one.
+func (m *TxManager) Begin(ctx context.Context) (*Tx, error) {
+	return m.BeginTx(ctx, nil)
+}
+
+// BeginTx is Begin with transaction options, e.g. read-only or an isolation
+// level
+func (m *TxManager) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	sqlTx, err := m.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	return &Tx{Tx: sqlTx, dialect: m.dialect, queryTimeout: QueryTimeoutOf(m.db), monitor: m.monitor}, nil
+}
+
+// WithTx runs fn in a transaction. The transaction is committed if fn
+// returns nil and rolled back if it returns an error or panics; panics are
+// re-raised after the rollback.
+func (m *TxManager) WithTx(ctx context.Context, fn func(tx *Tx) error) (err error) {
+	tx, err := m.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// Exec executes a query in the transaction
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+// Query runs a query in the transaction
+func (t *Tx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+// QueryRow runs a query in the transaction
+func (t *Tx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.QueryRowContext(context.Background(), query, args...)
+}
+
+// ExecContext executes a query in the transaction
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return observe(t.monitor, query, args, func() (sql.Result, error) {
+		return t.Tx.ExecContext(ctx, query, args...)
+	})
+}
+
+// QueryContext runs a query in the transaction
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return observe(t.monitor, query, args, func() (*sql.Rows, error) {
+		return t.Tx.QueryContext(ctx, query, args...)
+	})
+}
+
+// QueryRowContext runs a query in the transaction
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	row, _ := observe(t.monitor, query, args, func() (*sql.Row, error) {
+		row := t.Tx.QueryRowContext(ctx, query, args...)
+		return row, row.Err()
+	})
+	return row
+}
@@ -0,0 +1,199 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func setupTxTestDB(t *testing.T) *sql.DB {
+	db, err := InitDBWithConfig(&Config{DatabasePath: ":memory:", MaxOpenConns: 1, MaxIdleConns: 1})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := db.Exec(`CREATE TABLE items (name TEXT NOT NULL)`); err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	return db
+}
+
+func countItems(t *testing.T, db DBTX) int {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM items`).Scan(&count); err != nil {
+		t.Fatalf("Failed to count items: %v", err)
+	}
+	return count
+}
+
+func insertItem(tx DBTX, name string) error {
+	_, err := tx.Exec(`INSERT INTO items (name) VALUES (?)`, name)
+	return err
+}
+
+func TestTxManager_WithTx(t *testing.T) {
+	ctx := context.Background()
+	db := setupTxTestDB(t)
+	manager := NewTxManager(db)
+
+	err := manager.WithTx(ctx, func(tx *Tx) error {
+		return insertItem(tx, "committed")
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if got := countItems(t, db); got != 1 {
+		t.Fatalf("after commit count = %d, want 1", got)
+	}
+
+	errFailed := errors.New("failed")
+	err = manager.WithTx(ctx, func(tx *Tx) error {
+		if err := insertItem(tx, "rolled back"); err != nil {
+			return err
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("WithTx() error = %v, want %v", err, errFailed)
+	}
+	if got := countItems(t, db); got != 1 {
+		t.Errorf("after rollback count = %d, want 1", got)
+	}
+}
+
+func TestTxManager_RollbackOnPanic(t *testing.T) {
+	db := setupTxTestDB(t)
+	manager := NewTxManager(db)
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithTx() should re-raise the panic")
+			}
+		}()
+		manager.WithTx(context.Background(), func(tx *Tx) error {
+			insertItem(tx, "lost")
+			panic("boom")
+		})
+	}()
+
+	if got := countItems(t, db); got != 0 {
+		t.Errorf("after panic count = %d, want 0", got)
+	}
+}
+
+func TestTx_Savepoint(t *testing.T) {
+	ctx := context.Background()
+	db := setupTxTestDB(t)
+	manager := NewTxManager(db)
+
+	err := manager.WithTx(ctx, func(tx *Tx) error {
+		if err := insertItem(tx, "outer"); err != nil {
+			return err
+		}
+
+		err := tx.Savepoint(ctx, func(tx *Tx) error {
+			if err := insertItem(tx, "inner"); err != nil {
+				return err
+			}
+			return tx.Savepoint(ctx, func(tx *Tx) error {
+				insertItem(tx, "innermost")
+				return errors.New("undo innermost")
+			})
+		})
+		if err == nil {
+			t.Error("Savepoint() should return the nested error")
+		}
+		if got := countItems(t, tx); got != 1 {
+			t.Errorf("inside transaction count = %d, want 1", got)
+		}
+
+		return tx.Savepoint(ctx, func(tx *Tx) error {
+			if tx.Dialect() != DialectSQLite {
+				t.Errorf("Dialect() = %q, want %q", tx.Dialect(), DialectSQLite)
+			}
+			return insertItem(tx, "kept")
+		})
+	})
+	if err != nil {
+		t.Fatalf("WithTx() error = %v", err)
+	}
+	if got := countItems(t, db); got != 2 {
+		t.Errorf("after commit count = %d, want 2", got)
+	}
+}
+
+func TestTx_BeginSavepoint(t *testing.T) {
+	ctx := context.Background()
+	db := setupTxTestDB(t)
+	tx, err := NewTxManager(db).Begin(ctx)
+	if err != nil {
+		t.Fatalf("Begin() error = %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.ReleaseSavepoint(ctx); err == nil {
+		t.Error("ReleaseSavepoint() outside a savepoint should fail")
+	}
+
+	kept, err := tx.BeginSavepoint(ctx)
+	if err != nil {
+		t.Fatalf("BeginSavepoint() error = %v", err)
+	}
+	insertItem(kept, "kept")
+	undone, err := kept.BeginSavepoint(ctx)
+	if err != nil {
+		t.Fatalf("BeginSavepoint() nested error = %v", err)
+	}
+	insertItem(undone, "undone")
+	if err := undone.RollbackSavepoint(ctx); err != nil {
+		t.Fatalf("RollbackSavepoint() error = %v", err)
+	}
+	if err := kept.ReleaseSavepoint(ctx); err != nil {
+		t.Fatalf("ReleaseSavepoint() error = %v", err)
+	}
+	if got := countItems(t, tx); got != 1 {
+		t.Errorf("count = %d, want the released item only", got)
+	}
+
+	// Both savepoints are gone
+	if err := undone.RollbackSavepoint(ctx); err == nil {
+		t.Error("RollbackSavepoint() of a released savepoint should fail")
+	}
+}
+
+type plainConn struct{ DBTX }
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	db := setupTxTestDB(t)
+	errFail := errors.New("fail")
+
+	raw, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() error = %v", err)
+	}
+	defer raw.Rollback()
+
+	for name, conn := range map[string]DBTX{"sql.Tx": raw, "WithDialect": WithDialect(raw, DialectSQLite)} {
+		if err := InTx(ctx, conn, func(tx *Tx) error { return insertItem(tx, name) }); err != nil {
+			t.Fatalf("InTx(%s) error = %v", name, err)
+		}
+		err := InTx(ctx, conn, func(tx *Tx) error {
+			insertItem(tx, "undone")
+			return errFail
+		})
+		if !errors.Is(err, errFail) {
+			t.Fatalf("InTx(%s) error = %v, want %v", name, err, errFail)
+		}
+	}
+	if got := countItems(t, raw); got != 2 {
+		t.Errorf("count = %d, want the two committed savepoints only", got)
+	}
+
+	if err := InTx(ctx, plainConn{raw}, func(tx *Tx) error { return nil }); err == nil {
+		t.Error("InTx() on a connection that cannot begin a transaction should fail")
+	}
+}
@@ -0,0 +1,224 @@
+// Package dbtest gives each test its own migrated SQLite database, so tests
+// neither share state nor need to clean up after one another, and can run
+// in parallel.
+//
+//	db := dbtest.New(t)
+//	users := repository.NewUserRepository(db.Conn)
+//	categories := repository.NewCategoryRepository(db.Gorm)
+//
+// Databases are temporary files copied from a template migrated once per
+// test binary, or shared-cache in-memory databases with InMemory. Rollback
+// runs the test inside a transaction that is rolled back when it ends;
+// Isolate does the same for subtests sharing a database.
+//
+// Test data is loaded with the seed package, from an external test package
+// since seed builds on the repositories:
+//
+//	fixture, err := seed.LoadFile("testdata/blog.yaml")
+//	result, err := seed.Load(ctx, repository.NewUnitOfWorkManager(db.SQL, db.Gorm), fixture)
+package dbtest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"lab04-backend/database"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DB is a test database
+type DB struct {
+	// Conn is what repositories should use: the connection pool, or the
+	// transaction with Rollback and Isolate
+	Conn database.DBTX
+	// SQL is the connection pool. Work done on it directly escapes Rollback.
+	SQL *sql.DB
+	// Gorm runs on Conn
+	Gorm *gorm.DB
+	// Path is the database file, empty for in-memory databases
+	Path string
+}
+
+// Option configures New
+type Option func(*options)
+
+type options struct {
+	inMemory bool
+	rollback bool
+	config   func(*database.Config)
+}
+
+// InMemory uses a shared-cache in-memory database instead of a temporary
+// file. It is migrated for every test, and concurrent writers may fail with
+// "database table is locked" where a file would wait.
+func InMemory() Option {
+	return func(o *options) {
+		o.inMemory = true
+	}
+}
+
+// Rollback runs the test in a transaction that is rolled back when it ends
+func Rollback() Option {
+	return func(o *options) {
+		o.rollback = true
+	}
+}
+
+// WithConfig adjusts the connection configuration, e.g. its pool size or
+// query timeout
+func WithConfig(fn func(*database.Config)) Option {
+	return func(o *options) {
+		o.config = fn
+	}
+}
+
+// New creates a migrated database that is closed and removed when the test
+// and its subtests end
+func New(t testing.TB, opts ...Option) *DB {
+	t.Helper()
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	config := &database.Config{
+		MaxOpenConns: 5,
+		MaxIdleConns: 5,
+		QueryTimeout: database.DefaultConfig().QueryTimeout,
+	}
+	if o.inMemory {
+		// Idle connections are kept open: the database lives as long as one
+		config.DatabasePath = fmt.Sprintf("file:dbtest_%d?mode=memory&cache=shared", memoryDatabases.Add(1))
+	} else {
+		config.DatabasePath = filepath.Join(t.TempDir(), "test.db")
+		if err := copyTemplate(config.DatabasePath); err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+	}
+	if o.config != nil {
+		o.config(config)
+	}
+
+	sqlDB, err := database.InitDBWithConfig(config)
+	if err != nil {
+		t.Fatalf("dbtest: %v", err)
+	}
+	t.Cleanup(func() { database.CloseDB(sqlDB) })
+	if o.inMemory {
+		if err := database.RunMigrations(sqlDB); err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+	}
+
+	gormDB, err := gorm.Open(sqlite.New(sqlite.Config{Conn: sqlDB}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("dbtest: failed to open GORM: %v", err)
+	}
+	db := &DB{Conn: sqlDB, SQL: sqlDB, Gorm: gormDB}
+	if !o.inMemory {
+		db.Path = config.DatabasePath
+	}
+	if o.rollback {
+		db = db.Isolate(t)
+	}
+	return db
+}
+
+// Isolate returns a view of db whose work is done in a transaction rolled
+// back when t ends, so that subtests sharing a database do not see each
+// other's writes. Isolating an isolated database nests a savepoint.
+func (db *DB) Isolate(t testing.TB) *DB {
+	t.Helper()
+	ctx := context.Background()
+
+	var tx *database.Tx
+	switch conn := db.Conn.(type) {
+	case *database.Tx:
+		var err error
+		if tx, err = conn.BeginSavepoint(ctx); err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := tx.RollbackSavepoint(ctx); err != nil {
+				t.Errorf("dbtest: %v", err)
+			}
+		})
+	default:
+		var err error
+		tx, err = database.NewTxManager(db.SQL).Begin(ctx)
+		if err != nil {
+			t.Fatalf("dbtest: %v", err)
+		}
+		t.Cleanup(func() {
+			if err := tx.Rollback(); err != nil {
+				t.Errorf("dbtest: failed to roll back: %v", err)
+			}
+		})
+	}
+
+	// Bind a GORM session to the transaction, the way gorm.DB.Begin does
+	session := db.Gorm.Session(&gorm.Session{NewDB: true})
+	session.Statement.ConnPool = tx
+	return &DB{Conn: tx, SQL: db.SQL, Gorm: session, Path: db.Path}
+}
+
+// memoryDatabases numbers in-memory databases, which are shared by name
+// within the process
+var memoryDatabases atomic.Int64
+
+var template struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// copyTemplate writes a migrated database to path. The template is
+// migrated on first use and kept in memory for the rest of the test binary.
+func copyTemplate(path string) error {
+	template.once.Do(func() {
+		template.data, template.err = buildTemplate()
+	})
+	if template.err != nil {
+		return template.err
+	}
+	return os.WriteFile(path, template.data, 0o600)
+}
+
+func buildTemplate() ([]byte, error) {
+	dir, err := os.MkdirTemp("", "dbtest")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create template directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "template.db")
+	db, err := database.InitDBWithConfig(&database.Config{
+		DatabasePath:    path,
+		MaxOpenConns:    2,
+		MaxIdleConns:    2,
+		ConnMaxLifetime: time.Minute,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := database.RunMigrations(db); err != nil {
+		database.CloseDB(db)
+		return nil, err
+	}
+	if err := database.CloseDB(db); err != nil {
+		return nil, fmt.Errorf("failed to close template: %w", err)
+	}
+	return os.ReadFile(path)
+}
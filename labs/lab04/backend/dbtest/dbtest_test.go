@@ -0,0 +1,104 @@
+package dbtest
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+func count(t *testing.T, db *DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.Conn.QueryRowContext(context.Background(), "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return n
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"file", nil},
+		{"in memory", []Option{InMemory()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			a, b := New(t, tt.opts...), New(t, tt.opts...)
+			if _, err := a.Conn.Exec("INSERT INTO users (name, email) VALUES ('Ada', 'ada@example.com')"); err != nil {
+				t.Fatalf("insert into a migrated database failed: %v", err)
+			}
+			if got := count(t, a, "users"); got != 1 {
+				t.Errorf("users in a = %d, want 1", got)
+			}
+			if got := count(t, b, "users"); got != 0 {
+				t.Errorf("users in b = %d, want 0: databases should be isolated", got)
+			}
+			if (a.Path == "") != (tt.name == "in memory") {
+				t.Errorf("Path = %q", a.Path)
+			}
+		})
+	}
+}
+
+func TestNew_Cleanup(t *testing.T) {
+	var path string
+	t.Run("test", func(t *testing.T) {
+		path = New(t).Path
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("database file missing: %v", err)
+		}
+	})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("database file should be removed after the test, stat error = %v", err)
+	}
+}
+
+func exec(t *testing.T, db *DB, query string) {
+	t.Helper()
+	if _, err := db.Conn.ExecContext(context.Background(), query); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	t.Parallel()
+	db := New(t)
+	exec(t, db, "INSERT INTO users (id, name, email) VALUES (1, 'Ada Lovelace', 'ada@example.com')")
+	exec(t, db, "INSERT INTO posts (user_id, title) VALUES (1, 'Notes on the engine'), (1, 'Computing machinery')")
+
+	t.Run("isolated", func(t *testing.T) {
+		isolated := db.Isolate(t)
+		if _, err := isolated.Conn.Exec("DELETE FROM posts"); err != nil {
+			t.Fatalf("delete failed: %v", err)
+		}
+		t.Run("nested", func(t *testing.T) {
+			nested := isolated.Isolate(t)
+			exec(t, nested, "INSERT INTO posts (user_id, title) VALUES (1, 'Nested post')")
+			t.Run("deeper", func(t *testing.T) {
+				exec(t, nested.Isolate(t), "DELETE FROM posts")
+			})
+			if got := count(t, nested, "posts"); got != 1 {
+				t.Errorf("posts in the nested savepoint = %d, want 1", got)
+			}
+		})
+		if got := count(t, isolated, "posts"); got != 0 {
+			t.Errorf("posts after the nested subtest = %d, want 0", got)
+		}
+
+		var categories int64
+		if err := isolated.Gorm.Table("categories").Count(&categories).Error; err != nil {
+			t.Fatalf("GORM query in the transaction failed: %v", err)
+		}
+	})
+	if got := count(t, db, "posts"); got != 2 {
+		t.Errorf("posts after the isolated subtest = %d, want the 2 inserted first", got)
+	}
+
+	rolledBack := New(t, Rollback())
+	if rolledBack.Conn == rolledBack.SQL {
+		t.Error("Rollback() should run on a transaction")
+	}
+}
@@ -0,0 +1,303 @@
+// Package filter is a small, typed filter and sort language for list
+// endpoints. A Schema whitelists the fields of one table with their types and
+// the operators they support; conditions and sort orders are checked against
+// it and compiled to squirrel conditions with bound parameters, so user input
+// never reaches the SQL text.
+//
+// From a URL query string:
+//
+//	?filter=published:eq:true&filter=created_at:gte:2025-07-01&sort=-created_at,title
+//
+// and in Go:
+//
+//	filter.Condition{Field: "published", Op: filter.Eq, Value: true}
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is wrapped by every error about a field, operator, value or
+// sort order that the schema does not accept
+var ErrInvalid = errors.New("invalid filter")
+
+// Op is a comparison operator
+type Op string
+
+const (
+	Eq   Op = "eq"   // equal
+	Ne   Op = "ne"   // not equal
+	Lt   Op = "lt"   // less than
+	Lte  Op = "lte"  // less than or equal
+	Gt   Op = "gt"   // greater than
+	Gte  Op = "gte"  // greater than or equal
+	Like Op = "like" // contains, case-insensitively
+	In   Op = "in"   // one of a comma-separated list
+	Null Op = "null" // IS NULL when true, IS NOT NULL when false
+)
+
+// Kind is the type of a field's values
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Bool
+	Time
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Int:
+		return "integer"
+	case Bool:
+		return "boolean"
+	case Time:
+		return "time"
+	default:
+		return "string"
+	}
+}
+
+// operators lists the operators each kind supports; Null is added for
+// nullable fields
+var operators = map[Kind][]Op{
+	String: {Eq, Ne, Like, In},
+	Int:    {Eq, Ne, Lt, Lte, Gt, Gte, In},
+	Bool:   {Eq, Ne},
+	Time:   {Eq, Ne, Lt, Lte, Gt, Gte},
+}
+
+// Field is a column that can be filtered on
+type Field struct {
+	Name     string // Name used in filters and sort orders
+	Column   string // Column in SQL; defaults to Name
+	Kind     Kind
+	Nullable bool // Allows the null operator
+	Sortable bool // Allows sorting by the field
+}
+
+func (f Field) column() string {
+	if f.Column != "" {
+		return f.Column
+	}
+	return f.Name
+}
+
+func (f Field) allows(op Op) bool {
+	if op == Null {
+		return f.Nullable
+	}
+	for _, allowed := range operators[f.Kind] {
+		if op == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Condition compares a field with a value. Value is converted to the kind of
+// the field, so strings such as "true" or "2025-07-01" are accepted for any
+// kind; In takes a slice or a comma-separated string.
+type Condition struct {
+	Field string
+	Op    Op
+	Value interface{}
+}
+
+func (c Condition) String() string {
+	return fmt.Sprintf("%s:%s:%v", c.Field, c.Op, c.Value)
+}
+
+// Sort orders results by a field, ascending unless Desc is set
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// Query is a parsed filter and sort request
+type Query struct {
+	Filters []Condition
+	Sort    []Sort
+}
+
+// MaxConditions bounds the number of conditions in one request
+const MaxConditions = 20
+
+// MaxInValues bounds the number of values of one in condition
+const MaxInValues = 100
+
+// Schema is the whitelist of fields for one table
+type Schema struct {
+	fields map[string]Field
+	// tieBreaker is appended to every sort order so that pages are stable
+	tieBreaker string
+}
+
+// NewSchema creates a schema from fields. Sort orders end with tieBreaker,
+// usually the primary key, when it is not empty.
+func NewSchema(tieBreaker string, fields ...Field) *Schema {
+	s := &Schema{fields: make(map[string]Field, len(fields)), tieBreaker: tieBreaker}
+	for _, field := range fields {
+		s.fields[field.Name] = field
+	}
+	return s
+}
+
+// Field returns the field with the given name
+func (s *Schema) Field(name string) (Field, bool) {
+	field, ok := s.fields[name]
+	return field, ok
+}
+
+// Check validates conditions and returns them with their values converted
+// to the kinds of their fields
+func (s *Schema) Check(conditions ...Condition) ([]Condition, error) {
+	if len(conditions) > MaxConditions {
+		return nil, fmt.Errorf("%w: at most %d conditions are allowed", ErrInvalid, MaxConditions)
+	}
+	checked := make([]Condition, len(conditions))
+	for i, c := range conditions {
+		field, ok := s.fields[c.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalid, c.Field)
+		}
+		if !field.allows(c.Op) {
+			return nil, fmt.Errorf("%w: operator %q is not supported on %s", ErrInvalid, c.Op, c.Field)
+		}
+		value, err := convert(field, c.Op, c.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalid, c.Field, err)
+		}
+		checked[i] = Condition{Field: c.Field, Op: c.Op, Value: value}
+	}
+	return checked, nil
+}
+
+// OrderBy returns ORDER BY terms for sorts, followed by the tie breaker in
+// the direction of the last sort
+func (s *Schema) OrderBy(sorts ...Sort) ([]string, error) {
+	terms := make([]string, 0, len(sorts)+1)
+	desc := false
+	for _, sort := range sorts {
+		field, ok := s.fields[sort.Field]
+		if !ok || !field.Sortable {
+			return nil, fmt.Errorf("%w: cannot sort by %q", ErrInvalid, sort.Field)
+		}
+		terms = append(terms, field.column()+direction(sort.Desc))
+		if field.column() == s.tieBreaker {
+			return terms, nil
+		}
+		desc = sort.Desc
+	}
+	if s.tieBreaker != "" {
+		terms = append(terms, s.tieBreaker+direction(desc))
+	}
+	return terms, nil
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " DESC"
+	}
+	return " ASC"
+}
+
+// convert converts a value to the kind of field; In and Null take a list and
+// a boolean respectively
+func convert(field Field, op Op, value interface{}) (interface{}, error) {
+	switch op {
+	case Null:
+		return convertValue(Bool, value)
+	case In:
+		var items []interface{}
+		switch v := reflect.ValueOf(value); v.Kind() {
+		case reflect.String:
+			for _, item := range strings.Split(v.String(), ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		case reflect.Slice, reflect.Array:
+			for i := 0; i < v.Len(); i++ {
+				items = append(items, v.Index(i).Interface())
+			}
+		default:
+			return nil, fmt.Errorf("in needs a list, not %T", value)
+		}
+		if len(items) == 0 {
+			return nil, errors.New("in needs at least one value")
+		}
+		if len(items) > MaxInValues {
+			return nil, fmt.Errorf("in takes at most %d values", MaxInValues)
+		}
+		converted := make([]interface{}, len(items))
+		for i, item := range items {
+			v, err := convertValue(field.Kind, item)
+			if err != nil {
+				return nil, err
+			}
+			converted[i] = v
+		}
+		return converted, nil
+	default:
+		return convertValue(field.Kind, value)
+	}
+}
+
+// timeLayouts are the accepted formats of times given as strings
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+func convertValue(kind Kind, value interface{}) (interface{}, error) {
+	v := reflect.ValueOf(value)
+	switch {
+	case v.Kind() == reflect.String:
+		return parseValue(kind, v.String())
+	case kind == Int && v.CanInt():
+		return v.Int(), nil
+	case kind == Int && v.CanUint():
+		return int64(v.Uint()), nil
+	case kind == Bool && v.Kind() == reflect.Bool:
+		return v.Bool(), nil
+	}
+	if t, ok := value.(time.Time); ok && kind == Time {
+		return t.UTC(), nil
+	}
+	return nil, fmt.Errorf("%v is not a %s", value, kind)
+}
+
+func parseValue(kind Kind, s string) (interface{}, error) {
+	switch kind {
+	case Int:
+		n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", s)
+		}
+		return n, nil
+	case Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", s)
+		}
+		return b, nil
+	case Time:
+		for _, layout := range timeLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a time, want RFC 3339 or YYYY-MM-DD", s)
+	default:
+		return s, nil
+	}
+}
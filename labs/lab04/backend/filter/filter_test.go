@@ -0,0 +1,130 @@
+package filter
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"lab04-backend/database"
+
+	"github.com/Masterminds/squirrel"
+)
+
+var testSchema = NewSchema("id",
+	Field{Name: "id", Kind: Int, Sortable: true},
+	Field{Name: "title", Kind: String, Sortable: true},
+	Field{Name: "published", Kind: Bool},
+	Field{Name: "created_at", Kind: Time, Sortable: true},
+	Field{Name: "author", Column: "user_id", Kind: Int},
+	Field{Name: "publish_at", Kind: Time, Nullable: true},
+)
+
+func TestParse(t *testing.T) {
+	values, _ := url.ParseQuery("filter=published:eq:true&filter=created_at:gte:2025-07-01T09:30:00Z" +
+		"&filter=author:in:1,2&sort=-created_at,title")
+	q, err := testSchema.Parse(values)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	want := Query{
+		Filters: []Condition{
+			{Field: "published", Op: Eq, Value: true},
+			{Field: "created_at", Op: Gte, Value: time.Date(2025, 7, 1, 9, 30, 0, 0, time.UTC)},
+			{Field: "author", Op: In, Value: []interface{}{int64(1), int64(2)}},
+		},
+		Sort: []Sort{{Field: "created_at", Desc: true}, {Field: "title"}},
+	}
+	if !reflect.DeepEqual(q, want) {
+		t.Errorf("Parse() = %+v, want %+v", q, want)
+	}
+
+	for _, raw := range []string{
+		"filter=password:eq:x",        // unknown field
+		"filter=title:gt:a",           // operator not allowed on strings
+		"filter=published:eq:maybe",   // not a boolean
+		"filter=created_at:null:true", // not nullable
+		"filter=title",                // malformed
+		"sort=published",              // not sortable
+		"sort=title,",                 // empty field
+	} {
+		values, _ := url.ParseQuery(raw)
+		if _, err := testSchema.Parse(values); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalid", raw, err)
+		}
+	}
+}
+
+func TestWhere(t *testing.T) {
+	where := testSchema.Where(database.DialectPostgres,
+		Condition{Field: "title", Op: Like, Value: "go"},
+		Condition{Field: "author", Op: Ne, Value: 3},
+		Condition{Field: "publish_at", Op: Null, Value: "false"},
+	)
+	query, args, err := squirrel.Select("id").From("posts").Where(where).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		t.Fatalf("ToSql() error = %v", err)
+	}
+	wantSQL := `SELECT id FROM posts WHERE (title ILIKE $1 ESCAPE '\' AND user_id <> $2 AND publish_at IS NOT NULL)`
+	if query != wantSQL {
+		t.Errorf("ToSql() = %q, want %q", query, wantSQL)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"%go%", int64(3)}) {
+		t.Errorf("ToSql() args = %v", args)
+	}
+
+	// Wildcards in a like value match themselves
+	like := testSchema.Where(database.DialectSQLite, Condition{Field: "title", Op: Like, Value: `50%_off\`})
+	if _, args, _ := like.ToSql(); !reflect.DeepEqual(args, []interface{}{`%50\%\_off\\%`}) {
+		t.Errorf("ToSql() args = %q, want the wildcards escaped", args)
+	}
+
+	// Values are bound, never written into the SQL
+	injection := testSchema.Where(database.DialectSQLite, Condition{Field: "title", Op: Eq, Value: "'; DROP TABLE posts; --"})
+	if query, _, _ := injection.ToSql(); query != "(title = ?)" {
+		t.Errorf("ToSql() = %q, want a bound parameter", query)
+	}
+
+	invalid := testSchema.Where(database.DialectSQLite, Condition{Field: "title; DROP TABLE posts", Op: Eq, Value: "x"})
+	if _, _, err := squirrel.Select("id").From("posts").Where(invalid).ToSql(); !errors.Is(err, ErrInvalid) {
+		t.Errorf("ToSql() with an unknown field error = %v, want ErrInvalid", err)
+	}
+}
+
+func TestCheck_Limits(t *testing.T) {
+	conditions := make([]Condition, MaxConditions+1)
+	for i := range conditions {
+		conditions[i] = Condition{Field: "id", Op: Eq, Value: i}
+	}
+	if _, err := testSchema.Check(conditions...); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Check() with %d conditions error = %v, want ErrInvalid", len(conditions), err)
+	}
+
+	values := make([]int, MaxInValues)
+	if _, err := testSchema.Check(Condition{Field: "id", Op: In, Value: values}); err != nil {
+		t.Errorf("Check() with %d in values error = %v", len(values), err)
+	}
+	values = append(values, 0)
+	if _, err := testSchema.Check(Condition{Field: "id", Op: In, Value: values}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Check() with %d in values error = %v, want ErrInvalid", len(values), err)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	tests := []struct {
+		sorts []Sort
+		want  []string
+	}{
+		{nil, []string{"id ASC"}},
+		{[]Sort{{Field: "created_at", Desc: true}}, []string{"created_at DESC", "id DESC"}},
+		{[]Sort{{Field: "id", Desc: true}, {Field: "title"}}, []string{"id DESC"}},
+	}
+	for _, tt := range tests {
+		got, err := testSchema.OrderBy(tt.sorts...)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("OrderBy(%v) = %v, %v; want %v", tt.sorts, got, err, tt.want)
+		}
+	}
+}
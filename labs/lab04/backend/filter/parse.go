@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Query string parameters read by Parse
+const (
+	FilterParam = "filter" // field:op:value, repeated for several conditions
+	SortParam   = "sort"   // comma-separated fields, each prefixed with - for descending
+)
+
+// Parse reads the filter and sort parameters of a URL query string and
+// checks them against the schema
+func (s *Schema) Parse(values url.Values) (Query, error) {
+	var q Query
+	for _, raw := range values[FilterParam] {
+		c, err := ParseCondition(raw)
+		if err != nil {
+			return Query{}, err
+		}
+		q.Filters = append(q.Filters, c)
+	}
+	filters, err := s.Check(q.Filters...)
+	if err != nil {
+		return Query{}, err
+	}
+	q.Filters = filters
+
+	for _, raw := range values[SortParam] {
+		sorts, err := ParseSort(raw)
+		if err != nil {
+			return Query{}, err
+		}
+		q.Sort = append(q.Sort, sorts...)
+	}
+	if _, err := s.OrderBy(q.Sort...); err != nil {
+		return Query{}, err
+	}
+	return q, nil
+}
+
+// ParseCondition parses "field:op:value". The value is kept as a string and
+// may contain colons, as times do.
+func ParseCondition(raw string) (Condition, error) {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+		return Condition{}, fmt.Errorf("%w: %q, want field:operator:value", ErrInvalid, raw)
+	}
+	return Condition{Field: parts[0], Op: Op(strings.ToLower(parts[1])), Value: parts[2]}, nil
+}
+
+// ParseSort parses a comma-separated list of fields such as "-created_at,title"
+func ParseSort(raw string) ([]Sort, error) {
+	var sorts []Sort
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		sort := Sort{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		if sort.Field == "" {
+			return nil, fmt.Errorf("%w: empty field in sort %q", ErrInvalid, raw)
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
@@ -0,0 +1,74 @@
+package filter
+
+import (
+	"lab04-backend/database"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// Where returns the conditions ANDed together as a squirrel condition for
+// dialect. The conditions are checked when the SQL is built, so an invalid
+// one makes ToSql of the whole query fail with an error wrapping ErrInvalid.
+func (s *Schema) Where(dialect database.Dialect, conditions ...Condition) squirrel.Sqlizer {
+	return where{schema: s, dialect: dialect, conditions: conditions}
+}
+
+type where struct {
+	schema     *Schema
+	dialect    database.Dialect
+	conditions []Condition
+}
+
+func (w where) ToSql() (string, []interface{}, error) {
+	conditions, err := w.schema.Check(w.conditions...)
+	if err != nil {
+		return "", nil, err
+	}
+	and := squirrel.And{}
+	for _, c := range conditions {
+		and = append(and, w.sqlizer(c))
+	}
+	return and.ToSql()
+}
+
+// sqlizer compiles a checked condition
+func (w where) sqlizer(c Condition) squirrel.Sqlizer {
+	column := w.schema.fields[c.Field].column()
+	switch c.Op {
+	case Ne:
+		return squirrel.NotEq{column: c.Value}
+	case Lt:
+		return squirrel.Lt{column: c.Value}
+	case Lte:
+		return squirrel.LtOrEq{column: c.Value}
+	case Gt:
+		return squirrel.Gt{column: c.Value}
+	case Gte:
+		return squirrel.GtOrEq{column: c.Value}
+	case Like:
+		return w.dialect.ILike(column, "%"+database.EscapeLike(c.Value.(string))+"%")
+	case Null:
+		if c.Value.(bool) {
+			return squirrel.Eq{column: nil}
+		}
+		return squirrel.NotEq{column: nil}
+	default: // Eq and In; squirrel turns a slice into IN
+		return squirrel.Eq{column: c.Value}
+	}
+}
+
+// Apply adds the filters and sort order of q to query. Without a sort order
+// the query is left unordered.
+func (s *Schema) Apply(query squirrel.SelectBuilder, dialect database.Dialect, q Query) (squirrel.SelectBuilder, error) {
+	if len(q.Filters) > 0 {
+		query = query.Where(s.Where(dialect, q.Filters...))
+	}
+	if len(q.Sort) > 0 {
+		orderBy, err := s.OrderBy(q.Sort...)
+		if err != nil {
+			return query, err
+		}
+		query = query.OrderBy(orderBy...)
+	}
+	return query, nil
+}
@@ -1,13 +1,23 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"lab04-backend/database"
 	"lab04-backend/repository"
+	"lab04-backend/seed"
+	"lab04-backend/transfer"
 
 	_ "github.com/mattn/go-sqlite3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -23,6 +33,26 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	if len(os.Args) > 1 {
+		var err error
+		switch os.Args[1] {
+		case "seed":
+			err = runSeed(db, os.Args[2:])
+		case "import":
+			err = runImport(db, os.Args[2:])
+		case "export":
+			err = runExport(db, os.Args[2:])
+		case "publish":
+			err = runPublish(db, os.Args[2:])
+		default:
+			log.Fatalf("Unknown command %q, want seed, import, export or publish", os.Args[1])
+		}
+		if err != nil {
+			log.Fatalf("Failed to %s: %v", os.Args[1], err)
+		}
+		return
+	}
+
 	// TODO: Create repository instances
 	userRepo := repository.NewUserRepository(db)
 	postRepo := repository.NewPostRepository(db)
@@ -31,7 +61,157 @@ func main() {
 	fmt.Println("Database initialized successfully!")
 	fmt.Printf("User repository: %T\n", userRepo)
 	fmt.Printf("Post repository: %T\n", postRepo)
+	fmt.Println("Run 'go run . seed -help' to fill the database with demo data")
+	fmt.Println("Run 'go run . import -help' or 'go run . export -help' to move data between databases")
+	fmt.Println("Run 'go run . publish' to publish scheduled posts as they come due")
+}
+
+// runSeed handles "go run . seed [flags]": it loads a fixture file or
+// generates one, and optionally writes the generated fixture out
+func runSeed(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("seed", flag.ExitOnError)
+	profileName := flags.String("profile", "small", "size of generated data: small, medium or large")
+	seedValue := flags.Uint64("seed", 1, "random seed; the same seed generates the same data")
+	fixturePath := flags.String("fixture", "", "load this .yaml or .json fixture instead of generating data")
+	outPath := flags.String("out", "", "write the fixture to this .yaml or .json file instead of loading it")
+	flags.Parse(args)
+
+	var fixture *seed.Fixture
+	if *fixturePath != "" {
+		var err error
+		if fixture, err = seed.LoadFile(*fixturePath); err != nil {
+			return err
+		}
+	} else {
+		profile, err := seed.ProfileByName(*profileName)
+		if err != nil {
+			return err
+		}
+		fixture = seed.Generate(*seedValue, profile)
+	}
+
+	if *outPath != "" {
+		if err := fixture.WriteFile(*outPath); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote fixture to %s\n", *outPath)
+		return nil
+	}
+
+	gormDB, err := gorm.Open(sqlite.New(sqlite.Config{Conn: db}), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to open GORM: %w", err)
+	}
+	result, err := seed.Load(context.Background(), repository.NewUnitOfWorkManager(db, gormDB), fixture)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Seeded %d users, %d categories, %d posts and %d comments\n",
+		len(result.Users), len(result.Categories), len(result.Posts), result.Comments)
+	return nil
+}
+
+// transferFlags parses the flags shared by import and export and returns the
+// entity, the file path and its format
+func transferFlags(name string, args []string, extra func(*flag.FlagSet)) (transfer.Entity, string, transfer.Format, error) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	entityName := flags.String("entity", "", "table to "+name+": users, posts or categories")
+	path := flags.String("file", "", "the .csv or .ndjson file; the format follows the extension")
+	if extra != nil {
+		extra(flags)
+	}
+	flags.Parse(args)
+
+	entity, err := transfer.ParseEntity(*entityName)
+	if err != nil {
+		return "", "", "", err
+	}
+	format, err := transfer.FormatOf(*path)
+	if err != nil {
+		return "", "", "", err
+	}
+	return entity, *path, format, nil
+}
+
+// runImport handles "go run . import -entity users -file users.csv". Rows
+// that fail are listed and skipped; the rest are imported.
+func runImport(db *sql.DB, args []string) error {
+	var batchSize int
+	entity, path, format, err := transferFlags("import", args, func(flags *flag.FlagSet) {
+		flags.IntVar(&batchSize, "batch", transfer.DefaultBatchSize, "rows inserted per transaction")
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gormDB, err := gorm.Open(sqlite.New(sqlite.Config{Conn: db}), &gorm.Config{})
+	if err != nil {
+		return fmt.Errorf("failed to open GORM: %w", err)
+	}
+	importer := transfer.NewImporter(repository.NewUnitOfWorkManager(db, gormDB), batchSize)
+	report, err := importer.Import(context.Background(), entity, file, format)
+	if report != nil {
+		for _, rowErr := range report.Errors {
+			fmt.Println(rowErr)
+		}
+		fmt.Printf("Imported %d of %d %s, %d failed\n", report.Imported, report.Rows, entity, report.Failed())
+	}
+	return err
+}
+
+// runExport handles "go run . export -entity posts -file posts.ndjson"
+func runExport(db *sql.DB, args []string) error {
+	entity, path, format, err := transferFlags("export", args, nil)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	n, err := transfer.NewExporter(db).Export(context.Background(), entity, file, format)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d %s to %s\n", n, entity, path)
+	return nil
+}
+
+// runPublish handles "go run . publish [-interval 1m]": it publishes
+// scheduled posts as they come due until interrupted
+func runPublish(db *sql.DB, args []string) error {
+	flags := flag.NewFlagSet("publish", flag.ExitOnError)
+	interval := flags.Duration("interval", repository.DefaultPublishInterval, "how often to look for due posts")
+	once := flags.Bool("once", false, "publish the posts due now and exit")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	publisher := repository.NewPublisher(repository.NewPostRepository(db), *interval)
+	publisher.OnPublish = func(ids []int) {
+		log.Printf("Published posts %v", ids)
+	}
+	if *once {
+		ids, err := publisher.RunOnce(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Published %d posts\n", len(ids))
+		return nil
+	}
 
-	// TODO: Add some demo data operations here
-	// You can test your CRUD operations
+	log.Printf("Publishing scheduled posts every %s; press Ctrl+C to stop", *interval)
+	publisher.Run(ctx)
+	return nil
 }
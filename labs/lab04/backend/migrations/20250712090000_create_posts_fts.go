@@ -0,0 +1,87 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/pressly/goose/v3"
+)
+
+func init() {
+	goose.AddMigrationContext(upCreatePostsFTS, downCreatePostsFTS)
+}
+
+// postsFTSUp creates an external content FTS5 index over posts, kept in sync
+// by triggers, and fills it with the existing posts
+var postsFTSUp = []string{
+	`CREATE VIRTUAL TABLE posts_fts USING fts5(title, content, content='posts', content_rowid='id')`,
+	`CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+		INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	`CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+		INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+	END`,
+	`CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, content ON posts BEGIN
+		INSERT INTO posts_fts(posts_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+		INSERT INTO posts_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+	END`,
+	`INSERT INTO posts_fts(posts_fts) VALUES ('rebuild')`,
+}
+
+var postsFTSDown = []string{
+	`DROP TRIGGER IF EXISTS posts_fts_update`,
+	`DROP TRIGGER IF EXISTS posts_fts_delete`,
+	`DROP TRIGGER IF EXISTS posts_fts_insert`,
+	`DROP TABLE IF EXISTS posts_fts`,
+}
+
+// upCreatePostsFTS creates posts_fts, the FTS5 index of posts, when the
+// database is SQLite with FTS5. go-sqlite3 only has FTS5 when built with the
+// sqlite_fts5 tag, and PostgreSQL has no FTS5 at all; without it there is no
+// index and search falls back to LIKE.
+func upCreatePostsFTS(ctx context.Context, tx *sql.Tx) error {
+	available, err := fts5Available(ctx, tx)
+	if err != nil || !available {
+		return err
+	}
+	return execAll(ctx, tx, postsFTSUp)
+}
+
+func downCreatePostsFTS(ctx context.Context, tx *sql.Tx) error {
+	if isPostgres(ctx, tx) {
+		return nil
+	}
+	return execAll(ctx, tx, postsFTSDown)
+}
+
+// fts5Available reports whether tx runs on SQLite compiled with FTS5
+func fts5Available(ctx context.Context, tx *sql.Tx) (bool, error) {
+	if isPostgres(ctx, tx) {
+		return false, nil
+	}
+	var enabled bool
+	if err := tx.QueryRowContext(ctx, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled); err != nil {
+		return false, fmt.Errorf("failed to detect FTS5: %w", err)
+	}
+	return enabled, nil
+}
+
+// isPostgres reports whether tx runs on PostgreSQL. SQLite has no version()
+// and rejects the query before running it, which leaves tx usable.
+func isPostgres(ctx context.Context, tx *sql.Tx) bool {
+	var version string
+	err := tx.QueryRowContext(ctx, `SELECT version()`).Scan(&version)
+	return err == nil && strings.HasPrefix(version, "PostgreSQL")
+}
+
+// execAll runs statements in order
+func execAll(ctx context.Context, tx *sql.Tx, statements []string) error {
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to run %q: %w", statement, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+// Package migrations embeds the goose SQL migrations, so binaries and tests
+// can run them from any working directory. SQLite migrations are at the root
+// of FS and PostgreSQL migrations under postgres/. Go migrations register
+// themselves with goose for both dialects when the package is imported.
+package migrations
+
+import "embed"
+
+// FS holds the SQL migrations for every dialect
+//
+//go:embed *.sql postgres/*.sql
+var FS embed.FS
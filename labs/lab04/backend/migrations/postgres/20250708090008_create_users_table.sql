@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create users table with proper schema (PostgreSQL)
+CREATE TABLE users (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(255) UNIQUE NOT NULL,
+    password_hash VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMPTZ NULL
+);
+
+-- Create index for efficient email lookups
+CREATE INDEX idx_users_email ON users(email);
+
+-- Create index for soft delete queries
+CREATE INDEX idx_users_deleted_at ON users(deleted_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Drop the users table and its indexes
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_users_email;
+DROP TABLE users;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Create audit_log table recording who changed which row and how (PostgreSQL)
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    entity_type VARCHAR(50) NOT NULL, -- user, post or category
+    entity_id INTEGER NOT NULL,
+    action VARCHAR(20) NOT NULL, -- create, update, delete, restore or purge
+    changes JSONB NOT NULL DEFAULT '{}', -- JSON object of {"field": {"old": ..., "new": ...}}
+    created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create index for reading the history of an entity
+CREATE INDEX idx_audit_log_entity ON audit_log(entity_type, entity_id);
+
+-- Create index for reading the changes made by an actor
+CREATE INDEX idx_audit_log_actor ON audit_log(actor);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_audit_log_actor;
+DROP INDEX IF EXISTS idx_audit_log_entity;
+DROP TABLE audit_log;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add the publishing workflow state and URL slug to posts (PostgreSQL)
+ALTER TABLE posts ADD COLUMN slug VARCHAR(220) NULL;
+ALTER TABLE posts ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'draft'; -- draft, scheduled, published or archived
+ALTER TABLE posts ADD COLUMN publish_at TIMESTAMPTZ NULL;
+
+-- Existing posts keep their published flag and get a slug from their ID
+UPDATE posts SET status = 'published', publish_at = created_at WHERE published;
+UPDATE posts SET slug = 'post-' || id;
+
+-- Create unique index for permalinks, including soft deleted posts
+CREATE UNIQUE INDEX idx_posts_slug ON posts(slug);
+
+-- Create index for the publisher's scheduled post lookup
+CREATE INDEX idx_posts_status_publish_at ON posts(status, publish_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Drop the workflow columns and their indexes
+DROP INDEX IF EXISTS idx_posts_status_publish_at;
+DROP INDEX IF EXISTS idx_posts_slug;
+ALTER TABLE posts DROP COLUMN publish_at;
+ALTER TABLE posts DROP COLUMN status;
+ALTER TABLE posts DROP COLUMN slug;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Add parent/child categories as a materialized path, e.g. '/1/4/7/' for
+-- category 7 under 4 under 1, and a manual order among siblings
+ALTER TABLE categories ADD COLUMN parent_id INTEGER NULL;
+ALTER TABLE categories ADD COLUMN path VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE categories ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE categories ADD COLUMN sort_order INTEGER NOT NULL DEFAULT 0;
+
+-- Existing categories become roots, ordered by name
+UPDATE categories SET path = '/' || id || '/';
+UPDATE categories SET sort_order = (SELECT COUNT(*) FROM categories c WHERE c.name < categories.name);
+
+-- Create indexes for listing children and for subtree lookups by path prefix
+CREATE INDEX idx_categories_parent_id ON categories(parent_id, sort_order);
+CREATE INDEX idx_categories_path ON categories(path varchar_pattern_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Drop the hierarchy columns and their indexes
+DROP INDEX IF EXISTS idx_categories_path;
+DROP INDEX IF EXISTS idx_categories_parent_id;
+ALTER TABLE categories DROP COLUMN sort_order;
+ALTER TABLE categories DROP COLUMN depth;
+ALTER TABLE categories DROP COLUMN path;
+ALTER TABLE categories DROP COLUMN parent_id;
+-- +goose StatementEnd
@@ -1,8 +1,15 @@
 package models
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
+	"lab04-backend/audit"
+
 	"gorm.io/gorm"
 )
 
@@ -18,10 +25,34 @@ type Category struct {
 	UpdatedAt   time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
 	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"` // Soft delete support
 
+	// Hierarchy: ParentID is nil for a root category. Path lists the IDs from
+	// the root down to the category itself, e.g. "/1/4/7/", and is
+	// maintained together with Depth by the hooks and CategoryRepository.Move.
+	ParentID  *uint  `json:"parent_id,omitempty" gorm:"index"`
+	Path      string `json:"path" gorm:"size:255;not null"`
+	Depth     int    `json:"depth" gorm:"not null"`
+	SortOrder int    `json:"sort_order" gorm:"not null"` // Position among siblings
+
 	// GORM Associations (demonstrates ORM relationships)
 	Posts []Post `json:"posts,omitempty" gorm:"many2many:post_categories;"`
+
+	// Children is filled in by CategoryRepository.GetTree only
+	Children []Category `json:"children,omitempty" gorm:"-"`
 }
 
+// MaxCategoryDepth limits how deeply categories nest, so that paths fit
+// their column
+const MaxCategoryDepth = 10
+
+var (
+	// ErrCategoryTooDeep is returned when a category would be nested more
+	// than MaxCategoryDepth levels deep
+	ErrCategoryTooDeep = fmt.Errorf("categories cannot be nested more than %d levels deep", MaxCategoryDepth)
+	// ErrCategoryParentChanged is returned when a category is saved with a
+	// different parent; CategoryRepository.Move moves it with its subtree
+	ErrCategoryParentChanged = errors.New("the parent of a category can only be changed with Move")
+)
+
 // CreateCategoryRequest represents the payload for creating a category
 type CreateCategoryRequest struct {
 	Name        string `json:"name" validate:"required,min=2,max=100"`
@@ -37,91 +68,193 @@ type UpdateCategoryRequest struct {
 	Active      *bool   `json:"active,omitempty"`
 }
 
-// TODO: Implement GORM model methods and hooks
-
 // TableName specifies the table name for GORM (optional - GORM auto-infers)
 func (Category) TableName() string {
 	return "categories"
 }
 
-// TODO: Implement BeforeCreate hook
+// DefaultCategoryColor is used when a category is created without a color
+const DefaultCategoryColor = "#007bff"
+
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// BeforeCreate validates the category, fills in the default color and
+// places it under its parent, after its last sibling unless SortOrder is set
 func (c *Category) BeforeCreate(tx *gorm.DB) error {
-	// TODO: GORM BeforeCreate hook
-	// - Validate data before creation
-	// - Set default values
-	// - Perform any pre-creation logic
-	// Example: if c.Color == "" { c.Color = "#007bff" }
+	if c.Color == "" {
+		c.Color = DefaultCategoryColor
+	}
+	if err := validateCategory(c.Name, c.Description, c.Color); err != nil {
+		return err
+	}
+
+	db := tx.Session(&gorm.Session{NewDB: true})
+	c.Path, c.Depth = "/", 0
+	if c.ParentID != nil {
+		var parent Category
+		if err := db.First(&parent, *c.ParentID).Error; err != nil {
+			return fmt.Errorf("parent category %d: %w", *c.ParentID, err)
+		}
+		if parent.Depth+1 >= MaxCategoryDepth {
+			return ErrCategoryTooDeep
+		}
+		// The ID is appended by AfterCreate once it is known
+		c.Path, c.Depth = parent.Path, parent.Depth+1
+	}
+	if c.SortOrder == 0 {
+		order, err := NextSortOrder(db, c.ParentID)
+		if err != nil {
+			return err
+		}
+		c.SortOrder = order
+	}
 	return nil
 }
 
-// TODO: Implement AfterCreate hook
+// AfterCreate completes the path of the new category and records it in the
+// audit log
 func (c *Category) AfterCreate(tx *gorm.DB) error {
-	// TODO: GORM AfterCreate hook
-	// - Log creation
-	// - Send notifications
-	// - Update cache
-	// Example: log.Printf("Category created: %s", c.Name)
-	return nil
+	c.Path += strconv.FormatUint(uint64(c.ID), 10) + "/"
+	err := tx.Session(&gorm.Session{NewDB: true}).Model(&Category{}).
+		Where("id = ?", c.ID).UpdateColumn("path", c.Path).Error
+	if err != nil {
+		return err
+	}
+
+	entry, err := audit.NewEntry(tx.Statement.Context, audit.EntityCategory, int64(c.ID), audit.ActionCreate, nil, c.auditState())
+	if err != nil {
+		return err
+	}
+	return audit.RecordGORM(tx, entry)
 }
 
-// TODO: Implement BeforeUpdate hook
+// BeforeUpdate validates the category before GORM saves changes and records
+// what changed in the audit log
 func (c *Category) BeforeUpdate(tx *gorm.DB) error {
-	// TODO: GORM BeforeUpdate hook
-	// - Validate changes
-	// - Prevent certain updates
-	// - Clean up related data
-	return nil
+	if err := validateCategory(c.Name, c.Description, c.Color); err != nil {
+		return err
+	}
+	if c.ID == 0 {
+		return nil
+	}
+
+	var before Category
+	err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().First(&before, c.ID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // Save falls back to an insert, recorded by AfterCreate
+	}
+	if err != nil {
+		return err
+	}
+	if !c.IsChildOf(before.ParentID) {
+		return ErrCategoryParentChanged
+	}
+	c.Path, c.Depth = before.Path, before.Depth
+
+	entry, err := audit.NewEntry(tx.Statement.Context, audit.EntityCategory, int64(c.ID), audit.ActionUpdate, before.auditState(), c.auditState())
+	if err != nil || len(entry.Changes) == 0 {
+		return err
+	}
+	return audit.RecordGORM(tx, entry)
+}
+
+// auditState returns the category without its associations, as recorded in
+// the audit log
+func (c *Category) auditState() *Category {
+	state := *c
+	state.Posts, state.Children = nil, nil
+	return &state
 }
 
-// TODO: Implement Validate method for CreateCategoryRequest
+// IsChildOf reports whether parentID is the parent of the category, with nil
+// standing for the roots
+func (c *Category) IsChildOf(parentID *uint) bool {
+	if c.ParentID == nil || parentID == nil {
+		return c.ParentID == nil && parentID == nil
+	}
+	return *c.ParentID == *parentID
+}
+
+// ChildrenOf returns a GORM scope selecting the children of a category, or
+// the root categories when parentID is nil
+func ChildrenOf(parentID *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if parentID == nil {
+			return db.Where("parent_id IS NULL")
+		}
+		return db.Where("parent_id = ?", *parentID)
+	}
+}
+
+// NextSortOrder returns the sort order that places a category after the
+// last child of parentID
+func NextSortOrder(db *gorm.DB, parentID *uint) (int, error) {
+	var order int
+	err := db.Model(&Category{}).Scopes(ChildrenOf(parentID)).
+		Select("COALESCE(MAX(sort_order) + 1, 0)").Scan(&order).Error
+	return order, err
+}
+
+// Validate checks the name, description and color of the request.
+// Name uniqueness is enforced by the database.
 func (req *CreateCategoryRequest) Validate() error {
-	// TODO: Add validation logic for GORM model
-	// - Name should be unique (checked at database level via GORM)
-	// - Color should be valid hex color
-	// - Description should not exceed limits
-	// Example using validator package:
-	// return validator.New().Struct(req)
+	return validateCategory(req.Name, req.Description, req.Color)
+}
+
+func validateCategory(name, description, color string) error {
+	name = strings.TrimSpace(name)
+	if len(name) < 2 || len(name) > 100 {
+		return errors.New("name must be between 2 and 100 characters")
+	}
+	if len(description) > 500 {
+		return errors.New("description must be at most 500 characters")
+	}
+	if color != "" && !hexColorPattern.MatchString(color) {
+		return errors.New("color must be a hex color like #007bff")
+	}
 	return nil
 }
 
-// TODO: Implement ToCategory method
+// ToCategory converts the request to an active Category
 func (req *CreateCategoryRequest) ToCategory() *Category {
-	// TODO: Convert request to GORM model
-	// - Map fields from request to model
-	// - Set default values
-	// Example:
-	// return &Category{
-	//     Name:        req.Name,
-	//     Description: req.Description,
-	//     Color:       req.Color,
-	//     Active:      true,
-	// }
-	return nil
+	return &Category{
+		Name:        req.Name,
+		Description: req.Description,
+		Color:       req.Color,
+		Active:      true,
+	}
 }
 
-// TODO: Implement GORM scopes (reusable query logic)
+// ActiveCategories is a GORM scope selecting active categories
 func ActiveCategories(db *gorm.DB) *gorm.DB {
-	// TODO: GORM scope for active categories
-	// return db.Where("active = ?", true)
-	return db
+	return db.Where("active = ?", true)
 }
 
+// CategoriesWithPosts is a GORM scope selecting categories that have at
+// least one post that is not deleted
 func CategoriesWithPosts(db *gorm.DB) *gorm.DB {
-	// TODO: GORM scope for categories with posts
-	// return db.Joins("Posts").Where("posts.id IS NOT NULL")
-	return db
+	return db.Where(`EXISTS (
+		SELECT 1 FROM post_categories pc
+		JOIN posts p ON p.id = pc.post_id
+		WHERE pc.category_id = categories.id AND p.deleted_at IS NULL)`)
 }
 
-// TODO: Implement model validation methods
+// IsActive reports whether the category is active
 func (c *Category) IsActive() bool {
-	// TODO: Check if category is active
 	return c.Active
 }
 
+// PostCount returns the number of posts in the category and its live
+// descendants. A post in several of them is counted once.
 func (c *Category) PostCount(db *gorm.DB) (int64, error) {
-	// TODO: Get post count for this category using GORM association
-	// var count int64
-	// err := db.Model(c).Association("Posts").Count(&count)
-	// return count, err
-	return 0, nil
+	var count int64
+	err := db.Table("post_categories pc").
+		Joins("JOIN posts p ON p.id = pc.post_id").
+		Joins("JOIN categories c ON c.id = pc.category_id").
+		Where("p.deleted_at IS NULL").
+		Where(db.Where("c.id = ?", c.ID).Or(
+			"c.deleted_at IS NULL AND c.path LIKE (SELECT path FROM categories WHERE id = ?) || '%'", c.ID)).
+		Distinct("pc.post_id").
+		Count(&count).Error
+	return count, err
 }
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// CommentStatus is the moderation state of a comment
+type CommentStatus string
+
+const (
+	CommentPending  CommentStatus = "pending"
+	CommentApproved CommentStatus = "approved"
+	CommentRejected CommentStatus = "rejected"
+)
+
+// MaxCommentLength is the maximum length of a comment in characters
+const MaxCommentLength = 2000
+
+// Valid reports whether s is a known moderation status
+func (s CommentStatus) Valid() bool {
+	switch s {
+	case CommentPending, CommentApproved, CommentRejected:
+		return true
+	}
+	return false
+}
+
+// Comment represents a comment on a post. Replies point to the comment they
+// answer through ParentID; Replies is filled in when a thread is built.
+type Comment struct {
+	ID        int           `json:"id" db:"id"`
+	PostID    int           `json:"post_id" db:"post_id"`
+	UserID    int           `json:"user_id" db:"user_id"`
+	ParentID  *int          `json:"parent_id,omitempty" db:"parent_id"`
+	Content   string        `json:"content" db:"content"`
+	Status    CommentStatus `json:"status" db:"status"`
+	CreatedAt time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time    `json:"deleted_at,omitempty" db:"deleted_at"`
+	Replies   []*Comment    `json:"replies,omitempty" db:"-"`
+}
+
+// IsDeleted reports whether the comment has been soft deleted
+func (c *Comment) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
+// CreateCommentRequest represents the payload for creating a comment
+type CreateCommentRequest struct {
+	PostID   int    `json:"post_id"`
+	UserID   int    `json:"user_id"`
+	ParentID *int   `json:"parent_id,omitempty"`
+	Content  string `json:"content"`
+}
+
+// Validate checks that the request has a post, an author and content
+func (req *CreateCommentRequest) Validate() error {
+	if req.PostID <= 0 {
+		return errors.New("post ID must be positive")
+	}
+	if req.UserID <= 0 {
+		return errors.New("user ID must be positive")
+	}
+	if req.ParentID != nil && *req.ParentID <= 0 {
+		return errors.New("parent ID must be positive")
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return errors.New("content is required")
+	}
+	if len([]rune(req.Content)) > MaxCommentLength {
+		return errors.New("content must be at most 2000 characters")
+	}
+	return nil
+}
+
+// ToComment converts CreateCommentRequest to an approved Comment with the
+// current timestamps
+func (req *CreateCommentRequest) ToComment() *Comment {
+	now := time.Now().UTC()
+	return &Comment{
+		PostID:    req.PostID,
+		UserID:    req.UserID,
+		ParentID:  req.ParentID,
+		Content:   req.Content,
+		Status:    CommentApproved,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// BuildCommentThread arranges comments into trees of replies and returns the
+// top-level comments in the order given. Replies whose parent is not among
+// the comments are left out.
+func BuildCommentThread(comments []Comment) []*Comment {
+	byID := make(map[int]*Comment, len(comments))
+	for i := range comments {
+		comments[i].Replies = nil
+		byID[comments[i].ID] = &comments[i]
+	}
+
+	roots := []*Comment{}
+	for i := range comments {
+		c := &comments[i]
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else if parent, ok := byID[*c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		}
+	}
+	return roots
+}
+
+// PruneDeletedComments drops deleted comments without live replies from a
+// thread and clears the content of the deleted ones kept to hold replies
+// together
+func PruneDeletedComments(comments []*Comment) []*Comment {
+	kept := make([]*Comment, 0, len(comments))
+	for _, c := range comments {
+		c.Replies = PruneDeletedComments(c.Replies)
+		if c.IsDeleted() {
+			if len(c.Replies) == 0 {
+				continue
+			}
+			c.Content = ""
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
@@ -2,72 +2,199 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
-// Post represents a blog post in the system
+// PostStatus is the publishing state of a post
+type PostStatus string
+
+const (
+	PostDraft     PostStatus = "draft"
+	PostScheduled PostStatus = "scheduled" // published by the Publisher at PublishAt
+	PostPublished PostStatus = "published"
+	PostArchived  PostStatus = "archived"
+)
+
+// postTransitions lists the states each state may move to
+var postTransitions = map[PostStatus][]PostStatus{
+	PostDraft:     {PostScheduled, PostPublished},
+	PostScheduled: {PostDraft, PostPublished},
+	PostPublished: {PostDraft, PostArchived},
+	PostArchived:  {PostDraft, PostPublished},
+}
+
+// Valid reports whether s is a known publishing state
+func (s PostStatus) Valid() bool {
+	_, ok := postTransitions[s]
+	return ok
+}
+
+// CanTransition reports whether a post in state s may move to next.
+// Staying in the same state is allowed.
+func (s PostStatus) CanTransition(next PostStatus) bool {
+	if s == next {
+		return s.Valid()
+	}
+	for _, allowed := range postTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Post represents a blog post in the system. Published mirrors
+// Status == PostPublished for the queries that filter on it.
 type Post struct {
-	ID        int       `json:"id" db:"id"`
-	UserID    int       `json:"user_id" db:"user_id"`
-	Title     string    `json:"title" db:"title"`
-	Content   string    `json:"content" db:"content"`
-	Published bool      `json:"published" db:"published"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int        `json:"id" db:"id"`
+	UserID    int        `json:"user_id" db:"user_id"`
+	Title     string     `json:"title" db:"title"`
+	Content   string     `json:"content" db:"content"`
+	Published bool       `json:"published" db:"published"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+	Slug      string     `json:"slug" db:"slug"`
+	Status    PostStatus `json:"status" db:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty" db:"publish_at"` // When the post was or will be published
 }
 
-// CreatePostRequest represents the payload for creating a post
+// IsDeleted reports whether the post has been soft deleted
+func (p *Post) IsDeleted() bool {
+	return p.DeletedAt != nil
+}
+
+// CreatePostRequest represents the payload for creating a post. Status
+// defaults to published or draft according to Published, and Slug to one
+// generated from the title.
 type CreatePostRequest struct {
-	UserID    int    `json:"user_id"`
-	Title     string `json:"title"`
-	Content   string `json:"content"`
-	Published bool   `json:"published"`
+	UserID    int        `json:"user_id"`
+	Title     string     `json:"title"`
+	Content   string     `json:"content"`
+	Published bool       `json:"published"`
+	Slug      string     `json:"slug,omitempty"`
+	Status    PostStatus `json:"status,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"` // Required for scheduled posts
 }
 
-// UpdatePostRequest represents the payload for updating a post
+// UpdatePostRequest represents the payload for updating a post. Setting
+// Published moves the post to the published or draft state.
 type UpdatePostRequest struct {
 	Title     *string `json:"title,omitempty"`
 	Content   *string `json:"content,omitempty"`
 	Published *bool   `json:"published,omitempty"`
+	Slug      *string `json:"slug,omitempty"`
 }
 
-// TODO: Implement Validate method for Post
+// Validate checks that the post has a title, an author and content when it
+// is or will be published
 func (p *Post) Validate() error {
-	// TODO: Add validation logic
-	// - Title should not be empty and should be at least 5 characters
-	// - Content should not be empty if published is true
-	// - UserID should be greater than 0
-	// Return appropriate errors if validation fails
-	return nil
+	return validatePost(p.UserID, p.Title, p.Content, p.Published || p.Status == PostScheduled)
 }
 
-// TODO: Implement Validate method for CreatePostRequest
+// Validate checks that the request has a title, an author, a valid slug and
+// status, and content when the post is or will be published
 func (req *CreatePostRequest) Validate() error {
-	// TODO: Add validation logic
-	// - Title should not be empty and should be at least 5 characters
-	// - UserID should be greater than 0
-	// - Content should not be empty if published is true
-	// Return appropriate errors if validation fails
+	status := req.status()
+	if !status.Valid() {
+		return fmt.Errorf("unknown post status %q", req.Status)
+	}
+	if req.Slug != "" && !ValidSlug(req.Slug) {
+		return errors.New("slug must be lowercase letters, digits and single hyphens")
+	}
+	if status == PostScheduled && req.PublishAt == nil {
+		return errors.New("scheduled posts need a publish time")
+	}
+	return validatePost(req.UserID, req.Title, req.Content, status == PostPublished || status == PostScheduled)
+}
+
+// status returns the requested status, defaulting from Published
+func (req *CreatePostRequest) status() PostStatus {
+	switch {
+	case req.Status != "":
+		return req.Status
+	case req.Published:
+		return PostPublished
+	default:
+		return PostDraft
+	}
+}
+
+func validatePost(userID int, title, content string, published bool) error {
+	if len(strings.TrimSpace(title)) < 5 {
+		return errors.New("title must be at least 5 characters")
+	}
+	if userID <= 0 {
+		return errors.New("user ID must be positive")
+	}
+	if published && strings.TrimSpace(content) == "" {
+		return errors.New("published posts must have content")
+	}
 	return nil
 }
 
-// TODO: Implement ToPost method for CreatePostRequest
+// ToPost converts CreatePostRequest to Post with the current timestamps.
+// The slug is left to the repository when the request has none.
 func (req *CreatePostRequest) ToPost() *Post {
-	// TODO: Convert CreatePostRequest to Post
-	// Set timestamps to current time
-	return nil
+	now := time.Now().UTC()
+	post := &Post{
+		UserID:    req.UserID,
+		Title:     req.Title,
+		Content:   req.Content,
+		Slug:      req.Slug,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	post.SetStatus(req.status(), req.PublishAt, now)
+	return post
 }
 
-// TODO: Implement ScanRow method for Post
+// SetStatus moves the post to status and keeps Published and PublishAt in
+// line with it: a published post without a publish time gets now, and a
+// draft loses its publish time.
+func (p *Post) SetStatus(status PostStatus, publishAt *time.Time, now time.Time) {
+	p.Status = status
+	p.Published = status == PostPublished
+	switch {
+	case status == PostDraft:
+		p.PublishAt = nil
+	case publishAt != nil:
+		at := publishAt.UTC()
+		p.PublishAt = &at
+	case status == PostPublished && p.PublishAt == nil:
+		p.PublishAt = &now
+	}
+}
+
+// ScanRow scans a database row into the Post struct.
+// Columns must be selected in the order: id, user_id, title, content,
+// published, created_at, updated_at, deleted_at, slug, status, publish_at.
 func (p *Post) ScanRow(row *sql.Row) error {
-	// TODO: Scan database row into Post struct
-	// Handle the case where row might be nil
-	return nil
+	if row == nil {
+		return errors.New("row cannot be nil")
+	}
+	return row.Scan(p.scanDest()...)
 }
 
-// TODO: Implement ScanRows method for Post slice
+// ScanPosts scans multiple database rows into a Post slice and closes rows.
+// Columns must be selected in the same order as for ScanRow.
 func ScanPosts(rows *sql.Rows) ([]Post, error) {
-	// TODO: Scan multiple database rows into Post slice
-	// Make sure to close rows and handle errors properly
-	return nil, nil
+	defer rows.Close()
+
+	posts := []Post{}
+	for rows.Next() {
+		var p Post
+		if err := rows.Scan(p.scanDest()...); err != nil {
+			return nil, err
+		}
+		posts = append(posts, p)
+	}
+	return posts, rows.Err()
+}
+
+func (p *Post) scanDest() []interface{} {
+	return []interface{}{&p.ID, &p.UserID, &p.Title, &p.Content, &p.Published, &p.CreatedAt, &p.UpdatedAt, &p.DeletedAt, &p.Slug, &p.Status, &p.PublishAt}
 }
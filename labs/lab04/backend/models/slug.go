@@ -0,0 +1,86 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxSlugLength is the longest slug Slugify generates, leaving room in the
+// column for collision suffixes
+const MaxSlugLength = 200
+
+// DefaultSlug is used for titles without any letters or digits that can be
+// transliterated
+const DefaultSlug = "post"
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// ValidSlug reports whether s is at most MaxSlugLength lowercase ASCII
+// letters and digits separated by single hyphens
+func ValidSlug(s string) bool {
+	return len(s) <= MaxSlugLength && slugPattern.MatchString(s)
+}
+
+// transliterations spells letters that do not decompose into an ASCII
+// letter and accents, and drops apostrophes
+var transliterations = map[rune]string{
+	'\'': "", '’': "",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'ł': "l", 'đ': "d", 'ð': "d", 'þ': "th", 'ı': "i",
+	// Russian and Ukrainian Cyrillic
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z",
+	'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r",
+	'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu", 'я': "ya",
+	'є': "ye", 'і': "i", 'ї': "yi", 'ґ': "g",
+}
+
+// stripMarks decomposes accented letters and drops the accents, so that
+// "é" becomes "e"
+var stripMarks = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// Slugify turns a title into a URL slug: transliterated to lowercase ASCII,
+// with runs of other characters replaced by single hyphens. The result is at
+// most MaxSlugLength long and is DefaultSlug when nothing is left.
+func Slugify(title string) string {
+	var b strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(title) {
+		spelled, ok := transliterations[r]
+		if !ok {
+			stripped, _, _ := transform.String(stripMarks, string(r))
+			spelled = stripped
+		}
+		for _, c := range spelled {
+			if c > unicode.MaxASCII || !(unicode.IsLetter(c) || unicode.IsDigit(c)) {
+				pendingHyphen = b.Len() > 0
+				continue
+			}
+			if pendingHyphen {
+				b.WriteByte('-')
+				pendingHyphen = false
+			}
+			b.WriteRune(c)
+		}
+		if !ok && spelled == "" {
+			pendingHyphen = b.Len() > 0
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > MaxSlugLength {
+		slug = slug[:MaxSlugLength]
+		if i := strings.LastIndexByte(slug, '-'); i > MaxSlugLength/2 {
+			slug = slug[:i]
+		}
+		slug = strings.TrimRight(slug, "-")
+	}
+	if slug == "" {
+		return DefaultSlug
+	}
+	return slug
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Go 1.24 -- what's new?  ", "go-1-24-whats-new"},
+		{"Crème brûlée à la française", "creme-brulee-a-la-francaise"},
+		{"Straße und Smørrebrød", "strasse-und-smorrebrod"},
+		{"Привет, мир", "privet-mir"},
+		{"Щастя і їжак", "shchastya-i-yizhak"},
+		{"日本語", DefaultSlug},
+		{"", DefaultSlug},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+
+	long := Slugify(strings.Repeat("word ", 100))
+	if len(long) > MaxSlugLength || strings.HasSuffix(long, "-") || !ValidSlug(long) {
+		t.Errorf("Slugify(long title) = %q (%d bytes), want a valid slug of at most %d", long, len(long), MaxSlugLength)
+	}
+}
+
+func TestValidSlug(t *testing.T) {
+	for slug, want := range map[string]bool{
+		"hello-world":  true,
+		"post-2":       true,
+		"Hello":        false,
+		"-hello":       false,
+		"hello--world": false,
+		"hello_world":  false,
+		"":             false,
+	} {
+		if got := ValidSlug(slug); got != want {
+			t.Errorf("ValidSlug(%q) = %v, want %v", slug, got, want)
+		}
+	}
+}
+
+func TestPostStatus_CanTransition(t *testing.T) {
+	tests := []struct {
+		from, to PostStatus
+		want     bool
+	}{
+		{PostDraft, PostScheduled, true},
+		{PostDraft, PostPublished, true},
+		{PostDraft, PostArchived, false},
+		{PostScheduled, PostPublished, true},
+		{PostScheduled, PostArchived, false},
+		{PostPublished, PostArchived, true},
+		{PostPublished, PostScheduled, false},
+		{PostArchived, PostDraft, true},
+		{PostArchived, PostArchived, true},
+		{PostDraft, PostStatus("deleted"), false},
+	}
+	for _, tt := range tests {
+		if got := tt.from.CanTransition(tt.to); got != tt.want {
+			t.Errorf("%s.CanTransition(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
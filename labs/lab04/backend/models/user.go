@@ -2,16 +2,25 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
 	"time"
 )
 
 // User represents a user in the system
 type User struct {
-	ID        int       `json:"id" db:"id"`
-	Name      string    `json:"name" db:"name"`
-	Email     string    `json:"email" db:"email"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        int        `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Email     string     `json:"email" db:"email"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// IsDeleted reports whether the user has been soft deleted
+func (u *User) IsDeleted() bool {
+	return u.DeletedAt != nil
 }
 
 // CreateUserRequest represents the payload for creating a user
@@ -26,41 +35,69 @@ type UpdateUserRequest struct {
 	Email *string `json:"email,omitempty"`
 }
 
-// TODO: Implement Validate method for User
+// emailPattern is a pragmatic check for "local@domain.tld" addresses
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// Validate checks that the user has a valid name and email
 func (u *User) Validate() error {
-	// TODO: Add validation logic
-	// - Name should not be empty and should be at least 2 characters
-	// - Email should be valid format
-	// Return appropriate errors if validation fails
-	return nil
+	return validateUser(u.Name, u.Email)
 }
 
-// TODO: Implement Validate method for CreateUserRequest
+// Validate checks that the request has a valid name and email
 func (req *CreateUserRequest) Validate() error {
-	// TODO: Add validation logic
-	// - Name should not be empty and should be at least 2 characters
-	// - Email should be valid format and not empty
-	// Return appropriate errors if validation fails
+	return validateUser(req.Name, req.Email)
+}
+
+func validateUser(name, email string) error {
+	if len(strings.TrimSpace(name)) < 2 {
+		return errors.New("name must be at least 2 characters")
+	}
+	if strings.TrimSpace(email) == "" {
+		return errors.New("email is required")
+	}
+	if !emailPattern.MatchString(email) {
+		return errors.New("email is not valid")
+	}
 	return nil
 }
 
-// TODO: Implement ToUser method for CreateUserRequest
+// ToUser converts CreateUserRequest to User with the current timestamps
 func (req *CreateUserRequest) ToUser() *User {
-	// TODO: Convert CreateUserRequest to User
-	// Set timestamps to current time
-	return nil
+	now := time.Now().UTC()
+	return &User{
+		Name:      req.Name,
+		Email:     req.Email,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
 }
 
-// TODO: Implement ScanRow method for User
+// ScanRow scans a database row into the User struct.
+// Columns must be selected in the order: id, name, email, created_at,
+// updated_at, deleted_at.
 func (u *User) ScanRow(row *sql.Row) error {
-	// TODO: Scan database row into User struct
-	// Handle the case where row might be nil
-	return nil
+	if row == nil {
+		return errors.New("row cannot be nil")
+	}
+	return row.Scan(u.scanDest()...)
 }
 
-// TODO: Implement ScanRows method for User slice
+// ScanUsers scans multiple database rows into a User slice and closes rows.
+// Columns must be selected in the same order as for ScanRow.
 func ScanUsers(rows *sql.Rows) ([]User, error) {
-	// TODO: Scan multiple database rows into User slice
-	// Make sure to close rows and handle errors properly
-	return nil, nil
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(u.scanDest()...); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (u *User) scanDest() []interface{} {
+	return []interface{}{&u.ID, &u.Name, &u.Email, &u.CreatedAt, &u.UpdatedAt, &u.DeletedAt}
 }
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/filter"
+
+	"github.com/Masterminds/squirrel"
+)
+
+// DefaultAnalyticsBuckets is the number of periods covered when
+// AnalyticsRange.From is not set
+const DefaultAnalyticsBuckets = 30
+
+// MaxAnalyticsBuckets bounds the number of points in a series
+const MaxAnalyticsBuckets = 1000
+
+// MaxAnalyticsPosts bounds the posts whose content lengths are loaded to
+// compute percentiles; larger ranges fail and must be narrowed
+const MaxAnalyticsPosts = 100000
+
+// DefaultPercentiles are the content length percentiles reported when none
+// are requested
+var DefaultPercentiles = []float64{0.5, 0.9, 0.99}
+
+// AnalyticsRange selects the posts analysed and how they are bucketed. Posts
+// are bucketed by creation time, in UTC.
+type AnalyticsRange struct {
+	Period database.Period // Bucket width; defaults to day
+	From   time.Time       // Rounded down to the start of its period; defaults to DefaultAnalyticsBuckets periods before To
+	To     time.Time       // Defaults to now; the period containing To is the last and is counted whole
+
+	Filters     []filter.Condition // Conditions on PostSchema fields, e.g. user_id
+	Percentiles []float64          // Content length percentiles between 0 and 1; defaults to DefaultPercentiles
+}
+
+// Point is one bucket of a series
+type Point struct {
+	Bucket time.Time `json:"bucket"` // Start of the period
+	Value  float64   `json:"value"`
+}
+
+// Series is a named list of points with one point per period, empty periods
+// included, ready to be plotted
+type Series struct {
+	Name   string  `json:"name"`
+	Points []Point `json:"points"`
+}
+
+// PostAnalytics holds time series over the posts of an AnalyticsRange
+type PostAnalytics struct {
+	Period        database.Period `json:"period"`
+	From          time.Time       `json:"from"`
+	To            time.Time       `json:"to"`
+	Posts         Series          `json:"posts"`          // Posts created
+	Published     Series          `json:"published"`      // Posts created that are published
+	PublishRate   Series          `json:"publish_rate"`   // Published / Posts, 0 for empty periods
+	ActiveAuthors Series          `json:"active_authors"` // Distinct authors of the posts created
+	ContentLength []Series        `json:"content_length"` // One series per percentile, e.g. "p90"
+}
+
+// GetPostAnalytics returns time-bucketed statistics over live posts of live
+// users: posts, published posts and distinct authors per period, the share of
+// posts published, and content length percentiles
+func (s *SearchService) GetPostAnalytics(ctx context.Context, r AnalyticsRange) (*PostAnalytics, error) {
+	r, buckets, err := r.normalize()
+	if err != nil {
+		return nil, err
+	}
+	analytics := &PostAnalytics{
+		Period:        r.Period,
+		From:          r.From,
+		To:            r.To,
+		Posts:         newSeries("posts", buckets),
+		Published:     newSeries("published", buckets),
+		PublishRate:   newSeries("publish_rate", buckets),
+		ActiveAuthors: newSeries("active_authors", buckets),
+	}
+	index := make(map[string]int, len(buckets))
+	for i, bucket := range buckets {
+		index[bucket.Format(time.DateOnly)] = i
+	}
+
+	// The range is filtered on the column itself so that its index is used
+	bucket := s.dialect.DateTrunc(r.Period, "created_at")
+	end := r.Period.Add(buckets[len(buckets)-1], 1)
+	base := s.psql.Select().From("posts").
+		Where("deleted_at IS NULL").
+		Where("user_id IN (SELECT id FROM users WHERE deleted_at IS NULL)").
+		Where(squirrel.GtOrEq{"created_at": s.dialect.Timestamp(buckets[0])}).
+		Where(squirrel.Lt{"created_at": s.dialect.Timestamp(end)})
+	if len(r.Filters) > 0 {
+		base = base.Where(PostSchema.Where(s.dialect, r.Filters...))
+	}
+
+	counts := []struct {
+		Bucket    string `db:"bucket"`
+		Posts     int    `db:"posts"`
+		Published int    `db:"published"`
+		Authors   int    `db:"authors"`
+	}{}
+	query := base.Columns(
+		bucket+" AS bucket",
+		"COUNT(*) AS posts",
+		"COUNT(CASE WHEN published THEN 1 END) AS published",
+		"COUNT(DISTINCT user_id) AS authors",
+	).GroupBy(bucket)
+	if err := s.selectBuilt(ctx, "SearchService.GetPostAnalytics", &counts, query); err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, row := range counts {
+		total += row.Posts
+		i, ok := index[row.Bucket]
+		if !ok {
+			continue
+		}
+		analytics.Posts.Points[i].Value = float64(row.Posts)
+		analytics.Published.Points[i].Value = float64(row.Published)
+		analytics.ActiveAuthors.Points[i].Value = float64(row.Authors)
+		if row.Posts > 0 {
+			analytics.PublishRate.Points[i].Value = float64(row.Published) / float64(row.Posts)
+		}
+	}
+
+	// Percentiles are computed here rather than in SQL, which SQLite lacks,
+	// from one row per post
+	if total > MaxAnalyticsPosts {
+		return nil, fmt.Errorf("analytics range has %d posts, more than %d: narrow it or add filters", total, MaxAnalyticsPosts)
+	}
+	lengths := []struct {
+		Bucket string `db:"bucket"`
+		Length int    `db:"length"`
+	}{}
+	query = base.Columns(bucket+" AS bucket", "LENGTH(COALESCE(content, '')) AS length")
+	if err := s.selectBuilt(ctx, "SearchService.GetPostAnalytics", &lengths, query); err != nil {
+		return nil, err
+	}
+	byBucket := make([][]float64, len(buckets))
+	for _, row := range lengths {
+		if i, ok := index[row.Bucket]; ok {
+			byBucket[i] = append(byBucket[i], float64(row.Length))
+		}
+	}
+	for _, p := range r.Percentiles {
+		series := newSeries(percentileName(p), buckets)
+		for i, values := range byBucket {
+			series.Points[i].Value = percentile(values, p)
+		}
+		analytics.ContentLength = append(analytics.ContentLength, series)
+	}
+	return analytics, nil
+}
+
+// selectBuilt builds query and scans its rows into dest
+func (s *SearchService) selectBuilt(ctx context.Context, op string, dest interface{}, query squirrel.SelectBuilder) error {
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return fmt.Errorf("failed to build analytics query: %w", err)
+	}
+	return s.selectInto(ctx, op, dest, sqlStr, args...)
+}
+
+// normalize fills in defaults and returns the start of every bucket
+func (r AnalyticsRange) normalize() (AnalyticsRange, []time.Time, error) {
+	if r.Period == "" {
+		r.Period = database.PeriodDay
+	}
+	if _, err := database.ParsePeriod(string(r.Period)); err != nil {
+		return r, nil, err
+	}
+	if r.To.IsZero() {
+		r.To = time.Now()
+	}
+	r.To = r.To.UTC()
+	if r.From.IsZero() {
+		r.From = r.Period.Add(r.Period.Truncate(r.To), 1-DefaultAnalyticsBuckets)
+	}
+	r.From = r.From.UTC()
+	if !r.From.Before(r.To) {
+		return r, nil, errors.New("analytics range must start before it ends")
+	}
+	if len(r.Percentiles) == 0 {
+		r.Percentiles = DefaultPercentiles
+	}
+	for _, p := range r.Percentiles {
+		if p < 0 || p > 1 {
+			return r, nil, fmt.Errorf("percentile %v is not between 0 and 1", p)
+		}
+	}
+
+	var buckets []time.Time
+	for bucket := r.Period.Truncate(r.From); bucket.Before(r.To); bucket = r.Period.Add(bucket, 1) {
+		if len(buckets) == MaxAnalyticsBuckets {
+			return r, nil, fmt.Errorf("analytics range has more than %d %ss", MaxAnalyticsBuckets, r.Period)
+		}
+		buckets = append(buckets, bucket)
+	}
+	return r, buckets, nil
+}
+
+func newSeries(name string, buckets []time.Time) Series {
+	points := make([]Point, len(buckets))
+	for i, bucket := range buckets {
+		points[i].Bucket = bucket
+	}
+	return Series{Name: name, Points: points}
+}
+
+// percentileName names a percentile series, e.g. "p50" or "p99.9"
+func percentileName(p float64) string {
+	return fmt.Sprintf("p%g", math.Round(p*1000)/10)
+}
+
+// percentile interpolates linearly between the closest ranks, like
+// PostgreSQL's percentile_cont; it is 0 without values
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := p * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	if lower == len(values)-1 {
+		return values[lower]
+	}
+	return values[lower] + (rank-float64(lower))*(values[lower+1]-values[lower])
+}
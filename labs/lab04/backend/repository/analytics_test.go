@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/dbtest"
+	"lab04-backend/filter"
+	"lab04-backend/models"
+)
+
+func TestSearchService_GetPostAnalytics(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	posts := NewPostRepository(db.Conn)
+	search := NewSearchService(db.Conn)
+
+	var authors []int
+	for _, email := range []string{"alice@example.com", "bob@example.com"} {
+		user, err := userRepo.Create(&models.CreateUserRequest{Name: "Author", Email: email})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		authors = append(authors, user.ID)
+	}
+
+	// Weeks start on Monday: 2025-07-07, 2025-07-14 and 2025-07-21
+	for _, p := range []struct {
+		author    int
+		created   string
+		content   string
+		published bool
+	}{
+		{authors[0], "2025-07-07 00:00:00", "short", true},
+		{authors[0], "2025-07-09 09:00:00", strings.Repeat("x", 100), false},
+		{authors[1], "2025-07-13 23:59:59", strings.Repeat("x", 30), true},
+		{authors[1], "2025-07-21 08:00:00", strings.Repeat("x", 40), true},
+		{authors[1], "2025-06-30 08:00:00", "before the range", true},
+		{authors[1], "2025-07-28 00:00:00", "after the range", true},
+	} {
+		post, err := posts.Create(&models.CreatePostRequest{
+			UserID: p.author, Title: "Analytics post", Content: p.content, Published: p.published,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		if _, err := db.Conn.Exec(`UPDATE posts SET created_at = ? WHERE id = ?`, p.created, post.ID); err != nil {
+			t.Fatalf("Failed to backdate post: %v", err)
+		}
+	}
+
+	r := AnalyticsRange{
+		Period: database.PeriodWeek,
+		From:   time.Date(2025, 7, 9, 0, 0, 0, 0, time.UTC), // rounded down to Monday the 7th
+		To:     time.Date(2025, 7, 22, 0, 0, 0, 0, time.UTC),
+	}
+	analytics, err := search.GetPostAnalytics(t.Context(), r)
+	if err != nil {
+		t.Fatalf("GetPostAnalytics() error = %v", err)
+	}
+
+	values := func(series Series) []float64 {
+		var values []float64
+		for _, point := range series.Points {
+			values = append(values, point.Value)
+		}
+		return values
+	}
+	check := func(series Series, want ...float64) {
+		t.Helper()
+		got := values(series)
+		if len(got) != len(want) {
+			t.Errorf("%s = %v, want %v", series.Name, got, want)
+			return
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s = %v, want %v", series.Name, got, want)
+				return
+			}
+		}
+	}
+	if first := analytics.Posts.Points[0].Bucket; !first.Equal(time.Date(2025, 7, 7, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("first bucket = %v, want Monday 2025-07-07", first)
+	}
+	check(analytics.Posts, 3, 0, 1)
+	check(analytics.Published, 2, 0, 1)
+	check(analytics.PublishRate, 2.0/3, 0, 1)
+	check(analytics.ActiveAuthors, 2, 0, 1)
+	if len(analytics.ContentLength) != 3 || analytics.ContentLength[1].Name != "p90" {
+		t.Fatalf("ContentLength = %+v, want p50, p90 and p99", analytics.ContentLength)
+	}
+	check(analytics.ContentLength[0], 30, 0, 40)
+	check(analytics.ContentLength[1], 86, 0, 40) // 30 + 0.8 * (100 - 30)
+
+	r.Filters = []filter.Condition{{Field: "user_id", Op: filter.Eq, Value: authors[0]}}
+	r.Period = database.PeriodMonth
+	analytics, err = search.GetPostAnalytics(t.Context(), r)
+	if err != nil {
+		t.Fatalf("GetPostAnalytics(month, one author) error = %v", err)
+	}
+	check(analytics.Posts, 2)
+
+	for _, bad := range []AnalyticsRange{
+		{Period: "year"},
+		{From: r.To, To: r.From},
+		{Percentiles: []float64{1.5}},
+		{From: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if _, err := search.GetPostAnalytics(t.Context(), bad); err == nil {
+			t.Errorf("GetPostAnalytics(%+v) should fail", bad)
+		}
+	}
+}
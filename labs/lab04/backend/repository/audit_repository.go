@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+
+	"lab04-backend/audit"
+	"lab04-backend/database"
+	"lab04-backend/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+)
+
+// withAudit runs fn in a transaction, or a savepoint when db already is one,
+// and records the audit entries fn returns in the same transaction
+func withAudit(ctx context.Context, db database.DBTX, fn func(tx database.DBTX) ([]audit.Entry, error)) error {
+	return withTx(ctx, db, func(tx database.DBTX) error {
+		entries, err := fn(tx)
+		if err != nil {
+			return err
+		}
+		return audit.Record(ctx, tx, entries...)
+	})
+}
+
+// auditEntry returns a single audit entry for withAudit
+func auditEntry(ctx context.Context, entityType string, id int, action audit.Action, before, after interface{}) ([]audit.Entry, error) {
+	entry, err := audit.NewEntry(ctx, entityType, int64(id), action, before, after)
+	if err != nil {
+		return nil, err
+	}
+	return []audit.Entry{entry}, nil
+}
+
+// purgeEntries returns a purge entry for each permanently removed row of
+// ids, recording the state it was removed in. rows must be loaded before the
+// delete; rows that were not removed after all are skipped.
+func purgeEntries[T any](ctx context.Context, entityType string, rows []T, id func(*T) int, ids []int) ([]audit.Entry, error) {
+	removed := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		removed[id] = true
+	}
+	entries := make([]audit.Entry, 0, len(ids))
+	for i := range rows {
+		row := &rows[i]
+		if !removed[id(row)] {
+			continue
+		}
+		entry, err := audit.NewEntry(ctx, entityType, int64(id(row)), audit.ActionPurge, row, nil)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// cascadeEntries returns a purge entry for each row that a delete removes
+// through ON DELETE CASCADE rather than by itself
+func cascadeEntries[T any](ctx context.Context, entityType string, rows []T, id func(*T) int) ([]audit.Entry, error) {
+	ids := make([]int, len(rows))
+	for i := range rows {
+		ids[i] = id(&rows[i])
+	}
+	return purgeEntries(ctx, entityType, rows, id, ids)
+}
+
+// cascadedComments returns the comments matching condition together with
+// every reply below them, which ON DELETE CASCADE removes along with them
+func cascadedComments(ctx context.Context, db database.DBTX, dialect database.Dialect, condition string, args ...interface{}) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	err := sqlscan.Select(ctx, db, &comments, dialect.Rebind(`
+		WITH RECURSIVE doomed (id) AS (
+			SELECT id FROM comments WHERE `+condition+`
+			UNION
+			SELECT c.id FROM comments c JOIN doomed d ON c.parent_id = d.id
+		)
+		SELECT `+commentColumns+` FROM comments
+		WHERE id IN (SELECT id FROM doomed)
+		ORDER BY id`), args...)
+	return comments, err
+}
+
+func userID(u *models.User) int       { return u.ID }
+func postID(p *models.Post) int       { return p.ID }
+func commentID(c *models.Comment) int { return c.ID }
+
+// returningIDs runs a statement ending in RETURNING id, such as a purge,
+// and returns the IDs of the affected rows
+func returningIDs(ctx context.Context, db database.DBTX, query string, args ...interface{}) ([]int, error) {
+	ids := []int{}
+	err := sqlscan.Select(ctx, db, &ids, query, args...)
+	return ids, err
+}
+
+// AuditRepository reads the audit log
+type AuditRepository struct {
+	db      database.DBTX
+	dialect database.Dialect
+}
+
+// NewAuditRepository creates a new AuditRepository
+func NewAuditRepository(db database.DBTX) *AuditRepository {
+	return &AuditRepository{db: db, dialect: database.DialectOf(db)}
+}
+
+const auditColumns = "id, actor, entity_type, entity_id, action, changes, created_at"
+
+// GetHistory is GetHistoryContext with context.Background()
+func (r *AuditRepository) GetHistory(entityType string, entityID int64) ([]audit.Entry, error) {
+	return r.GetHistoryContext(context.Background(), entityType, entityID)
+}
+
+// GetHistoryContext returns the changes made to an entity, oldest first
+func (r *AuditRepository) GetHistoryContext(ctx context.Context, entityType string, entityID int64) ([]audit.Entry, error) {
+	query := r.dialect.Rebind(`
+		SELECT ` + auditColumns + ` FROM audit_log
+		WHERE entity_type = ? AND entity_id = ?
+		ORDER BY created_at, id`)
+
+	return r.selectEntries(ctx, "AuditRepository.GetHistory", query, entityType, entityID)
+}
+
+// GetByActor is GetByActorContext with context.Background()
+func (r *AuditRepository) GetByActor(actor string, limit int) ([]audit.Entry, error) {
+	return r.GetByActorContext(context.Background(), actor, limit)
+}
+
+// GetByActorContext returns the latest changes made by an actor, newest first
+func (r *AuditRepository) GetByActorContext(ctx context.Context, actor string, limit int) ([]audit.Entry, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	query := r.dialect.Rebind(`
+		SELECT ` + auditColumns + ` FROM audit_log
+		WHERE actor = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`)
+
+	return r.selectEntries(ctx, "AuditRepository.GetByActor", query, actor, limit)
+}
+
+// selectEntries scans all audit entries returned by query with scany
+func (r *AuditRepository) selectEntries(ctx context.Context, op, query string, args ...interface{}) ([]audit.Entry, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	entries := []audit.Entry{}
+	err := sqlscan.Select(ctx, r.db, &entries, query, args...)
+	return entries, mapQueryError(ctx, op, err)
+}
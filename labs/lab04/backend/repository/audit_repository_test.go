@@ -0,0 +1,266 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"lab04-backend/audit"
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+)
+
+// actions returns the actions of entries in order
+func actions(entries []audit.Entry) []audit.Action {
+	result := make([]audit.Action, len(entries))
+	for i, entry := range entries {
+		result[i] = entry.Action
+	}
+	return result
+}
+
+func equalActions(got []audit.Action, want ...audit.Action) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAuditRepository(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	postRepo := NewPostRepository(db.Conn)
+	repo := NewAuditRepository(db.Conn)
+	ctx := audit.WithActor(context.Background(), "admin@example.com")
+
+	user, err := userRepo.CreateContext(ctx, &models.CreateUserRequest{Name: "Audited", Email: "audited@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	t.Run("UserHistory", func(t *testing.T) {
+		name := "Renamed"
+		if _, err := userRepo.UpdateContext(ctx, user.ID, &models.UpdateUserRequest{Name: &name}); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+		if err := userRepo.Delete(user.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		if _, err := userRepo.Restore(user.ID); err != nil {
+			t.Fatalf("Restore() failed: %v", err)
+		}
+
+		history, err := repo.GetHistory(audit.EntityUser, int64(user.ID))
+		if err != nil {
+			t.Fatalf("GetHistory() failed: %v", err)
+		}
+		got := actions(history)
+		if !equalActions(got, audit.ActionCreate, audit.ActionUpdate, audit.ActionDelete, audit.ActionRestore) {
+			t.Fatalf("GetHistory() actions = %v, want create, update, delete, restore", got)
+		}
+
+		if history[0].Changes["email"].New != "audited@example.com" {
+			t.Errorf("create changes = %v, want the new email", history[0].Changes)
+		}
+		update := history[1]
+		if len(update.Changes) != 1 || update.Changes["name"] != (audit.Change{Old: "Audited", New: "Renamed"}) {
+			t.Errorf("update changes = %v, want only the name", update.Changes)
+		}
+		if update.Actor != "admin@example.com" {
+			t.Errorf("update actor = %q, want admin@example.com", update.Actor)
+		}
+		if history[2].Actor != audit.SystemActor {
+			t.Errorf("delete actor = %q, want %q", history[2].Actor, audit.SystemActor)
+		}
+		if deleted := history[2].Changes["deleted_at"]; len(history[2].Changes) != 1 || deleted.Old != nil || deleted.New == nil {
+			t.Errorf("delete changes = %v, want deleted_at set", history[2].Changes)
+		}
+		if restored := history[3].Changes["deleted_at"]; len(history[3].Changes) != 1 || restored.Old == nil || restored.New != nil {
+			t.Errorf("restore changes = %v, want deleted_at cleared", history[3].Changes)
+		}
+	})
+
+	t.Run("PostHistory", func(t *testing.T) {
+		post, err := postRepo.CreateContext(ctx, &models.CreatePostRequest{UserID: user.ID, Title: "Audited post", Content: "Draft"})
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		published := true
+		if _, err := postRepo.UpdateContext(ctx, post.ID, &models.UpdatePostRequest{Published: &published}); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+		if err := postRepo.Delete(post.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		if _, err := postRepo.PurgeDeletedBefore(time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("PurgeDeletedBefore() failed: %v", err)
+		}
+
+		history, err := repo.GetHistory(audit.EntityPost, int64(post.ID))
+		if err != nil {
+			t.Fatalf("GetHistory() failed: %v", err)
+		}
+		got := actions(history)
+		if !equalActions(got, audit.ActionCreate, audit.ActionUpdate, audit.ActionDelete, audit.ActionPurge) {
+			t.Fatalf("GetHistory() actions = %v, want create, update, delete, purge", got)
+		}
+		if history[1].Changes["published"] != (audit.Change{Old: false, New: true}) {
+			t.Errorf("update changes = %v, want published", history[1].Changes)
+		}
+		if deleted := history[2].Changes["deleted_at"]; deleted.Old != nil || deleted.New == nil {
+			t.Errorf("delete changes = %v, want deleted_at set", history[2].Changes)
+		}
+		if purge := history[3].Changes["title"]; purge != (audit.Change{Old: "Audited post"}) {
+			t.Errorf("purge changes = %v, want the removed post", history[3].Changes)
+		}
+	})
+
+	t.Run("PurgeCascade", func(t *testing.T) {
+		commentRepo := NewCommentRepository(db.Conn)
+		author, err := userRepo.CreateContext(ctx, &models.CreateUserRequest{Name: "Leaving", Email: "leaving@example.com"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		post, err := postRepo.CreateContext(ctx, &models.CreatePostRequest{UserID: author.ID, Title: "Farewell", Content: "Bye"})
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		comment, err := commentRepo.CreateContext(ctx, &models.CreateCommentRequest{PostID: post.ID, UserID: user.ID, Content: "See you"})
+		if err != nil {
+			t.Fatalf("Failed to create comment: %v", err)
+		}
+		reply, err := commentRepo.CreateContext(ctx, &models.CreateCommentRequest{PostID: post.ID, UserID: user.ID, ParentID: &comment.ID, Content: "Soon"})
+		if err != nil {
+			t.Fatalf("Failed to create reply: %v", err)
+		}
+		if err := userRepo.Delete(author.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		if _, err := userRepo.PurgeDeletedBefore(time.Now().Add(time.Minute)); err != nil {
+			t.Fatalf("PurgeDeletedBefore() failed: %v", err)
+		}
+
+		// The user's posts and their comments are removed by ON DELETE
+		// CASCADE, and recorded as purged with their last state
+		for _, tt := range []struct {
+			entityType string
+			id         int
+			field      string
+			want       interface{}
+		}{
+			{audit.EntityUser, author.ID, "email", "leaving@example.com"},
+			{audit.EntityPost, post.ID, "title", "Farewell"},
+			{audit.EntityComment, comment.ID, "content", "See you"},
+			{audit.EntityComment, reply.ID, "content", "Soon"},
+		} {
+			history, err := repo.GetHistory(tt.entityType, int64(tt.id))
+			if err != nil {
+				t.Fatalf("GetHistory() failed: %v", err)
+			}
+			if len(history) == 0 || history[len(history)-1].Action != audit.ActionPurge {
+				t.Errorf("%s %d history = %v, want it to end with a purge", tt.entityType, tt.id, actions(history))
+				continue
+			}
+			if got := history[len(history)-1].Changes[tt.field]; got != (audit.Change{Old: tt.want}) {
+				t.Errorf("%s %d purge %s = %v, want %v", tt.entityType, tt.id, tt.field, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("FailedChange", func(t *testing.T) {
+		before, _ := repo.GetByActor("admin@example.com", 100)
+		email := "audited@example.com"
+		other, err := userRepo.CreateContext(ctx, &models.CreateUserRequest{Name: "Other", Email: "other@example.com"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		if _, err := userRepo.UpdateContext(ctx, other.ID, &models.UpdateUserRequest{Email: &email}); err == nil {
+			t.Fatal("Update() to a duplicate email should fail")
+		}
+
+		after, _ := repo.GetByActor("admin@example.com", 100)
+		if len(after) != len(before)+1 {
+			t.Errorf("GetByActor() returned %d entries after a failed update, want %d", len(after), len(before)+1)
+		}
+	})
+
+	t.Run("ByActor", func(t *testing.T) {
+		entries, err := repo.GetByActor("admin@example.com", 2)
+		if err != nil {
+			t.Fatalf("GetByActor() failed: %v", err)
+		}
+		if len(entries) != 2 || entries[0].ID < entries[1].ID {
+			t.Errorf("GetByActor() = %v, want the 2 newest entries first", entries)
+		}
+	})
+}
+
+func TestAuditRepository_Categories(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	categoryRepo := NewCategoryRepository(db.Gorm)
+	repo := NewAuditRepository(db.Conn)
+	ctx := audit.WithActor(context.Background(), "editor@example.com")
+
+	category := &models.Category{Name: "Audited", Description: "Before"}
+	if err := categoryRepo.CreateContext(ctx, category); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	// Saving an unchanged category records nothing
+	if err := categoryRepo.UpdateContext(ctx, category); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	category.Description = "After"
+	if err := categoryRepo.UpdateContext(ctx, category); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if err := categoryRepo.Delete(category.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := categoryRepo.Restore(category.ID); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+
+	history, err := repo.GetHistory(audit.EntityCategory, int64(category.ID))
+	if err != nil {
+		t.Fatalf("GetHistory() failed: %v", err)
+	}
+	got := actions(history)
+	if !equalActions(got, audit.ActionCreate, audit.ActionUpdate, audit.ActionDelete, audit.ActionRestore) {
+		t.Fatalf("GetHistory() actions = %v, want create, update, delete, restore", got)
+	}
+	if history[0].Changes["color"].New != models.DefaultCategoryColor {
+		t.Errorf("create changes = %v, want the default color", history[0].Changes)
+	}
+	update := history[1]
+	if update.Actor != "editor@example.com" || update.Changes["description"] != (audit.Change{Old: "Before", New: "After"}) {
+		t.Errorf("update = %+v, want the description changed by editor@example.com", update)
+	}
+	if got := history[2].Changes["name"]; got != (audit.Change{Old: "Audited"}) {
+		t.Errorf("delete changes = %v, want the deleted category", history[2].Changes)
+	}
+	if got := history[3].Changes["name"]; got != (audit.Change{New: "Audited"}) {
+		t.Errorf("restore changes = %v, want the restored category", history[3].Changes)
+	}
+
+	if err := categoryRepo.Delete(category.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := categoryRepo.PurgeDeletedBefore(time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("PurgeDeletedBefore() failed: %v", err)
+	}
+	history, err = repo.GetHistory(audit.EntityCategory, int64(category.ID))
+	if err != nil {
+		t.Fatalf("GetHistory() failed: %v", err)
+	}
+	if purge := history[len(history)-1]; purge.Action != audit.ActionPurge || purge.Changes["description"] != (audit.Change{Old: "After"}) {
+		t.Errorf("purge = %+v, want the removed category", purge)
+	}
+}
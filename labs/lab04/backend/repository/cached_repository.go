@@ -0,0 +1,264 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"lab04-backend/cache"
+	"lab04-backend/models"
+)
+
+// DefaultCacheTTL is used by the cached repositories when no TTL is given
+const DefaultCacheTTL = 5 * time.Minute
+
+// readThrough returns the value cached under key, or loads it and caches it
+// for ttl. Cache failures are counted in m and fall back to load; errors of
+// load, such as not found, are returned and not cached.
+func readThrough[T any](ctx context.Context, c cache.Cache, m *cache.Metrics, key string, ttl time.Duration, load func() (*T, error)) (*T, error) {
+	data, err := c.Get(ctx, key)
+	if err == nil {
+		var value T
+		if err := json.Unmarshal(data, &value); err == nil {
+			m.Hit()
+			return &value, nil
+		}
+	}
+	if !errors.Is(err, cache.ErrMiss) {
+		m.Error()
+	}
+	m.Miss()
+
+	value, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if data, err := json.Marshal(value); err != nil || c.Set(ctx, key, data, ttl) != nil {
+		m.Error()
+	}
+	return value, nil
+}
+
+// invalidate removes keys from c. The change they describe is already
+// committed, so a failure is only counted in m; the entries then expire
+// after their TTL.
+func invalidate(ctx context.Context, c cache.Cache, m *cache.Metrics, keys ...string) {
+	if err := c.Delete(ctx, keys...); err != nil {
+		m.Error()
+	}
+}
+
+// CachedUserRepository is a UserRepository whose GetByID and GetByEmail
+// read through a cache. Update and Delete invalidate the cached user; writes
+// made through another repository, e.g. in a UnitOfWork, are only seen once
+// the entries expire.
+type CachedUserRepository struct {
+	*UserRepository
+	cache   cache.Cache
+	ttl     time.Duration
+	metrics cache.Metrics
+}
+
+// NewCachedUserRepository wraps repo with c. Entries live for ttl, or
+// DefaultCacheTTL when ttl <= 0.
+func NewCachedUserRepository(repo *UserRepository, c cache.Cache, ttl time.Duration) *CachedUserRepository {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &CachedUserRepository{UserRepository: repo, cache: c, ttl: ttl}
+}
+
+func userIDKey(id int) string          { return fmt.Sprintf("user:id:%d", id) }
+func userEmailKey(email string) string { return "user:email:" + email }
+
+// Stats returns the hit and miss counts of the cache
+func (r *CachedUserRepository) Stats() cache.Stats {
+	return r.metrics.Stats()
+}
+
+// GetByID is GetByIDContext with context.Background()
+func (r *CachedUserRepository) GetByID(id int) (*models.User, error) {
+	return r.GetByIDContext(context.Background(), id)
+}
+
+// GetByIDContext returns the cached user or reads it with UserRepository.GetByIDContext
+func (r *CachedUserRepository) GetByIDContext(ctx context.Context, id int) (*models.User, error) {
+	return readThrough(ctx, r.cache, &r.metrics, userIDKey(id), r.ttl, func() (*models.User, error) {
+		return r.UserRepository.GetByIDContext(ctx, id)
+	})
+}
+
+// GetByEmail is GetByEmailContext with context.Background()
+func (r *CachedUserRepository) GetByEmail(email string) (*models.User, error) {
+	return r.GetByEmailContext(context.Background(), email)
+}
+
+// GetByEmailContext returns the cached user or reads it with UserRepository.GetByEmailContext
+func (r *CachedUserRepository) GetByEmailContext(ctx context.Context, email string) (*models.User, error) {
+	return readThrough(ctx, r.cache, &r.metrics, userEmailKey(email), r.ttl, func() (*models.User, error) {
+		return r.UserRepository.GetByEmailContext(ctx, email)
+	})
+}
+
+// Update is UpdateContext with context.Background()
+func (r *CachedUserRepository) Update(id int, req *models.UpdateUserRequest) (*models.User, error) {
+	return r.UpdateContext(context.Background(), id, req)
+}
+
+// UpdateContext updates the user and invalidates it under its old and new email
+func (r *CachedUserRepository) UpdateContext(ctx context.Context, id int, req *models.UpdateUserRequest) (*models.User, error) {
+	before, _ := r.UserRepository.GetByIDContext(ctx, id)
+	user, err := r.UserRepository.UpdateContext(ctx, id, req)
+	if err != nil {
+		return nil, err
+	}
+	r.invalidate(ctx, id, before, user)
+	return user, nil
+}
+
+// Delete is DeleteContext with context.Background()
+func (r *CachedUserRepository) Delete(id int) error {
+	return r.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext deletes the user and invalidates it
+func (r *CachedUserRepository) DeleteContext(ctx context.Context, id int) error {
+	before, _ := r.UserRepository.GetByIDContext(ctx, id)
+	if err := r.UserRepository.DeleteContext(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, id, before)
+	return nil
+}
+
+// invalidate removes the ID entry and the email entries of users
+func (r *CachedUserRepository) invalidate(ctx context.Context, id int, users ...*models.User) {
+	keys := []string{userIDKey(id)}
+	for _, user := range users {
+		if user != nil {
+			keys = append(keys, userEmailKey(user.Email))
+		}
+	}
+	invalidate(ctx, r.cache, &r.metrics, keys...)
+}
+
+// CachedCategoryRepository is a CategoryRepository whose FindByName reads
+// through a cache. Update and Delete invalidate the cached category.
+type CachedCategoryRepository struct {
+	*CategoryRepository
+	cache   cache.Cache
+	ttl     time.Duration
+	metrics cache.Metrics
+}
+
+// NewCachedCategoryRepository wraps repo with c. Entries live for ttl, or
+// DefaultCacheTTL when ttl <= 0.
+func NewCachedCategoryRepository(repo *CategoryRepository, c cache.Cache, ttl time.Duration) *CachedCategoryRepository {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &CachedCategoryRepository{CategoryRepository: repo, cache: c, ttl: ttl}
+}
+
+func categoryNameKey(name string) string { return "category:name:" + name }
+
+// Stats returns the hit and miss counts of the cache
+func (r *CachedCategoryRepository) Stats() cache.Stats {
+	return r.metrics.Stats()
+}
+
+// FindByName is FindByNameContext with context.Background()
+func (r *CachedCategoryRepository) FindByName(name string) (*models.Category, error) {
+	return r.FindByNameContext(context.Background(), name)
+}
+
+// FindByNameContext returns the cached category or reads it with
+// CategoryRepository.FindByNameContext
+func (r *CachedCategoryRepository) FindByNameContext(ctx context.Context, name string) (*models.Category, error) {
+	return readThrough(ctx, r.cache, &r.metrics, categoryNameKey(name), r.ttl, func() (*models.Category, error) {
+		return r.CategoryRepository.FindByNameContext(ctx, name)
+	})
+}
+
+// Update is UpdateContext with context.Background()
+func (r *CachedCategoryRepository) Update(category *models.Category) error {
+	return r.UpdateContext(context.Background(), category)
+}
+
+// UpdateContext saves the category and invalidates it under its old and new name
+func (r *CachedCategoryRepository) UpdateContext(ctx context.Context, category *models.Category) error {
+	before, _ := r.CategoryRepository.GetByIDContext(ctx, category.ID)
+	if err := r.CategoryRepository.UpdateContext(ctx, category); err != nil {
+		return err
+	}
+	r.invalidate(ctx, before, category)
+	return nil
+}
+
+// Delete is DeleteContext with context.Background()
+func (r *CachedCategoryRepository) Delete(id uint) error {
+	return r.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext deletes the category and invalidates it
+func (r *CachedCategoryRepository) DeleteContext(ctx context.Context, id uint) error {
+	before, _ := r.CategoryRepository.GetByIDContext(ctx, id)
+	if err := r.CategoryRepository.DeleteContext(ctx, id); err != nil {
+		return err
+	}
+	r.invalidate(ctx, before)
+	return nil
+}
+
+// Move is MoveContext with context.Background()
+func (r *CachedCategoryRepository) Move(id uint, parentID *uint) (*models.Category, error) {
+	return r.MoveContext(context.Background(), id, parentID)
+}
+
+// MoveContext moves the category and invalidates it and its descendants,
+// whose paths change with it
+func (r *CachedCategoryRepository) MoveContext(ctx context.Context, id uint, parentID *uint) (*models.Category, error) {
+	descendants, _ := r.CategoryRepository.GetDescendantsContext(ctx, id)
+	category, err := r.CategoryRepository.MoveContext(ctx, id, parentID)
+	if err != nil {
+		return nil, err
+	}
+	moved := []*models.Category{category}
+	for i := range descendants {
+		moved = append(moved, &descendants[i])
+	}
+	r.invalidate(ctx, moved...)
+	return category, nil
+}
+
+// Reorder is ReorderContext with context.Background()
+func (r *CachedCategoryRepository) Reorder(parentID *uint, ids []uint) error {
+	return r.ReorderContext(context.Background(), parentID, ids)
+}
+
+// ReorderContext reorders the children of parentID and invalidates them
+func (r *CachedCategoryRepository) ReorderContext(ctx context.Context, parentID *uint, ids []uint) error {
+	if err := r.CategoryRepository.ReorderContext(ctx, parentID, ids); err != nil {
+		return err
+	}
+	children, _ := r.CategoryRepository.GetChildrenContext(ctx, parentID)
+	reordered := make([]*models.Category, len(children))
+	for i := range children {
+		reordered[i] = &children[i]
+	}
+	r.invalidate(ctx, reordered...)
+	return nil
+}
+
+// invalidate removes the name entries of categories
+func (r *CachedCategoryRepository) invalidate(ctx context.Context, categories ...*models.Category) {
+	var keys []string
+	for _, category := range categories {
+		if category != nil {
+			keys = append(keys, categoryNameKey(category.Name))
+		}
+	}
+	invalidate(ctx, r.cache, &r.metrics, keys...)
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"lab04-backend/cache"
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+
+	"gorm.io/gorm"
+)
+
+// failingCache is a cache whose server is down
+type failingCache struct{}
+
+var errCacheDown = errors.New("cache down")
+
+func (failingCache) Get(context.Context, string) ([]byte, error) { return nil, errCacheDown }
+func (failingCache) Set(context.Context, string, []byte, time.Duration) error {
+	return errCacheDown
+}
+func (failingCache) Delete(context.Context, ...string) error { return errCacheDown }
+
+func TestCachedUserRepository(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	repo := NewCachedUserRepository(userRepo, cache.NewLRU(100), 0)
+
+	user, err := repo.Create(&models.CreateUserRequest{Name: "Cached", Email: "cached@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	t.Run("ReadThrough", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			got, err := repo.GetByID(user.ID)
+			if err != nil || got.Email != user.Email {
+				t.Fatalf("GetByID() = %v, %v; want %s", got, err, user.Email)
+			}
+		}
+		if _, err := repo.GetByEmail(user.Email); err != nil {
+			t.Fatalf("GetByEmail() failed: %v", err)
+		}
+		if stats := repo.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+			t.Errorf("Stats() = %+v, want 2 hits and 2 misses", stats)
+		}
+	})
+
+	t.Run("UpdateInvalidates", func(t *testing.T) {
+		email := "renamed@example.com"
+		if _, err := repo.Update(user.ID, &models.UpdateUserRequest{Email: &email}); err != nil {
+			t.Fatalf("Update() failed: %v", err)
+		}
+		got, err := repo.GetByID(user.ID)
+		if err != nil || got.Email != email {
+			t.Errorf("GetByID() after Update() = %v, %v; want %s", got, err, email)
+		}
+		if _, err := repo.GetByEmail("cached@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByEmail(old email) error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("DeleteInvalidates", func(t *testing.T) {
+		repo.GetByEmail("renamed@example.com")
+		if err := repo.Delete(user.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		if _, err := repo.GetByID(user.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByID() after Delete() error = %v, want sql.ErrNoRows", err)
+		}
+		if _, err := repo.GetByEmail("renamed@example.com"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetByEmail() after Delete() error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("CacheDown", func(t *testing.T) {
+		other, err := userRepo.Create(&models.CreateUserRequest{Name: "Uncached", Email: "uncached@example.com"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		down := NewCachedUserRepository(userRepo, failingCache{}, 0)
+		if _, err := down.GetByID(other.ID); err != nil {
+			t.Errorf("GetByID() with the cache down error = %v, want a database read", err)
+		}
+		if stats := down.Stats(); stats.Errors != 2 || stats.Misses != 1 {
+			t.Errorf("Stats() = %+v, want 1 miss and 2 errors", stats)
+		}
+	})
+}
+
+func TestCachedCategoryRepository(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewCachedCategoryRepository(NewCategoryRepository(db.Gorm), cache.NewLRU(100), 0)
+
+	category := &models.Category{Name: "Cached"}
+	if err := repo.Create(category); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	repo.FindByName("Cached")
+	if _, err := repo.FindByName("Cached"); err != nil {
+		t.Fatalf("FindByName() failed: %v", err)
+	}
+	if stats := repo.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Stats() = %+v, want 1 hit and 1 miss", stats)
+	}
+
+	category.Name = "Renamed"
+	if err := repo.Update(category); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if _, err := repo.FindByName("Cached"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByName(old name) error = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	repo.FindByName("Renamed")
+	if err := repo.Delete(category.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := repo.FindByName("Renamed"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("FindByName() after Delete() error = %v, want gorm.ErrRecordNotFound", err)
+	}
+}
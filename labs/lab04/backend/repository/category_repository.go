@@ -1,8 +1,14 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
+	"lab04-backend/audit"
+	"lab04-backend/database"
+	"lab04-backend/filter"
 	"lab04-backend/models"
 
 	"gorm.io/gorm"
@@ -19,149 +25,346 @@ func NewCategoryRepository(gormDB *gorm.DB) *CategoryRepository {
 	return &CategoryRepository{db: gormDB}
 }
 
-// TODO: Implement Create method using GORM
+// session returns a GORM handle bound to ctx, bounded by the per-query
+// timeout of the underlying connection. Unscoped makes GORM return soft
+// deleted rows as well.
+func (r *CategoryRepository) session(ctx context.Context, opts []ListOption) (*gorm.DB, context.CancelFunc) {
+	cancel := context.CancelFunc(func() {})
+	if conn, ok := r.db.Statement.ConnPool.(database.DBTX); ok {
+		ctx, cancel = queryContext(ctx, conn)
+	}
+
+	db := r.db.WithContext(ctx)
+	if applyListOptions(opts).includeDeleted {
+		db = db.Unscoped()
+	}
+	return db, cancel
+}
+
+// mapError maps deadline errors of a session to a *QueryTimeoutError
+func mapError(db *gorm.DB, op string, err error) error {
+	return mapQueryError(db.Statement.Context, op, err)
+}
+
+// Create is CreateContext with context.Background()
 func (r *CategoryRepository) Create(category *models.Category) error {
-	// TODO: Create a new category using GORM
-	// - Use GORM's Create method: r.db.Create(category)
-	// - GORM automatically handles ID generation and timestamps
-	// - No need for manual SQL or RETURNING clauses
-	// Example: result := r.db.Create(category)
-	// return result.Error
-	//
-	// Notice how much simpler this is compared to manual SQL!
-	return fmt.Errorf("TODO: implement Create method with GORM")
-}
-
-// TODO: Implement GetByID method using GORM
+	return r.CreateContext(context.Background(), category)
+}
+
+// CreateContext inserts a new category. GORM fills in the ID and timestamps.
+func (r *CategoryRepository) CreateContext(ctx context.Context, category *models.Category) error {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	return mapError(db, "CategoryRepository.Create", db.Create(category).Error)
+}
+
+// GetByID is GetByIDContext with context.Background()
 func (r *CategoryRepository) GetByID(id uint) (*models.Category, error) {
-	// TODO: Get category by ID using GORM
-	// - Use GORM's First method: r.db.First(&category, id)
-	// - GORM automatically generates the WHERE clause
-	// - Returns gorm.ErrRecordNotFound if not found
-	// Example:
-	// var category models.Category
-	// result := r.db.First(&category, id)
-	// return &category, result.Error
-	//
-	// Much cleaner than manual row scanning!
-	return nil, fmt.Errorf("TODO: implement GetByID method with GORM")
-}
-
-// TODO: Implement GetAll method using GORM
-func (r *CategoryRepository) GetAll() ([]models.Category, error) {
-	// TODO: Get all categories using GORM
-	// - Use GORM's Find method: r.db.Find(&categories)
-	// - GORM automatically handles the slice allocation
-	// - Can add Order() for sorting: r.db.Order("name").Find(&categories)
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Order("name").Find(&categories)
-	// return categories, result.Error
-	return nil, fmt.Errorf("TODO: implement GetAll method with GORM")
-}
-
-// TODO: Implement Update method using GORM
+	return r.GetByIDContext(context.Background(), id)
+}
+
+// GetByIDContext returns the category with the given ID or
+// gorm.ErrRecordNotFound. Soft deleted categories are not found.
+func (r *CategoryRepository) GetByIDContext(ctx context.Context, id uint) (*models.Category, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	var category models.Category
+	if err := db.First(&category, id).Error; err != nil {
+		return nil, mapError(db, "CategoryRepository.GetByID", err)
+	}
+	return &category, nil
+}
+
+// GetAll is GetAllContext with context.Background()
+func (r *CategoryRepository) GetAll(opts ...ListOption) ([]models.Category, error) {
+	return r.GetAllContext(context.Background(), opts...)
+}
+
+// GetAllContext returns all categories ordered by name. GetTree returns
+// them as a hierarchy in their manual order.
+func (r *CategoryRepository) GetAllContext(ctx context.Context, opts ...ListOption) ([]models.Category, error) {
+	db, cancel := r.session(ctx, opts)
+	defer cancel()
+
+	var categories []models.Category
+	err := db.Order("name").Find(&categories).Error
+	return categories, mapError(db, "CategoryRepository.GetAll", err)
+}
+
+// Update is UpdateContext with context.Background()
 func (r *CategoryRepository) Update(category *models.Category) error {
-	// TODO: Update category using GORM
-	// - Use GORM's Save method: r.db.Save(category)
-	// - GORM automatically updates only changed fields
-	// - Handles updated_at timestamp automatically
-	// - Alternative: r.db.Model(category).Updates(updates)
-	//
-	// Example:
-	// result := r.db.Save(category)
-	// return result.Error
-	return fmt.Errorf("TODO: implement Update method with GORM")
-}
-
-// TODO: Implement Delete method using GORM
+	return r.UpdateContext(context.Background(), category)
+}
+
+// UpdateContext saves all fields of the category
+func (r *CategoryRepository) UpdateContext(ctx context.Context, category *models.Category) error {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	return mapError(db, "CategoryRepository.Update", db.Save(category).Error)
+}
+
+// Delete is DeleteContext with context.Background()
 func (r *CategoryRepository) Delete(id uint) error {
-	// TODO: Delete category using GORM
-	// - Use GORM's Delete method: r.db.Delete(&models.Category{}, id)
-	// - GORM can do soft delete if model has DeletedAt field
-	// - For hard delete: r.db.Unscoped().Delete(&models.Category{}, id)
-	//
-	// Example:
-	// result := r.db.Delete(&models.Category{}, id)
-	// return result.Error
-	return fmt.Errorf("TODO: implement Delete method with GORM")
-}
-
-// TODO: Implement FindByName method using GORM
+	return r.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext soft deletes the category with the given ID or returns
+// gorm.ErrRecordNotFound. A category with live children fails with
+// ErrCategoryHasChildren; move or delete them first.
+func (r *CategoryRepository) DeleteContext(ctx context.Context, id uint) error {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var children int64
+		if err := tx.Model(&models.Category{}).Scopes(models.ChildrenOf(&id)).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return ErrCategoryHasChildren
+		}
+		var category models.Category
+		if err := tx.First(&category, id).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Category{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return recordCategory(tx, id, audit.ActionDelete, &category, nil)
+	})
+	return mapError(db, "CategoryRepository.Delete", err)
+}
+
+// Restore is RestoreContext with context.Background()
+func (r *CategoryRepository) Restore(id uint) (*models.Category, error) {
+	return r.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext undoes a soft delete and returns the category, or
+// gorm.ErrRecordNotFound if there is no deleted category with the given ID.
+// The parent of a child category must be restored first.
+func (r *CategoryRepository) RestoreContext(ctx context.Context, id uint) (*models.Category, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var category models.Category
+		if err := tx.Unscoped().First(&category, id).Error; err != nil {
+			return err
+		}
+		if category.ParentID != nil {
+			if err := tx.Select("id").First(&models.Category{}, *category.ParentID).Error; err != nil {
+				return fmt.Errorf("parent category %d: %w", *category.ParentID, err)
+			}
+		}
+		result := tx.Unscoped().Model(&models.Category{}).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			UpdateColumns(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return recordCategory(tx, id, audit.ActionRestore, nil, &category)
+	})
+	if err != nil {
+		return nil, mapError(db, "CategoryRepository.Restore", err)
+	}
+	return r.GetByIDContext(ctx, id)
+}
+
+// PurgeDeletedBefore is PurgeDeletedBeforeContext with context.Background()
+func (r *CategoryRepository) PurgeDeletedBefore(before time.Time) (int64, error) {
+	return r.PurgeDeletedBeforeContext(context.Background(), before)
+}
+
+// PurgeDeletedBeforeContext permanently removes categories soft deleted
+// before the given time and returns the number of categories removed. The
+// soft deleted descendants of a purged category go with it, whenever they
+// were deleted, so no tombstone is left pointing at a missing parent.
+func (r *CategoryRepository) PurgeDeletedBeforeContext(ctx context.Context, before time.Time) (int64, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Paths are IDs and slashes only, so they hold no LIKE wildcards
+		var categories []models.Category
+		err := tx.Unscoped().Where(`deleted_at IS NOT NULL AND EXISTS (
+				SELECT 1 FROM categories purged
+				WHERE purged.deleted_at IS NOT NULL AND purged.deleted_at < ?
+					AND categories.path LIKE purged.path || '%'
+			)`, before).
+			Find(&categories).Error
+		if err != nil || len(categories) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(categories))
+		for i, category := range categories {
+			ids[i] = category.ID
+		}
+		result := tx.Unscoped().Delete(&models.Category{}, ids)
+		if result.Error != nil {
+			return result.Error
+		}
+		purged = result.RowsAffected
+		for i := range categories {
+			if err := recordCategory(tx, categories[i].ID, audit.ActionPurge, &categories[i], nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return purged, mapError(db, "CategoryRepository.PurgeDeletedBefore", err)
+}
+
+// recordCategory records a delete, restore or purge in the audit log. The
+// JSON form of a category has no deleted_at, so a soft deleted category is
+// recorded as gone: before is nil for a restore and after for the others.
+func recordCategory(tx *gorm.DB, id uint, action audit.Action, before, after *models.Category) error {
+	entry, err := audit.NewEntry(tx.Statement.Context, audit.EntityCategory, int64(id), action, before, after)
+	if err != nil {
+		return err
+	}
+	return audit.RecordGORM(tx, entry)
+}
+
+// FindByName is FindByNameContext with context.Background()
 func (r *CategoryRepository) FindByName(name string) (*models.Category, error) {
-	// TODO: Find category by name using GORM
-	// - Use GORM's Where method: r.db.Where("name = ?", name).First(&category)
-	// - GORM handles SQL injection protection automatically
-	// - Can use struct for conditions: r.db.Where(&models.Category{Name: name}).First(&category)
-	//
-	// Example:
-	// var category models.Category
-	// result := r.db.Where("name = ?", name).First(&category)
-	// return &category, result.Error
-	return nil, fmt.Errorf("TODO: implement FindByName method with GORM")
-}
-
-// TODO: Implement SearchCategories method using GORM
-func (r *CategoryRepository) SearchCategories(query string, limit int) ([]models.Category, error) {
-	// TODO: Search categories using GORM
-	// - Use GORM's Where with LIKE: r.db.Where("name LIKE ?", "%"+query+"%")
-	// - Add Limit: .Limit(limit)
-	// - Add Order: .Order("name")
-	//
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Where("name LIKE ?", "%"+query+"%").
-	//               Order("name").
-	//               Limit(limit).
-	//               Find(&categories)
-	// return categories, result.Error
-	return nil, fmt.Errorf("TODO: implement SearchCategories method with GORM")
-}
-
-// TODO: Implement GetCategoriesWithPosts method using GORM associations
+	return r.FindByNameContext(context.Background(), name)
+}
+
+// FindByNameContext returns the category with the given name or gorm.ErrRecordNotFound
+func (r *CategoryRepository) FindByNameContext(ctx context.Context, name string) (*models.Category, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	var category models.Category
+	if err := db.Where("name = ?", name).First(&category).Error; err != nil {
+		return nil, mapError(db, "CategoryRepository.FindByName", err)
+	}
+	return &category, nil
+}
+
+// SearchCategories is SearchCategoriesContext with context.Background()
+func (r *CategoryRepository) SearchCategories(query string, limit int, opts ...ListOption) ([]models.Category, error) {
+	return r.SearchCategoriesContext(context.Background(), query, limit, opts...)
+}
+
+// SearchCategoriesContext returns categories whose name contains query
+func (r *CategoryRepository) SearchCategoriesContext(ctx context.Context, query string, limit int, opts ...ListOption) ([]models.Category, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	db, cancel := r.session(ctx, opts)
+	defer cancel()
+
+	var categories []models.Category
+	err := db.Where(`name LIKE ? ESCAPE '\'`, "%"+database.EscapeLike(query)+"%").
+		Order("name").
+		Limit(limit).
+		Find(&categories).Error
+	return categories, mapError(db, "CategoryRepository.SearchCategories", err)
+}
+
+// Filter is FilterContext with context.Background()
+func (r *CategoryRepository) Filter(q filter.Query, limit int, opts ...ListOption) ([]models.Category, error) {
+	return r.FilterContext(context.Background(), q, limit, opts...)
+}
+
+// FilterContext returns the categories matching q, ordered by q.Sort or by
+// name. The fields are those of CategorySchema.
+func (r *CategoryRepository) FilterContext(ctx context.Context, q filter.Query, limit int, opts ...ListOption) ([]models.Category, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if len(q.Sort) == 0 {
+		q.Sort = []filter.Sort{{Field: "name"}}
+	}
+	orderBy, err := CategorySchema.OrderBy(q.Sort...)
+	if err != nil {
+		return nil, err
+	}
+	dialect, err := database.ParseDialect(r.db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	where, args, err := CategorySchema.Where(dialect, q.Filters...).ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	db, cancel := r.session(ctx, opts)
+	defer cancel()
+
+	query := db.Order(strings.Join(orderBy, ", ")).Limit(limit)
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	var categories []models.Category
+	err = query.Find(&categories).Error
+	return categories, mapError(db, "CategoryRepository.Filter", err)
+}
+
+// GetCategoriesWithPosts is GetCategoriesWithPostsContext with context.Background()
 func (r *CategoryRepository) GetCategoriesWithPosts() ([]models.Category, error) {
-	// TODO: Get categories with associated posts using GORM
-	// - Use GORM's Preload to load associations: r.db.Preload("Posts").Find(&categories)
-	// - GORM automatically handles the JOINs and relationships
-	// - Much simpler than manual JOIN queries!
-	//
-	// Example:
-	// var categories []models.Category
-	// result := r.db.Preload("Posts").Find(&categories)
-	// return categories, result.Error
-	//
-	// This assumes Category model has Posts relationship defined
-	return nil, fmt.Errorf("TODO: implement GetCategoriesWithPosts method with GORM Preload")
-}
-
-// TODO: Implement Count method using GORM
+	return r.GetCategoriesWithPostsContext(context.Background())
+}
+
+// GetCategoriesWithPostsContext returns all categories with their posts
+// preloaded. Deleted posts are left out.
+func (r *CategoryRepository) GetCategoriesWithPostsContext(ctx context.Context) ([]models.Category, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	var categories []models.Category
+	err := db.Preload("Posts", "posts.deleted_at IS NULL").Order("name").Find(&categories).Error
+	return categories, mapError(db, "CategoryRepository.GetCategoriesWithPosts", err)
+}
+
+// Count is CountContext with context.Background()
 func (r *CategoryRepository) Count() (int64, error) {
-	// TODO: Count categories using GORM
-	// - Use GORM's Count method: r.db.Model(&models.Category{}).Count(&count)
-	// - GORM returns int64 for count operations
-	//
-	// Example:
-	// var count int64
-	// result := r.db.Model(&models.Category{}).Count(&count)
-	// return count, result.Error
-	return 0, fmt.Errorf("TODO: implement Count method with GORM")
-}
-
-// TODO: Implement Transaction example using GORM
+	return r.CountContext(context.Background())
+}
+
+// CountContext returns the number of categories that are not deleted
+func (r *CategoryRepository) CountContext(ctx context.Context) (int64, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	var count int64
+	err := db.Model(&models.Category{}).Count(&count).Error
+	return count, mapError(db, "CategoryRepository.Count", err)
+}
+
+// CreateWithTransaction is CreateWithTransactionContext with context.Background()
 func (r *CategoryRepository) CreateWithTransaction(categories []models.Category) error {
-	// TODO: Create multiple categories in a transaction using GORM
-	// - Use GORM's Transaction method: r.db.Transaction(func(tx *gorm.DB) error {...})
-	// - GORM automatically handles rollback on error
-	// - Much simpler than manual transaction management!
-	//
-	// Example:
-	// return r.db.Transaction(func(tx *gorm.DB) error {
-	//     for _, category := range categories {
-	//         if err := tx.Create(&category).Error; err != nil {
-	//             return err // GORM will rollback automatically
-	//         }
-	//     }
-	//     return nil // GORM will commit automatically
-	// })
-	return fmt.Errorf("TODO: implement CreateWithTransaction method with GORM")
+	return r.CreateWithTransactionContext(context.Background(), categories)
+}
+
+// CreateWithTransactionContext creates all categories or none of them.
+// The per-query timeout applies to the transaction as a whole.
+func (r *CategoryRepository) CreateWithTransactionContext(ctx context.Context, categories []models.Category) error {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for i := range categories {
+			if err := tx.Create(&categories[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return mapError(db, "CategoryRepository.CreateWithTransaction", err)
 }
@@ -1,7 +1,14 @@
 package repository
 
 import (
+	"errors"
 	"testing"
+	"time"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+
+	"gorm.io/gorm"
 )
 
 // TestCategoryRepository tests the GORM ORM approach
@@ -246,6 +253,96 @@ func TestGORMScopes(t *testing.T) {
 	})
 }
 
+func TestCategoryRepository_SoftDelete(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewCategoryRepository(db.Gorm)
+
+	category := (&models.CreateCategoryRequest{Name: "Technology"}).ToCategory()
+	if err := repo.Create(category); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if category.Color != models.DefaultCategoryColor {
+		t.Errorf("Create() color = %q, want default %q", category.Color, models.DefaultCategoryColor)
+	}
+
+	if err := repo.Delete(category.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := repo.Delete(category.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Delete() twice error = %v, want gorm.ErrRecordNotFound", err)
+	}
+	if _, err := repo.GetByID(category.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetByID() of a deleted category error = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	all, err := repo.GetAll()
+	if err != nil || len(all) != 0 {
+		t.Errorf("GetAll() = %v, %v; want no categories", all, err)
+	}
+	all, err = repo.GetAll(IncludeDeleted())
+	if err != nil || len(all) != 1 {
+		t.Errorf("GetAll(IncludeDeleted()) = %v, %v; want the deleted category", all, err)
+	}
+
+	restored, err := repo.Restore(category.ID)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if restored.Name != "Technology" {
+		t.Errorf("Restore() name = %q, want Technology", restored.Name)
+	}
+	if _, err := repo.Restore(category.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Restore() of a live category error = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	if err := repo.Delete(category.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	purged, err := repo.PurgeDeletedBefore(time.Now().Add(time.Second))
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeDeletedBefore() = %d, %v; want 1", purged, err)
+	}
+	if count, _ := repo.Count(); count != 0 {
+		t.Errorf("Count() = %d, want 0", count)
+	}
+
+	// A purged parent takes its deleted subtree along, even children deleted
+	// after the cutoff
+	parent := (&models.CreateCategoryRequest{Name: "Science"}).ToCategory()
+	if err := repo.Create(parent); err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	child := (&models.CreateCategoryRequest{Name: "Physics"}).ToCategory()
+	child.ParentID = &parent.ID
+	if err := repo.Create(child); err != nil {
+		t.Fatalf("Create() child failed: %v", err)
+	}
+	grandchild := (&models.CreateCategoryRequest{Name: "Optics"}).ToCategory()
+	grandchild.ParentID = &child.ID
+	if err := repo.Create(grandchild); err != nil {
+		t.Fatalf("Create() grandchild failed: %v", err)
+	}
+	for _, id := range []uint{grandchild.ID, child.ID, parent.ID} {
+		if err := repo.Delete(id); err != nil {
+			t.Fatalf("Delete(%d) failed: %v", id, err)
+		}
+	}
+	cutoff := time.Now().Add(time.Second)
+	err = db.Gorm.Unscoped().Model(&models.Category{}).Where("id IN ?", []uint{child.ID, grandchild.ID}).
+		UpdateColumn("deleted_at", cutoff.Add(time.Hour)).Error
+	if err != nil {
+		t.Fatalf("Failed to move deleted_at: %v", err)
+	}
+	purged, err = repo.PurgeDeletedBefore(cutoff)
+	if err != nil || purged != 3 {
+		t.Errorf("PurgeDeletedBefore() = %d, %v; want the parent and its 2 descendants", purged, err)
+	}
+	if all, _ := repo.GetAll(IncludeDeleted()); len(all) != 0 {
+		t.Errorf("GetAll(IncludeDeleted()) after purging the parent = %v, want none", all)
+	}
+}
+
 // BenchmarkGORMVsSQL benchmarks GORM vs raw SQL performance
 func BenchmarkGORMVsSQL(b *testing.B) {
 	// TODO: Compare GORM vs raw SQL performance
@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"lab04-backend/audit"
+	"lab04-backend/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCategoryCycle is returned when a category would be moved under
+	// itself or one of its descendants
+	ErrCategoryCycle = errors.New("a category cannot be moved under itself or its descendants")
+	// ErrCategoryHasChildren is returned when a category with live children
+	// is deleted
+	ErrCategoryHasChildren = errors.New("category has child categories")
+	// ErrNotSibling is returned when Reorder is given a category that is not
+	// a child of the given parent
+	ErrNotSibling = errors.New("category is not a child of the given parent")
+)
+
+// treeOrder orders categories level by level, and siblings by their manual
+// sort order
+const treeOrder = "depth, sort_order, name"
+
+// GetChildren is GetChildrenContext with context.Background()
+func (r *CategoryRepository) GetChildren(parentID *uint, opts ...ListOption) ([]models.Category, error) {
+	return r.GetChildrenContext(context.Background(), parentID, opts...)
+}
+
+// GetChildrenContext returns the children of a category in their sort order,
+// or the root categories when parentID is nil
+func (r *CategoryRepository) GetChildrenContext(ctx context.Context, parentID *uint, opts ...ListOption) ([]models.Category, error) {
+	db, cancel := r.session(ctx, opts)
+	defer cancel()
+
+	var categories []models.Category
+	err := db.Scopes(models.ChildrenOf(parentID)).Order("sort_order, name").Find(&categories).Error
+	return categories, mapError(db, "CategoryRepository.GetChildren", err)
+}
+
+// GetAncestors is GetAncestorsContext with context.Background()
+func (r *CategoryRepository) GetAncestors(id uint) ([]models.Category, error) {
+	return r.GetAncestorsContext(context.Background(), id)
+}
+
+// GetAncestorsContext returns the ancestors of a category from the root
+// down to its parent, or gorm.ErrRecordNotFound if it does not exist
+func (r *CategoryRepository) GetAncestorsContext(ctx context.Context, id uint) ([]models.Category, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	var category models.Category
+	if err := db.First(&category, id).Error; err != nil {
+		return nil, mapError(db, "CategoryRepository.GetAncestors", err)
+	}
+	ids := pathIDs(category.Path)
+	ancestors := []models.Category{}
+	if len(ids) <= 1 {
+		return ancestors, nil
+	}
+	err := db.Where("id IN ?", ids[:len(ids)-1]).Order("depth").Find(&ancestors).Error
+	return ancestors, mapError(db, "CategoryRepository.GetAncestors", err)
+}
+
+// GetDescendants is GetDescendantsContext with context.Background()
+func (r *CategoryRepository) GetDescendants(id uint, opts ...ListOption) ([]models.Category, error) {
+	return r.GetDescendantsContext(context.Background(), id, opts...)
+}
+
+// GetDescendantsContext returns all categories below a category, level by
+// level and in sort order within a level, or gorm.ErrRecordNotFound if the
+// category does not exist
+func (r *CategoryRepository) GetDescendantsContext(ctx context.Context, id uint, opts ...ListOption) ([]models.Category, error) {
+	db, cancel := r.session(ctx, opts)
+	defer cancel()
+
+	var category models.Category
+	if err := db.First(&category, id).Error; err != nil {
+		return nil, mapError(db, "CategoryRepository.GetDescendants", err)
+	}
+	var descendants []models.Category
+	err := db.Where("path LIKE ? AND id <> ?", category.Path+"%", id).Order(treeOrder).Find(&descendants).Error
+	return descendants, mapError(db, "CategoryRepository.GetDescendants", err)
+}
+
+// GetTree is GetTreeContext with context.Background()
+func (r *CategoryRepository) GetTree(parentID *uint) ([]models.Category, error) {
+	return r.GetTreeContext(context.Background(), parentID)
+}
+
+// GetTreeContext returns the children of parentID, or the root categories
+// when it is nil, with their descendants nested in Children. Every level is
+// in sort order.
+func (r *CategoryRepository) GetTreeContext(ctx context.Context, parentID *uint) ([]models.Category, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	query := db.Order(treeOrder)
+	if parentID != nil {
+		var parent models.Category
+		if err := db.First(&parent, *parentID).Error; err != nil {
+			return nil, mapError(db, "CategoryRepository.GetTree", err)
+		}
+		query = query.Where("path LIKE ? AND id <> ?", parent.Path+"%", parent.ID)
+	}
+	var categories []models.Category
+	if err := query.Find(&categories).Error; err != nil {
+		return nil, mapError(db, "CategoryRepository.GetTree", err)
+	}
+
+	byParent := make(map[uint][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		if category.IsChildOf(parentID) {
+			roots = append(roots, category)
+		} else if category.ParentID != nil {
+			byParent[*category.ParentID] = append(byParent[*category.ParentID], category)
+		}
+	}
+	var attach func([]models.Category) []models.Category
+	attach = func(level []models.Category) []models.Category {
+		for i := range level {
+			level[i].Children = attach(byParent[level[i].ID])
+		}
+		return level
+	}
+	return attach(roots), nil
+}
+
+// Move is MoveContext with context.Background()
+func (r *CategoryRepository) Move(id uint, parentID *uint) (*models.Category, error) {
+	return r.MoveContext(context.Background(), id, parentID)
+}
+
+// MoveContext moves a category and its subtree under parentID, or to the
+// roots when it is nil, after the last of its new siblings. Moving a
+// category under itself or a descendant fails with ErrCategoryCycle.
+func (r *CategoryRepository) MoveContext(ctx context.Context, id uint, parentID *uint) (*models.Category, error) {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var before models.Category
+		if err := tx.First(&before, id).Error; err != nil {
+			return err
+		}
+		if before.IsChildOf(parentID) {
+			return nil
+		}
+
+		path, depth := "/", 0
+		if parentID != nil {
+			var parent models.Category
+			if err := tx.First(&parent, *parentID).Error; err != nil {
+				return fmt.Errorf("parent category %d: %w", *parentID, err)
+			}
+			if strings.HasPrefix(parent.Path, before.Path) {
+				return ErrCategoryCycle
+			}
+			path, depth = parent.Path, parent.Depth+1
+		}
+		path += strconv.FormatUint(uint64(id), 10) + "/"
+
+		subtree := tx.Unscoped().Model(&models.Category{}).Where("path LIKE ?", before.Path+"%").
+			Session(&gorm.Session{})
+		var deepest int
+		if err := subtree.Select("MAX(depth)").Scan(&deepest).Error; err != nil {
+			return err
+		}
+		if depth+deepest-before.Depth >= models.MaxCategoryDepth {
+			return models.ErrCategoryTooDeep
+		}
+		order, err := models.NextSortOrder(tx, parentID)
+		if err != nil {
+			return err
+		}
+
+		err = subtree.UpdateColumns(map[string]interface{}{
+			"path":  gorm.Expr("? || SUBSTR(path, ?)", path, len(before.Path)+1),
+			"depth": gorm.Expr("depth + ?", depth-before.Depth),
+		}).Error
+		if err != nil {
+			return err
+		}
+		after := before
+		after.ParentID, after.Path, after.Depth, after.SortOrder = parentID, path, depth, order
+		after.UpdatedAt = time.Now()
+		err = tx.Model(&models.Category{}).Where("id = ?", id).UpdateColumns(map[string]interface{}{
+			"parent_id": parentID, "sort_order": order, "updated_at": after.UpdatedAt,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return recordCategoryChange(tx, id, before, after)
+	})
+	if err != nil {
+		return nil, mapError(db, "CategoryRepository.Move", err)
+	}
+	return r.GetByIDContext(ctx, id)
+}
+
+// Reorder is ReorderContext with context.Background()
+func (r *CategoryRepository) Reorder(parentID *uint, ids []uint) error {
+	return r.ReorderContext(context.Background(), parentID, ids)
+}
+
+// ReorderContext puts the children of parentID (the roots when it is nil)
+// in the order of ids. Children left out of ids keep their relative order
+// after the listed ones. An ID that is not a live child fails with
+// ErrNotSibling.
+func (r *CategoryRepository) ReorderContext(ctx context.Context, parentID *uint, ids []uint) error {
+	db, cancel := r.session(ctx, nil)
+	defer cancel()
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var siblings []models.Category
+		if err := tx.Scopes(models.ChildrenOf(parentID)).Order("sort_order, name").Find(&siblings).Error; err != nil {
+			return err
+		}
+		current := make(map[uint]int, len(siblings))
+		for _, sibling := range siblings {
+			current[sibling.ID] = sibling.SortOrder
+		}
+
+		order := make([]uint, 0, len(siblings))
+		listed := make(map[uint]bool, len(ids))
+		for _, id := range ids {
+			if _, ok := current[id]; !ok || listed[id] {
+				return fmt.Errorf("%w: %d", ErrNotSibling, id)
+			}
+			listed[id] = true
+			order = append(order, id)
+		}
+		for _, sibling := range siblings {
+			if !listed[sibling.ID] {
+				order = append(order, sibling.ID)
+			}
+		}
+
+		type state struct {
+			SortOrder int `json:"sort_order"`
+		}
+		for position, id := range order {
+			if current[id] == position {
+				continue
+			}
+			err := tx.Model(&models.Category{}).Where("id = ?", id).UpdateColumn("sort_order", position).Error
+			if err != nil {
+				return err
+			}
+			if err := recordCategoryChange(tx, id, state{current[id]}, state{position}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return mapError(db, "CategoryRepository.Reorder", err)
+}
+
+// recordCategoryChange records the difference between two states of a
+// category in the audit log
+func recordCategoryChange(tx *gorm.DB, id uint, before, after interface{}) error {
+	entry, err := audit.NewEntry(tx.Statement.Context, audit.EntityCategory, int64(id), audit.ActionUpdate, before, after)
+	if err != nil || len(entry.Changes) == 0 {
+		return err
+	}
+	return audit.RecordGORM(tx, entry)
+}
+
+// pathIDs returns the category IDs of a materialized path, root first
+func pathIDs(path string) []uint {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(path, "/"), "/") {
+		if id, err := strconv.ParseUint(part, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+
+	"gorm.io/gorm"
+)
+
+func TestCategoryRepository_Tree(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	repo := NewCategoryRepository(db.Gorm)
+
+	create := func(name string, parent *models.Category) *models.Category {
+		t.Helper()
+		category := (&models.CreateCategoryRequest{Name: name}).ToCategory()
+		if parent != nil {
+			category.ParentID = &parent.ID
+		}
+		if err := repo.Create(category); err != nil {
+			t.Fatalf("Create(%s) failed: %v", name, err)
+		}
+		return category
+	}
+	names := func(categories []models.Category) []string {
+		var names []string
+		for _, category := range categories {
+			names = append(names, category.Name)
+		}
+		return names
+	}
+	equal := func(got, want []string) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	tech := create("Technology", nil)
+	golang := create("Go", tech)
+	generics := create("Generics", golang)
+	rust := create("Rust", tech)
+	food := create("Food", nil)
+
+	if generics.Depth != 2 || generics.Path != pathOf(tech, golang, generics) {
+		t.Errorf("Create() depth = %d, path = %q; want 2 and %q", generics.Depth, generics.Path, pathOf(tech, golang, generics))
+	}
+	if golang.SortOrder != 0 || rust.SortOrder != 1 {
+		t.Errorf("Create() sort orders = %d, %d; want 0 and 1", golang.SortOrder, rust.SortOrder)
+	}
+	missing := uint(999)
+	orphan := (&models.CreateCategoryRequest{Name: "Orphan"}).ToCategory()
+	orphan.ParentID = &missing
+	if err := repo.Create(orphan); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Create() under a missing parent error = %v, want gorm.ErrRecordNotFound", err)
+	}
+
+	t.Run("Queries", func(t *testing.T) {
+		ancestors, err := repo.GetAncestors(generics.ID)
+		if err != nil || !equal(names(ancestors), []string{"Technology", "Go"}) {
+			t.Errorf("GetAncestors() = %v, %v; want Technology, Go", names(ancestors), err)
+		}
+		descendants, err := repo.GetDescendants(tech.ID)
+		if err != nil || !equal(names(descendants), []string{"Go", "Rust", "Generics"}) {
+			t.Errorf("GetDescendants() = %v, %v; want Go, Rust, Generics", names(descendants), err)
+		}
+		roots, err := repo.GetChildren(nil)
+		if err != nil || !equal(names(roots), []string{"Technology", "Food"}) {
+			t.Errorf("GetChildren(nil) = %v, %v; want Technology, Food", names(roots), err)
+		}
+
+		tree, err := repo.GetTree(nil)
+		if err != nil || len(tree) != 2 {
+			t.Fatalf("GetTree() = %v, %v; want two roots", names(tree), err)
+		}
+		if !equal(names(tree[0].Children), []string{"Go", "Rust"}) || !equal(names(tree[0].Children[0].Children), []string{"Generics"}) {
+			t.Errorf("GetTree() Technology children = %v, want Go (Generics), Rust", tree[0].Children)
+		}
+	})
+
+	t.Run("Reorder", func(t *testing.T) {
+		if err := repo.Reorder(&tech.ID, []uint{rust.ID}); err != nil {
+			t.Fatalf("Reorder() failed: %v", err)
+		}
+		children, err := repo.GetChildren(&tech.ID)
+		if err != nil || !equal(names(children), []string{"Rust", "Go"}) {
+			t.Errorf("GetChildren() after Reorder() = %v, %v; want Rust, Go", names(children), err)
+		}
+		if err := repo.Reorder(&tech.ID, []uint{food.ID}); !errors.Is(err, ErrNotSibling) {
+			t.Errorf("Reorder() with a root error = %v, want ErrNotSibling", err)
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		for _, parent := range []*models.Category{tech, generics} {
+			if _, err := repo.Move(tech.ID, &parent.ID); !errors.Is(err, ErrCategoryCycle) {
+				t.Errorf("Move(Technology under %s) error = %v, want ErrCategoryCycle", parent.Name, err)
+			}
+		}
+
+		moved, err := repo.Move(golang.ID, &food.ID)
+		if err != nil {
+			t.Fatalf("Move() failed: %v", err)
+		}
+		if moved.Depth != 1 || moved.Path != pathOf(food, golang) || *moved.ParentID != food.ID {
+			t.Errorf("Move() = %+v, want Go under Food", moved)
+		}
+		child, err := repo.GetByID(generics.ID)
+		if err != nil || child.Depth != 2 || child.Path != pathOf(food, golang, generics) {
+			t.Errorf("GetByID(Generics) after Move() = %+v, %v; want it moved along", child, err)
+		}
+
+		moved, err = repo.Move(golang.ID, nil)
+		if err != nil || moved.Depth != 0 || moved.ParentID != nil || moved.SortOrder != 2 {
+			t.Errorf("Move(nil) = %+v, %v; want the last root", moved, err)
+		}
+		if _, err := repo.Move(golang.ID, &food.ID); err != nil {
+			t.Fatalf("Move() back failed: %v", err)
+		}
+
+		golang.ParentID = &tech.ID
+		if err := repo.Update(golang); !errors.Is(err, models.ErrCategoryParentChanged) {
+			t.Errorf("Update() with a new parent error = %v, want ErrCategoryParentChanged", err)
+		}
+	})
+
+	t.Run("TooDeep", func(t *testing.T) {
+		parent := food
+		for depth := 1; depth < models.MaxCategoryDepth; depth++ {
+			parent = create("Level "+string(rune('A'+depth)), parent)
+		}
+		deep := (&models.CreateCategoryRequest{Name: "Too deep"}).ToCategory()
+		deep.ParentID = &parent.ID
+		if err := repo.Create(deep); !errors.Is(err, models.ErrCategoryTooDeep) {
+			t.Errorf("Create() below MaxCategoryDepth error = %v, want ErrCategoryTooDeep", err)
+		}
+		if _, err := repo.Move(food.ID, &rust.ID); !errors.Is(err, models.ErrCategoryTooDeep) {
+			t.Errorf("Move() of a deep subtree error = %v, want ErrCategoryTooDeep", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := repo.Delete(tech.ID); !errors.Is(err, ErrCategoryHasChildren) {
+			t.Errorf("Delete() of a parent error = %v, want ErrCategoryHasChildren", err)
+		}
+		if err := repo.Delete(rust.ID); err != nil {
+			t.Fatalf("Delete(Rust) failed: %v", err)
+		}
+		if err := repo.Delete(tech.ID); err != nil {
+			t.Fatalf("Delete(Technology) failed: %v", err)
+		}
+		if _, err := repo.Restore(rust.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("Restore() under a deleted parent error = %v, want gorm.ErrRecordNotFound", err)
+		}
+	})
+
+	t.Run("PostCount", func(t *testing.T) {
+		posts := NewPostRepository(db.Conn)
+		user, err := userRepo.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		for _, categories := range [][]uint{{golang.ID, generics.ID}, {generics.ID}, {food.ID}} {
+			post, err := posts.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Categorised post"})
+			if err != nil {
+				t.Fatalf("Failed to create post: %v", err)
+			}
+			ids := make([]int, len(categories))
+			for i, id := range categories {
+				ids[i] = int(id)
+			}
+			if err := posts.SetCategories(post.ID, ids); err != nil {
+				t.Fatalf("SetCategories() failed: %v", err)
+			}
+		}
+
+		for _, tt := range []struct {
+			category *models.Category
+			want     int64
+		}{{food, 3}, {golang, 2}, {generics, 2}} {
+			if count, err := tt.category.PostCount(db.Gorm); err != nil || count != tt.want {
+				t.Errorf("%s.PostCount() = %d, %v; want %d", tt.category.Name, count, err, tt.want)
+			}
+		}
+	})
+}
+
+// pathOf returns the materialized path of the last category in a chain
+func pathOf(chain ...*models.Category) string {
+	path := "/"
+	for _, category := range chain {
+		path += fmt.Sprint(category.ID) + "/"
+	}
+	return path
+}
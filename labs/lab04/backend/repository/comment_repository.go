@@ -0,0 +1,252 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+)
+
+// ErrInvalidParent is returned when a reply names a parent comment that does
+// not exist, is deleted or belongs to another post
+var ErrInvalidParent = errors.New("invalid parent comment")
+
+// CommentRepository handles database operations for comments on posts
+type CommentRepository struct {
+	db      database.DBTX
+	dialect database.Dialect
+}
+
+// NewCommentRepository creates a new CommentRepository
+func NewCommentRepository(db database.DBTX) *CommentRepository {
+	return &CommentRepository{db: db, dialect: database.DialectOf(db)}
+}
+
+const commentColumns = "id, post_id, user_id, parent_id, content, status, created_at, updated_at, deleted_at"
+
+// Create is CreateContext with context.Background()
+func (r *CommentRepository) Create(req *models.CreateCommentRequest) (*models.Comment, error) {
+	return r.CreateContext(context.Background(), req)
+}
+
+// CreateContext inserts a new comment and returns it with its ID and
+// timestamps. It returns sql.ErrNoRows if the post does not exist or is
+// deleted, and ErrInvalidParent if a reply's parent cannot be answered.
+func (r *CommentRepository) CreateContext(ctx context.Context, req *models.CreateCommentRequest) (*models.Comment, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	comment := req.ToComment()
+	var created models.Comment
+	err := withTx(ctx, r.db, func(tx database.DBTX) error {
+		if err := r.checkTarget(ctx, tx, comment.PostID, comment.ParentID); err != nil {
+			return err
+		}
+		query := r.dialect.Rebind(`
+			INSERT INTO comments (post_id, user_id, parent_id, content, status, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			RETURNING ` + commentColumns)
+		return r.get(ctx, tx, "CommentRepository.Create", &created, query,
+			comment.PostID, comment.UserID, comment.ParentID, comment.Content, comment.Status,
+			comment.CreatedAt, comment.UpdatedAt)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, ErrInvalidParent) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	return &created, nil
+}
+
+// GetByID is GetByIDContext with context.Background()
+func (r *CommentRepository) GetByID(id int) (*models.Comment, error) {
+	return r.GetByIDContext(context.Background(), id)
+}
+
+// GetByIDContext returns the comment with the given ID or sql.ErrNoRows.
+// Soft deleted comments are not found.
+func (r *CommentRepository) GetByIDContext(ctx context.Context, id int) (*models.Comment, error) {
+	query := r.dialect.Rebind(`SELECT ` + commentColumns + ` FROM comments WHERE id = ? AND deleted_at IS NULL`)
+
+	var comment models.Comment
+	if err := r.get(ctx, r.db, "CommentRepository.GetByID", &comment, query, id); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// ListByPost is ListByPostContext with context.Background()
+func (r *CommentRepository) ListByPost(postID int, opts ...ListOption) ([]*models.Comment, error) {
+	return r.ListByPostContext(context.Background(), postID, opts...)
+}
+
+// ListByPostContext returns the approved comments of a post as threads,
+// oldest first. Replies to comments that are not listed are left out.
+// IncludeUnapproved adds pending and rejected comments. Deleted comments
+// only appear, without content, where they still have replies, unless
+// IncludeDeleted is given.
+func (r *CommentRepository) ListByPostContext(ctx context.Context, postID int, opts ...ListOption) ([]*models.Comment, error) {
+	o := applyListOptions(opts)
+
+	where, args := "post_id = ?", []interface{}{postID}
+	if !o.includeUnapproved {
+		where += " AND status = ?"
+		args = append(args, models.CommentApproved)
+	}
+	query := r.dialect.Rebind(`
+		SELECT ` + commentColumns + ` FROM comments
+		WHERE ` + where + `
+		ORDER BY created_at, id`)
+
+	comments, err := r.selectComments(ctx, "CommentRepository.ListByPost", query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	thread := models.BuildCommentThread(comments)
+	if !o.includeDeleted {
+		thread = models.PruneDeletedComments(thread)
+	}
+	return thread, nil
+}
+
+// ListByStatus is ListByStatusContext with context.Background()
+func (r *CommentRepository) ListByStatus(status models.CommentStatus, limit int) ([]models.Comment, error) {
+	return r.ListByStatusContext(context.Background(), status, limit)
+}
+
+// ListByStatusContext returns comments awaiting or past moderation, oldest
+// first, as a flat list for moderation queues. Deleted comments are left out.
+func (r *CommentRepository) ListByStatusContext(ctx context.Context, status models.CommentStatus, limit int) ([]models.Comment, error) {
+	if !status.Valid() {
+		return nil, fmt.Errorf("unknown comment status %q", status)
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	query := r.dialect.Rebind(`
+		SELECT ` + commentColumns + ` FROM comments
+		WHERE status = ? AND deleted_at IS NULL
+		ORDER BY created_at, id
+		LIMIT ?`)
+
+	return r.selectComments(ctx, "CommentRepository.ListByStatus", query, status, limit)
+}
+
+// Moderate is ModerateContext with context.Background()
+func (r *CommentRepository) Moderate(id int, status models.CommentStatus) (*models.Comment, error) {
+	return r.ModerateContext(context.Background(), id, status)
+}
+
+// ModerateContext sets the moderation status of a comment and returns it, or
+// sql.ErrNoRows if the comment does not exist or is deleted
+func (r *CommentRepository) ModerateContext(ctx context.Context, id int, status models.CommentStatus) (*models.Comment, error) {
+	if !status.Valid() {
+		return nil, fmt.Errorf("unknown comment status %q", status)
+	}
+
+	query := r.dialect.Rebind(`
+		UPDATE comments SET status = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL
+		RETURNING ` + commentColumns)
+
+	var comment models.Comment
+	if err := r.get(ctx, r.db, "CommentRepository.Moderate", &comment, query, status, time.Now().UTC(), id); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// Delete is DeleteContext with context.Background()
+func (r *CommentRepository) Delete(id int) error {
+	return r.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext soft deletes the comment with the given ID or returns
+// sql.ErrNoRows. Its replies are kept.
+func (r *CommentRepository) DeleteContext(ctx context.Context, id int) error {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	now := time.Now().UTC()
+	result, err := r.db.ExecContext(ctx, r.dialect.Rebind(`
+		UPDATE comments SET deleted_at = ?, updated_at = ?
+		WHERE id = ? AND deleted_at IS NULL`), now, now, id)
+	if err != nil {
+		return mapQueryError(ctx, "CommentRepository.Delete", err)
+	}
+	return expectAffected(result)
+}
+
+// CountByPost is CountByPostContext with context.Background()
+func (r *CommentRepository) CountByPost(postID int) (int, error) {
+	return r.CountByPostContext(context.Background(), postID)
+}
+
+// CountByPostContext returns the number of approved comments of a post that
+// are not deleted
+func (r *CommentRepository) CountByPostContext(ctx context.Context, postID int) (int, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, r.dialect.Rebind(`
+		SELECT COUNT(*) FROM comments
+		WHERE post_id = ? AND status = ? AND deleted_at IS NULL`), postID, models.CommentApproved).Scan(&count)
+	return count, mapQueryError(ctx, "CommentRepository.CountByPost", err)
+}
+
+// checkTarget returns sql.ErrNoRows unless the post is live, and
+// ErrInvalidParent unless parentID is nil or a live comment on the same post
+func (r *CommentRepository) checkTarget(ctx context.Context, db database.DBTX, postID int, parentID *int) error {
+	ctx, cancel := queryContext(ctx, db)
+	defer cancel()
+
+	var postExists, parentValid bool
+	err := db.QueryRowContext(ctx, r.dialect.Rebind(`
+		SELECT
+			EXISTS (SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL),
+			EXISTS (SELECT 1 FROM comments WHERE id = ? AND post_id = ? AND deleted_at IS NULL)`),
+		postID, parentID, postID).Scan(&postExists, &parentValid)
+	if err != nil {
+		return mapQueryError(ctx, "CommentRepository.Create", err)
+	}
+	if !postExists {
+		return sql.ErrNoRows
+	}
+	if parentID != nil && !parentValid {
+		return ErrInvalidParent
+	}
+	return nil
+}
+
+// get scans a single comment returned by query with scany
+func (r *CommentRepository) get(ctx context.Context, db database.DBTX, op string, comment *models.Comment, query string, args ...interface{}) error {
+	ctx, cancel := queryContext(ctx, db)
+	defer cancel()
+
+	err := sqlscan.Get(ctx, db, comment, query, args...)
+	if sqlscan.NotFound(err) {
+		return sql.ErrNoRows
+	}
+	return mapQueryError(ctx, op, err)
+}
+
+// selectComments scans all comments returned by query with scany
+func (r *CommentRepository) selectComments(ctx context.Context, op, query string, args ...interface{}) ([]models.Comment, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	comments := []models.Comment{}
+	err := sqlscan.Select(ctx, r.db, &comments, query, args...)
+	return comments, mapQueryError(ctx, op, err)
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+)
+
+func TestCommentRepository(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	postRepo := NewPostRepository(db.Conn)
+	repo := NewCommentRepository(db.Conn)
+
+	user, err := userRepo.Create(&models.CreateUserRequest{Name: "Commenter", Email: "commenter@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	post, err := postRepo.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Commented post", Content: "Body", Published: true})
+	if err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+	other, err := postRepo.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Another post"})
+	if err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+
+	create := func(parentID *int, content string) *models.Comment {
+		t.Helper()
+		comment, err := repo.Create(&models.CreateCommentRequest{PostID: post.ID, UserID: user.ID, ParentID: parentID, Content: content})
+		if err != nil {
+			t.Fatalf("Create(%q) failed: %v", content, err)
+		}
+		return comment
+	}
+
+	root := create(nil, "First")
+	reply := create(&root.ID, "Reply")
+	create(&reply.ID, "Nested reply")
+	spam := create(nil, "Spam")
+
+	t.Run("Threads", func(t *testing.T) {
+		thread, err := repo.ListByPost(post.ID)
+		if err != nil {
+			t.Fatalf("ListByPost() failed: %v", err)
+		}
+		if len(thread) != 2 || thread[0].ID != root.ID {
+			t.Fatalf("ListByPost() returned %d threads, want 2 starting with the first comment", len(thread))
+		}
+		if len(thread[0].Replies) != 1 || len(thread[0].Replies[0].Replies) != 1 {
+			t.Errorf("ListByPost() did not nest the replies: %+v", thread[0])
+		}
+	})
+
+	t.Run("InvalidTarget", func(t *testing.T) {
+		_, err := repo.Create(&models.CreateCommentRequest{PostID: other.ID, UserID: user.ID, ParentID: &root.ID, Content: "Wrong post"})
+		if !errors.Is(err, ErrInvalidParent) {
+			t.Errorf("Create() with a parent on another post error = %v, want ErrInvalidParent", err)
+		}
+		_, err = repo.Create(&models.CreateCommentRequest{PostID: 9999, UserID: user.ID, Content: "No post"})
+		if !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Create() on a missing post error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("Moderate", func(t *testing.T) {
+		moderated, err := repo.Moderate(spam.ID, models.CommentRejected)
+		if err != nil {
+			t.Fatalf("Moderate() failed: %v", err)
+		}
+		if moderated.Status != models.CommentRejected {
+			t.Errorf("Moderate() status = %q, want rejected", moderated.Status)
+		}
+		if _, err := repo.Moderate(spam.ID, "hidden"); err == nil {
+			t.Error("Moderate() should reject an unknown status")
+		}
+
+		thread, _ := repo.ListByPost(post.ID)
+		if len(thread) != 1 {
+			t.Errorf("ListByPost() returned %d threads, want the rejected comment left out", len(thread))
+		}
+		thread, _ = repo.ListByPost(post.ID, IncludeUnapproved())
+		if len(thread) != 2 {
+			t.Errorf("ListByPost(IncludeUnapproved()) returned %d threads, want 2", len(thread))
+		}
+		rejected, _ := repo.ListByStatus(models.CommentRejected, 0)
+		if len(rejected) != 1 || rejected[0].ID != spam.ID {
+			t.Errorf("ListByStatus(rejected) = %v, want the rejected comment", rejected)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := repo.Delete(root.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		if err := repo.Delete(root.ID); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("Delete() of a deleted comment error = %v, want sql.ErrNoRows", err)
+		}
+
+		thread, err := repo.ListByPost(post.ID)
+		if err != nil {
+			t.Fatalf("ListByPost() failed: %v", err)
+		}
+		if len(thread) != 1 || !thread[0].IsDeleted() || thread[0].Content != "" || len(thread[0].Replies) != 1 {
+			t.Errorf("ListByPost() should keep the deleted comment as an empty placeholder for its replies: %+v", thread)
+		}
+		if count, _ := repo.CountByPost(post.ID); count != 2 {
+			t.Errorf("CountByPost() = %d, want 2", count)
+		}
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		search := NewSearchService(db.Conn)
+		stats, err := search.GetPostStats(t.Context())
+		if err != nil {
+			t.Fatalf("GetPostStats() failed: %v", err)
+		}
+		if stats.TotalComments != 2 {
+			t.Errorf("GetPostStats() TotalComments = %d, want 2", stats.TotalComments)
+		}
+
+		users, err := search.GetTopUsers(t.Context(), 10)
+		if err != nil {
+			t.Fatalf("GetTopUsers() failed: %v", err)
+		}
+		if len(users) != 1 || users[0].CommentCount != 2 || users[0].PostCount != 2 {
+			t.Errorf("GetTopUsers() = %+v, want one user with 2 posts and 2 comments", users)
+		}
+	})
+}
@@ -0,0 +1,57 @@
+// Package repository implements the lab's data access layer three ways:
+// hand-written SQL, scany with squirrel, and GORM.
+//
+// Every method that touches the database comes in two forms. XContext takes
+// the caller's context; X runs XContext with context.Background(). Either
+// way each query is bounded by the per-query timeout of the connection, and
+// a query that runs past its deadline fails with a *QueryTimeoutError.
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lab04-backend/database"
+)
+
+// QueryTimeoutError is returned when a repository query runs past its
+// deadline, either the per-query timeout or one set by the caller's context
+type QueryTimeoutError struct {
+	Op  string // Repository method, e.g. "UserRepository.GetByID"
+	Err error
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("%s: query timed out: %v", e.Op, e.Err)
+}
+
+// Unwrap makes errors.Is(err, context.DeadlineExceeded) hold, and keeps the
+// driver's error reachable
+func (e *QueryTimeoutError) Unwrap() []error {
+	return []error{context.DeadlineExceeded, e.Err}
+}
+
+// IsQueryTimeout reports whether err is a *QueryTimeoutError
+func IsQueryTimeout(err error) bool {
+	var timeoutErr *QueryTimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// queryContext bounds ctx by the per-query timeout of db
+func queryContext(ctx context.Context, db database.DBTX) (context.Context, context.CancelFunc) {
+	return database.WithQueryTimeout(ctx, db)
+}
+
+// mapQueryError turns errors caused by an expired deadline into a
+// *QueryTimeoutError. Drivers report them differently, so ctx is checked too.
+// Errors that already are a *QueryTimeoutError are returned unchanged.
+func mapQueryError(ctx context.Context, op string, err error) error {
+	if err == nil || IsQueryTimeout(err) {
+		return err
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &QueryTimeoutError{Op: op, Err: err}
+	}
+	return err
+}
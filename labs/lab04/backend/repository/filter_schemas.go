@@ -0,0 +1,41 @@
+package repository
+
+import "lab04-backend/filter"
+
+// PostSchema lists the post fields that filters and sort orders may use,
+// e.g. SearchFilters.Filters or ?filter=status:eq:published&sort=-created_at
+var PostSchema = filter.NewSchema("id",
+	filter.Field{Name: "id", Kind: filter.Int, Sortable: true},
+	filter.Field{Name: "user_id", Kind: filter.Int},
+	filter.Field{Name: "title", Kind: filter.String, Sortable: true},
+	filter.Field{Name: "content", Kind: filter.String},
+	filter.Field{Name: "slug", Kind: filter.String},
+	filter.Field{Name: "published", Kind: filter.Bool},
+	filter.Field{Name: "status", Kind: filter.String},
+	filter.Field{Name: "publish_at", Kind: filter.Time, Nullable: true, Sortable: true},
+	filter.Field{Name: "created_at", Kind: filter.Time, Sortable: true},
+	filter.Field{Name: "updated_at", Kind: filter.Time, Sortable: true},
+)
+
+// UserSchema lists the user fields that filters and sort orders may use
+var UserSchema = filter.NewSchema("id",
+	filter.Field{Name: "id", Kind: filter.Int, Sortable: true},
+	filter.Field{Name: "name", Kind: filter.String, Sortable: true},
+	filter.Field{Name: "email", Kind: filter.String, Sortable: true},
+	filter.Field{Name: "created_at", Kind: filter.Time, Sortable: true},
+	filter.Field{Name: "updated_at", Kind: filter.Time, Sortable: true},
+)
+
+// CategorySchema lists the category fields that filters and sort orders may use
+var CategorySchema = filter.NewSchema("id",
+	filter.Field{Name: "id", Kind: filter.Int, Sortable: true},
+	filter.Field{Name: "name", Kind: filter.String, Sortable: true},
+	filter.Field{Name: "description", Kind: filter.String},
+	filter.Field{Name: "color", Kind: filter.String},
+	filter.Field{Name: "active", Kind: filter.Bool},
+	filter.Field{Name: "parent_id", Kind: filter.Int, Nullable: true},
+	filter.Field{Name: "depth", Kind: filter.Int, Sortable: true},
+	filter.Field{Name: "sort_order", Kind: filter.Int, Sortable: true},
+	filter.Field{Name: "created_at", Kind: filter.Time, Sortable: true},
+	filter.Field{Name: "updated_at", Kind: filter.Time, Sortable: true},
+)
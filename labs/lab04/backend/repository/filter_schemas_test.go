@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/filter"
+	"lab04-backend/models"
+)
+
+func TestFilterSchemas(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	posts := NewPostRepository(db.Conn)
+	search := NewSearchService(db.Conn)
+
+	var users []*models.User
+	for _, req := range []models.CreateUserRequest{
+		{Name: "Alice", Email: "alice@example.com"},
+		{Name: "Bob", Email: "bob@example.org"},
+	} {
+		user, err := userRepo.Create(&req)
+		if err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+		users = append(users, user)
+	}
+	for _, req := range []models.CreatePostRequest{
+		{UserID: users[0].ID, Title: "Draft about Go"},
+		{UserID: users[0].ID, Title: "Published about Go", Content: "Content", Published: true},
+		{UserID: users[1].ID, Title: "Published about Rust", Content: "Content", Published: true},
+	} {
+		if _, err := posts.Create(&req); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+	}
+
+	t.Run("Posts", func(t *testing.T) {
+		values, _ := url.ParseQuery("filter=published:eq:true&filter=title:like:GO&sort=-title")
+		q, err := PostSchema.Parse(values)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		found, err := search.SearchPosts(t.Context(), SearchFilters{Filters: q.Filters, Sort: q.Sort})
+		if err != nil || len(found) != 1 || found[0].Title != "Published about Go" {
+			t.Fatalf("SearchPosts(filters) = %v, %v; want the published Go post", found, err)
+		}
+
+		found, err = search.SearchPosts(t.Context(), SearchFilters{
+			Filters: []filter.Condition{{Field: "user_id", Op: filter.Eq, Value: users[0].ID}},
+			Sort:    []filter.Sort{{Field: "title"}},
+		})
+		if err != nil || len(found) != 2 || found[0].Title != "Draft about Go" {
+			t.Errorf("SearchPosts(user_id, title) = %v, %v; want Alice's posts by title", found, err)
+		}
+
+		_, err = search.SearchPosts(t.Context(), SearchFilters{
+			Filters: []filter.Condition{{Field: "deleted_at", Op: filter.Null, Value: false}},
+		})
+		if !errors.Is(err, filter.ErrInvalid) {
+			t.Errorf("SearchPosts(unknown field) error = %v, want filter.ErrInvalid", err)
+		}
+		if _, err := search.SearchPostsPage(t.Context(), SearchFilters{Sort: []filter.Sort{{Field: "title"}}}); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("SearchPostsPage(sort by title) error = %v, want ErrInvalidCursor", err)
+		}
+	})
+
+	t.Run("Users", func(t *testing.T) {
+		found, err := search.FilterUsers(t.Context(), filter.Query{
+			Filters: []filter.Condition{{Field: "email", Op: filter.Like, Value: "example.org"}},
+		}, 10)
+		if err != nil || len(found) != 1 || found[0].Name != "Bob" {
+			t.Errorf("FilterUsers(email) = %v, %v; want Bob", found, err)
+		}
+		found, err = search.FilterUsers(t.Context(), filter.Query{Sort: []filter.Sort{{Field: "name", Desc: true}}}, 10)
+		if err != nil || len(found) != 2 || found[0].Name != "Bob" {
+			t.Errorf("FilterUsers(-name) = %v, %v; want Bob first", found, err)
+		}
+	})
+
+	t.Run("Categories", func(t *testing.T) {
+		categories := NewCategoryRepository(db.Gorm)
+		parent := &models.Category{Name: "Languages", Active: true}
+		if err := categories.Create(parent); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		for _, name := range []string{"Go", "Rust"} {
+			if err := categories.Create(&models.Category{Name: name, Active: true, ParentID: &parent.ID}); err != nil {
+				t.Fatalf("Create() failed: %v", err)
+			}
+		}
+
+		values, _ := url.ParseQuery("filter=parent_id:null:false&sort=-name")
+		q, err := CategorySchema.Parse(values)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		found, err := categories.Filter(q, 10)
+		if err != nil || len(found) != 2 || found[0].Name != "Rust" {
+			t.Errorf("Filter(children, -name) = %v, %v; want Rust, Go", found, err)
+		}
+		if _, err := categories.Filter(filter.Query{Sort: []filter.Sort{{Field: "color"}}}, 10); !errors.Is(err, filter.ErrInvalid) {
+			t.Errorf("Filter(sort by color) error = %v, want filter.ErrInvalid", err)
+		}
+	})
+}
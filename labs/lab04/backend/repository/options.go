@@ -0,0 +1,41 @@
+package repository
+
+// ListOption modifies list and search queries
+type ListOption func(*listOptions)
+
+type listOptions struct {
+	includeDeleted    bool
+	includeUnapproved bool
+}
+
+// IncludeDeleted makes a list query return soft deleted rows as well
+func IncludeDeleted() ListOption {
+	return func(o *listOptions) {
+		o.includeDeleted = true
+	}
+}
+
+// IncludeUnapproved makes a comment list return pending and rejected
+// comments as well
+func IncludeUnapproved() ListOption {
+	return func(o *listOptions) {
+		o.includeUnapproved = true
+	}
+}
+
+func applyListOptions(opts []ListOption) listOptions {
+	var o listOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// notDeleted returns the condition excluding soft deleted rows of column,
+// or "1 = 1" when the options ask for deleted rows too
+func (o listOptions) notDeleted(column string) string {
+	if o.includeDeleted {
+		return "1 = 1"
+	}
+	return column + " IS NULL"
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a page cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+const defaultPageLimit = 50
+
+// Cursor is the keyset position of a row: its creation time and ID
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Encode returns the opaque string form of the cursor
+func (c Cursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + strconv.Itoa(c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Cursor.Encode
+func DecodeCursor(s string) (Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if c.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if c.ID, err = strconv.Atoi(id); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// PageRequest asks for one page of a keyset paginated list. At most one of
+// After and Before may be set; with neither, the first page is returned.
+type PageRequest struct {
+	Limit  int    // Page size (default 50)
+	After  string // NextCursor of the previous page
+	Before string // PrevCursor of the following page
+}
+
+// Page is one page of a keyset paginated list. NextCursor and PrevCursor are
+// empty when there is no page in that direction.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// keyset is the SQL needed to fetch a page ordered by (created_at, id)
+type keyset struct {
+	limit    int
+	where    string        // empty for the first page
+	args     []interface{} // arguments of where
+	order    string        // ORDER BY clause in fetch order
+	backward bool          // rows are fetched in reverse and must be flipped
+	hasStart bool          // the page starts after a cursor
+}
+
+// keyset builds the condition and ordering for the page. column prefixes
+// created_at and id, e.g. "p." for a joined query.
+func (p PageRequest) keyset(descending bool, prefix string) (keyset, error) {
+	if p.After != "" && p.Before != "" {
+		return keyset{}, fmt.Errorf("%w: only one of after and before may be set", ErrInvalidCursor)
+	}
+
+	k := keyset{limit: p.Limit}
+	if k.limit <= 0 {
+		k.limit = defaultPageLimit
+	}
+
+	cursor := p.After
+	if p.Before != "" {
+		cursor = p.Before
+		k.backward = true
+		descending = !descending
+	}
+
+	cmp, dir := ">", "ASC"
+	if descending {
+		cmp, dir = "<", "DESC"
+	}
+	createdAt, id := prefix+"created_at", prefix+"id"
+	k.order = createdAt + " " + dir + ", " + id + " " + dir
+
+	if cursor != "" {
+		c, err := DecodeCursor(cursor)
+		if err != nil {
+			return keyset{}, err
+		}
+		k.where = fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", createdAt, cmp, createdAt, id, cmp)
+		k.args = []interface{}{c.CreatedAt.UTC(), c.CreatedAt.UTC(), c.ID}
+		k.hasStart = true
+	}
+	return k, nil
+}
+
+// newPage trims rows fetched with limit+1 to a page and sets its cursors
+func newPage[T any](rows []T, k keyset, cursorOf func(T) Cursor) *Page[T] {
+	hasMore := len(rows) > k.limit
+	if hasMore {
+		rows = rows[:k.limit]
+	}
+	if k.backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	page := &Page[T]{Items: rows}
+	if len(rows) == 0 {
+		return page
+	}
+
+	// Going forward, more rows mean a next page and a start cursor means a
+	// previous one; going backward it is the other way round
+	hasNext, hasPrev := hasMore, k.hasStart
+	if k.backward {
+		hasNext, hasPrev = k.hasStart, hasMore
+	}
+	if hasNext {
+		page.NextCursor = cursorOf(rows[len(rows)-1]).Encode()
+	}
+	if hasPrev {
+		page.PrevCursor = cursorOf(rows[0]).Encode()
+	}
+	return page
+}
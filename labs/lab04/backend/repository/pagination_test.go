@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+)
+
+func TestCursorEncoding(t *testing.T) {
+	c := Cursor{CreatedAt: time.Date(2025, 7, 8, 9, 0, 0, 123456789, time.UTC), ID: 42}
+
+	decoded, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatalf("DecodeCursor() error = %v", err)
+	}
+	if !decoded.CreatedAt.Equal(c.CreatedAt) || decoded.ID != c.ID {
+		t.Errorf("DecodeCursor() = %+v, want %+v", decoded, c)
+	}
+
+	for _, invalid := range []string{"not base64!", "bm8tc2VwYXJhdG9y", "eHx5"} {
+		if _, err := DecodeCursor(invalid); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", invalid, err)
+		}
+	}
+}
+
+func postIDs(posts []models.Post) []int {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	return ids
+}
+
+func TestPostRepository_GetPage(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	repo := NewPostRepository(db.Conn)
+
+	user, err := userRepo.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err := repo.Create(&models.CreatePostRequest{UserID: user.ID, Title: fmt.Sprintf("Post number %d", i)}); err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+	}
+	// Posts 2 and 3 share a timestamp so the id tiebreaker is exercised
+	if _, err := db.Conn.Exec(`UPDATE posts SET created_at = (SELECT created_at FROM posts WHERE id = 2) WHERE id = 3`); err != nil {
+		t.Fatalf("Failed to update timestamps: %v", err)
+	}
+
+	first, err := repo.GetPage(PageRequest{Limit: 2})
+	if err != nil {
+		t.Fatalf("GetPage() failed: %v", err)
+	}
+	if got := fmt.Sprint(postIDs(first.Items)); got != "[5 4]" || first.NextCursor == "" || first.PrevCursor != "" {
+		t.Fatalf("first page = %s next=%q prev=%q", got, first.NextCursor, first.PrevCursor)
+	}
+
+	second, err := repo.GetPage(PageRequest{Limit: 2, After: first.NextCursor})
+	if err != nil {
+		t.Fatalf("GetPage(After) failed: %v", err)
+	}
+	if got := fmt.Sprint(postIDs(second.Items)); got != "[3 2]" || second.NextCursor == "" || second.PrevCursor == "" {
+		t.Fatalf("second page = %s next=%q prev=%q", got, second.NextCursor, second.PrevCursor)
+	}
+
+	last, err := repo.GetPage(PageRequest{Limit: 2, After: second.NextCursor})
+	if err != nil {
+		t.Fatalf("GetPage(After) failed: %v", err)
+	}
+	if got := fmt.Sprint(postIDs(last.Items)); got != "[1]" || last.NextCursor != "" || last.PrevCursor == "" {
+		t.Fatalf("last page = %s next=%q prev=%q", got, last.NextCursor, last.PrevCursor)
+	}
+
+	back, err := repo.GetPage(PageRequest{Limit: 2, Before: last.PrevCursor})
+	if err != nil {
+		t.Fatalf("GetPage(Before) failed: %v", err)
+	}
+	if got := fmt.Sprint(postIDs(back.Items)); got != "[3 2]" {
+		t.Errorf("page before last = %s, want [3 2]", got)
+	}
+
+	back, err = repo.GetPage(PageRequest{Limit: 2, Before: back.PrevCursor})
+	if err != nil {
+		t.Fatalf("GetPage(Before) failed: %v", err)
+	}
+	if got := fmt.Sprint(postIDs(back.Items)); got != "[5 4]" || back.PrevCursor != "" || back.NextCursor == "" {
+		t.Errorf("first page again = %s next=%q prev=%q", got, back.NextCursor, back.PrevCursor)
+	}
+
+	if _, err := repo.GetPage(PageRequest{After: "garbage"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("GetPage(garbage) error = %v, want ErrInvalidCursor", err)
+	}
+
+	search := NewSearchService(repo.db)
+	found, err := search.SearchPostsPage(t.Context(), SearchFilters{Query: "number", Limit: 3, OrderDir: "ASC"})
+	if err != nil {
+		t.Fatalf("SearchPostsPage() failed: %v", err)
+	}
+	if got := fmt.Sprint(postIDs(found.Items)); got != "[1 2 3]" || found.NextCursor == "" {
+		t.Fatalf("SearchPostsPage() = %s next=%q", got, found.NextCursor)
+	}
+	rest, err := search.SearchPosts(t.Context(), SearchFilters{Query: "number", OrderDir: "ASC", After: found.NextCursor})
+	if err != nil {
+		t.Fatalf("SearchPosts(After) failed: %v", err)
+	}
+	if got := fmt.Sprint(postIDs(rest)); got != "[4 5]" {
+		t.Errorf("SearchPosts(After) = %s, want [4 5]", got)
+	}
+	if _, err := search.SearchPostsPage(t.Context(), SearchFilters{OrderBy: "title"}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("SearchPostsPage(OrderBy title) error = %v, want ErrInvalidCursor", err)
+	}
+}
+
+func TestUserRepository_GetPage(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
+
+	for i := 1; i <= 3; i++ {
+		req := &models.CreateUserRequest{Name: fmt.Sprintf("User %d", i), Email: fmt.Sprintf("user%d@example.com", i)}
+		if _, err := repo.Create(req); err != nil {
+			t.Fatalf("Failed to create user: %v", err)
+		}
+	}
+
+	var names []string
+	page := PageRequest{Limit: 2}
+	for {
+		result, err := repo.GetPage(page)
+		if err != nil {
+			t.Fatalf("GetPage() failed: %v", err)
+		}
+		for _, u := range result.Items {
+			names = append(names, u.Name)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.After = result.NextCursor
+	}
+
+	if got := fmt.Sprint(names); got != "[User 1 User 2 User 3]" {
+		t.Errorf("paged users = %s, want oldest first", got)
+	}
+}
@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"lab04-backend/database"
+	"lab04-backend/models"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
+)
+
+// CategoryMatch selects how posts are matched against several categories
+type CategoryMatch int
+
+const (
+	// MatchAnyCategory matches posts in at least one of the categories
+	MatchAnyCategory CategoryMatch = iota
+	// MatchAllCategories matches posts in every one of the categories
+	MatchAllCategories
+)
+
+// categoryFilter returns a condition on posts.id selecting posts in the
+// given categories. Placeholders are always ?, so the result can be nested
+// in any squirrel builder or rebound for manual SQL.
+func categoryFilter(categoryIDs []int, match CategoryMatch) squirrel.Sqlizer {
+	ids := uniqueIDs(categoryIDs)
+	sub := squirrel.Select("post_id").
+		From("post_categories").
+		Where(squirrel.Eq{"category_id": ids})
+	if match == MatchAllCategories {
+		sub = sub.GroupBy("post_id").Having("COUNT(DISTINCT category_id) = ?", len(ids))
+	}
+	return squirrel.Expr("id IN (?)", sub)
+}
+
+func uniqueIDs(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	unique := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// withTx runs fn in a transaction, or in a savepoint when db already is one.
+// It fails for connections that can do neither; see database.InTx.
+func withTx(ctx context.Context, db database.DBTX, fn func(tx database.DBTX) error) error {
+	return database.InTx(ctx, db, func(tx *database.Tx) error {
+		return fn(tx)
+	})
+}
+
+// SetCategories is SetCategoriesContext with context.Background()
+func (r *PostRepository) SetCategories(postID int, categoryIDs []int) error {
+	return r.SetCategoriesContext(context.Background(), postID, categoryIDs)
+}
+
+// SetCategoriesContext replaces the categories of a post. It returns
+// sql.ErrNoRows if the post does not exist or is deleted.
+func (r *PostRepository) SetCategoriesContext(ctx context.Context, postID int, categoryIDs []int) error {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	err := withTx(ctx, r.db, func(tx database.DBTX) error {
+		if err := r.expectLivePost(ctx, tx, postID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, r.dialect.Rebind(`DELETE FROM post_categories WHERE post_id = ?`), postID)
+		if err != nil {
+			return err
+		}
+		return r.insertCategories(ctx, tx, postID, categoryIDs)
+	})
+	return mapQueryError(ctx, "PostRepository.SetCategories", err)
+}
+
+// AddCategories is AddCategoriesContext with context.Background()
+func (r *PostRepository) AddCategories(postID int, categoryIDs ...int) error {
+	return r.AddCategoriesContext(context.Background(), postID, categoryIDs...)
+}
+
+// AddCategoriesContext adds categories to a post; categories it already has
+// are ignored. It returns sql.ErrNoRows if the post does not exist or is deleted.
+func (r *PostRepository) AddCategoriesContext(ctx context.Context, postID int, categoryIDs ...int) error {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	err := withTx(ctx, r.db, func(tx database.DBTX) error {
+		if err := r.expectLivePost(ctx, tx, postID); err != nil {
+			return err
+		}
+		return r.insertCategories(ctx, tx, postID, categoryIDs)
+	})
+	return mapQueryError(ctx, "PostRepository.AddCategories", err)
+}
+
+// RemoveCategories is RemoveCategoriesContext with context.Background()
+func (r *PostRepository) RemoveCategories(postID int, categoryIDs ...int) error {
+	return r.RemoveCategoriesContext(context.Background(), postID, categoryIDs...)
+}
+
+// RemoveCategoriesContext removes categories from a post. Categories the post
+// does not have are ignored.
+func (r *PostRepository) RemoveCategoriesContext(ctx context.Context, postID int, categoryIDs ...int) error {
+	if len(categoryIDs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	query, args, err := squirrel.Delete("post_categories").
+		Where(squirrel.Eq{"post_id": postID, "category_id": uniqueIDs(categoryIDs)}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, r.dialect.Rebind(query), args...)
+	return mapQueryError(ctx, "PostRepository.RemoveCategories", err)
+}
+
+// GetCategories is GetCategoriesContext with context.Background()
+func (r *PostRepository) GetCategories(postID int) ([]models.Category, error) {
+	return r.GetCategoriesContext(context.Background(), postID)
+}
+
+// GetCategoriesContext returns the categories of a post ordered by name.
+// Deleted categories are left out.
+func (r *PostRepository) GetCategoriesContext(ctx context.Context, postID int) ([]models.Category, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	query := r.dialect.Rebind(`
+		SELECT c.id, c.name, COALESCE(c.description, '') AS description, COALESCE(c.color, '') AS color,
+			c.active, c.created_at, c.updated_at
+		FROM categories c
+		JOIN post_categories pc ON pc.category_id = c.id
+		WHERE pc.post_id = ? AND c.deleted_at IS NULL
+		ORDER BY c.name`)
+
+	categories := []models.Category{}
+	err := sqlscan.Select(ctx, r.db, &categories, query, postID)
+	return categories, mapQueryError(ctx, "PostRepository.GetCategories", err)
+}
+
+// GetByCategories is GetByCategoriesContext with context.Background()
+func (r *PostRepository) GetByCategories(categoryIDs []int, match CategoryMatch, opts ...ListOption) ([]models.Post, error) {
+	return r.GetByCategoriesContext(context.Background(), categoryIDs, match, opts...)
+}
+
+// GetByCategoriesContext returns posts in any or all of the given categories,
+// newest first
+func (r *PostRepository) GetByCategoriesContext(ctx context.Context, categoryIDs []int, match CategoryMatch, opts ...ListOption) ([]models.Post, error) {
+	if len(categoryIDs) == 0 {
+		return []models.Post{}, nil
+	}
+
+	condition, args, err := categoryFilter(categoryIDs, match).ToSql()
+	if err != nil {
+		return nil, err
+	}
+	query := r.dialect.Rebind(`
+		SELECT ` + postColumns + ` FROM posts
+		WHERE ` + condition + ` AND ` + applyListOptions(opts).notDeleted("deleted_at") + `
+		ORDER BY created_at DESC, id DESC`)
+
+	return r.selectPosts(ctx, "PostRepository.GetByCategories", query, args...)
+}
+
+// expectLivePost returns sql.ErrNoRows unless the post exists and is not deleted
+func (r *PostRepository) expectLivePost(ctx context.Context, db database.DBTX, postID int) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, r.dialect.Rebind(`
+		SELECT EXISTS (SELECT 1 FROM posts WHERE id = ? AND deleted_at IS NULL)`), postID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// insertCategories links categories to a post, skipping existing links
+func (r *PostRepository) insertCategories(ctx context.Context, db database.DBTX, postID int, categoryIDs []int) error {
+	ids := uniqueIDs(categoryIDs)
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	values := make([]string, len(ids))
+	args := make([]interface{}, 0, 3*len(ids))
+	for i, id := range ids {
+		values[i] = "(?, ?, ?)"
+		args = append(args, postID, id, now)
+	}
+
+	_, err := db.ExecContext(ctx, r.dialect.Rebind(`
+		INSERT INTO post_categories (post_id, category_id, created_at)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (post_id, category_id) DO NOTHING`), args...)
+	return err
+}
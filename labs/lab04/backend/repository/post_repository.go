@@ -1,101 +1,430 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"lab04-backend/audit"
+	"lab04-backend/database"
 	"lab04-backend/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
 )
 
 // PostRepository handles database operations for posts
 // This repository demonstrates SCANY MAPPING approach for result scanning
 type PostRepository struct {
-	db *sql.DB
+	db      database.DBTX
+	dialect database.Dialect
 }
 
 // NewPostRepository creates a new PostRepository
-func NewPostRepository(db *sql.DB) *PostRepository {
-	return &PostRepository{db: db}
+func NewPostRepository(db database.DBTX) *PostRepository {
+	return &PostRepository{db: db, dialect: database.DialectOf(db)}
 }
 
-// TODO: Implement Create method using scany for result mapping
+const postColumns = "id, user_id, title, content, published, created_at, updated_at, deleted_at, slug, status, publish_at"
+
+// Create is CreateContext with context.Background()
 func (r *PostRepository) Create(req *models.CreatePostRequest) (*models.Post, error) {
-	// TODO: Create a new post in the database using scany for result mapping
-	// - Validate the request using req.Validate()
-	// - Insert into posts table with RETURNING clause
-	// - Use sqlscan.Get() to scan the RETURNING result into a Post struct
-	// Example: sqlscan.Get(context.Background(), r.db, &post, query, args...)
-	// This eliminates manual row scanning compared to user repository
-	return nil, fmt.Errorf("TODO: implement Create method with scany mapping")
+	return r.CreateContext(context.Background(), req)
+}
+
+// CreateContext inserts a new post and scans the RETURNING result with scany.
+// Without a slug in the request, one is generated from the title, and
+// generated again if a concurrent insert takes it first; a requested slug
+// that is taken fails with ErrSlugTaken.
+func (r *PostRepository) CreateContext(ctx context.Context, req *models.CreatePostRequest) (*models.Post, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	post := req.ToPost()
+	query := r.dialect.Rebind(`
+		INSERT INTO posts (user_id, title, content, published, created_at, updated_at, slug, status, publish_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + postColumns)
+
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var created models.Post
+	var err error
+	for attempt := 1; ; attempt++ {
+		var slug string
+		err = withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+			var err error
+			if slug, err = r.allocateSlug(ctx, tx, post.Slug, post.Title, 0); err != nil {
+				return nil, err
+			}
+			err = r.getIn(ctx, tx, "PostRepository.Create", &created, query,
+				post.UserID, post.Title, post.Content, post.Published, post.CreatedAt, post.UpdatedAt,
+				slug, post.Status, post.PublishAt)
+			if err != nil {
+				return nil, err
+			}
+			return auditEntry(ctx, audit.EntityPost, created.ID, audit.ActionCreate, nil, &created)
+		})
+		if !isSlugViolation(err) {
+			break
+		}
+		if post.Slug != "" || attempt == maxSlugAttempts {
+			err = fmt.Errorf("%w: %s", ErrSlugTaken, slug)
+			break
+		}
+	}
+	if err != nil {
+		return nil, mapQueryError(ctx, "PostRepository.Create", fmt.Errorf("failed to create post: %w", err))
+	}
+	return &created, nil
 }
 
-// TODO: Implement GetByID method using scany
+// GetByID is GetByIDContext with context.Background()
 func (r *PostRepository) GetByID(id int) (*models.Post, error) {
-	// TODO: Get post by ID from database using scany
-	// - Use sqlscan.Get() instead of manual row.Scan()
-	// Example: sqlscan.Get(context.Background(), r.db, &post, "SELECT * FROM posts WHERE id = $1", id)
-	// Notice how this eliminates the need for manual field scanning
-	return nil, fmt.Errorf("TODO: implement GetByID method with scany")
+	return r.GetByIDContext(context.Background(), id)
+}
+
+// GetByIDContext returns the post with the given ID or sql.ErrNoRows.
+// Soft deleted posts are not found.
+func (r *PostRepository) GetByIDContext(ctx context.Context, id int) (*models.Post, error) {
+	query := r.dialect.Rebind(`SELECT ` + postColumns + ` FROM posts WHERE id = ? AND deleted_at IS NULL`)
+
+	var post models.Post
+	if err := r.get(ctx, "PostRepository.GetByID", &post, query, id); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// GetByUserID is GetByUserIDContext with context.Background()
+func (r *PostRepository) GetByUserID(userID int, opts ...ListOption) ([]models.Post, error) {
+	return r.GetByUserIDContext(context.Background(), userID, opts...)
+}
+
+// GetByUserIDContext returns all posts of a user, newest first
+func (r *PostRepository) GetByUserIDContext(ctx context.Context, userID int, opts ...ListOption) ([]models.Post, error) {
+	o := applyListOptions(opts)
+	query := r.dialect.Rebind(`
+		SELECT ` + postColumns + ` FROM posts
+		WHERE user_id = ? AND ` + o.notDeleted("deleted_at") + `
+		ORDER BY created_at DESC, id DESC`)
+
+	return r.selectPosts(ctx, "PostRepository.GetByUserID", query, userID)
+}
+
+// GetPublished is GetPublishedContext with context.Background()
+func (r *PostRepository) GetPublished(opts ...ListOption) ([]models.Post, error) {
+	return r.GetPublishedContext(context.Background(), opts...)
+}
+
+// GetPublishedContext returns all published posts, newest first
+func (r *PostRepository) GetPublishedContext(ctx context.Context, opts ...ListOption) ([]models.Post, error) {
+	o := applyListOptions(opts)
+	query := r.dialect.Rebind(`
+		SELECT ` + postColumns + ` FROM posts
+		WHERE published = ? AND ` + o.notDeleted("deleted_at") + `
+		ORDER BY created_at DESC, id DESC`)
+
+	return r.selectPosts(ctx, "PostRepository.GetPublished", query, true)
+}
+
+// GetAll is GetAllContext with context.Background()
+func (r *PostRepository) GetAll(opts ...ListOption) ([]models.Post, error) {
+	return r.GetAllContext(context.Background(), opts...)
+}
+
+// GetAllContext returns all posts, newest first
+func (r *PostRepository) GetAllContext(ctx context.Context, opts ...ListOption) ([]models.Post, error) {
+	o := applyListOptions(opts)
+	query := `
+		SELECT ` + postColumns + ` FROM posts
+		WHERE ` + o.notDeleted("deleted_at") + `
+		ORDER BY created_at DESC, id DESC`
+
+	return r.selectPosts(ctx, "PostRepository.GetAll", query)
 }
 
-// TODO: Implement GetByUserID method using scany
-func (r *PostRepository) GetByUserID(userID int) ([]models.Post, error) {
-	// TODO: Get all posts by user ID using scany
-	// - Use sqlscan.Select() for multiple rows instead of manual rows.Next() loop
-	// Example: sqlscan.Select(context.Background(), r.db, &posts, query, userID)
-	// This eliminates manual iteration and scanning
-	return nil, fmt.Errorf("TODO: implement GetByUserID method with scany")
+// GetPage is GetPageContext with context.Background()
+func (r *PostRepository) GetPage(page PageRequest, opts ...ListOption) (*Page[models.Post], error) {
+	return r.GetPageContext(context.Background(), page, opts...)
 }
 
-// TODO: Implement GetPublished method using scany
-func (r *PostRepository) GetPublished() ([]models.Post, error) {
-	// TODO: Get all published posts using scany
-	// - Use sqlscan.Select() for multiple rows
-	// - Query posts where published = true
-	// - Order by created_at DESC
-	return nil, fmt.Errorf("TODO: implement GetPublished method with scany")
+// GetPageContext returns one page of posts, newest first, using keyset
+// pagination on (created_at, id)
+func (r *PostRepository) GetPageContext(ctx context.Context, page PageRequest, opts ...ListOption) (*Page[models.Post], error) {
+	k, err := page.keyset(true, "")
+	if err != nil {
+		return nil, err
+	}
+
+	where := applyListOptions(opts).notDeleted("deleted_at")
+	if k.where != "" {
+		where += " AND " + k.where
+	}
+	query := r.dialect.Rebind(`
+		SELECT ` + postColumns + ` FROM posts
+		WHERE ` + where + `
+		ORDER BY ` + k.order + `
+		LIMIT ?`)
+
+	posts, err := r.selectPosts(ctx, "PostRepository.GetPage", query, append(k.args, k.limit+1)...)
+	if err != nil {
+		return nil, err
+	}
+	return newPage(posts, k, postCursor), nil
 }
 
-// TODO: Implement GetAll method using scany
-func (r *PostRepository) GetAll() ([]models.Post, error) {
-	// TODO: Get all posts from database using scany
-	// - Use sqlscan.Select() instead of manual rows iteration
-	// Example: sqlscan.Select(context.Background(), r.db, &posts, "SELECT * FROM posts ORDER BY created_at DESC")
-	// Compare this simplicity with manual scanning in user repository
-	return nil, fmt.Errorf("TODO: implement GetAll method with scany")
+func postCursor(p models.Post) Cursor {
+	return Cursor{CreatedAt: p.CreatedAt, ID: p.ID}
 }
 
-// TODO: Implement Update method using scany
+// Update is UpdateContext with context.Background()
 func (r *PostRepository) Update(id int, req *models.UpdatePostRequest) (*models.Post, error) {
-	// TODO: Update post in database using scany
-	// - Build dynamic UPDATE query based on non-nil fields in req
-	// - Update updated_at timestamp
-	// - Use sqlscan.Get() with RETURNING clause to get updated post
-	// This avoids a separate SELECT query after UPDATE
-	return nil, fmt.Errorf("TODO: implement Update method with scany")
+	return r.UpdateContext(context.Background(), id, req)
+}
+
+// UpdateContext changes the non-nil fields of req and returns the updated
+// post. The slug stays the same when the title changes, so permalinks keep
+// working; setting Published moves the post to the published or draft state.
+func (r *PostRepository) UpdateContext(ctx context.Context, id int, req *models.UpdatePostRequest) (*models.Post, error) {
+	if req.Slug != nil && !models.ValidSlug(*req.Slug) {
+		return nil, errors.New("slug must be lowercase letters, digits and single hyphens")
+	}
+
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var post models.Post
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		var before models.Post
+		err := r.getIn(ctx, tx, "PostRepository.Update", &before, r.dialect.Rebind(`
+			SELECT `+postColumns+` FROM posts WHERE id = ? AND deleted_at IS NULL`), id)
+		if err != nil {
+			return nil, err
+		}
+
+		var sets []string
+		var args []interface{}
+		if req.Title != nil {
+			sets = append(sets, "title = ?")
+			args = append(args, *req.Title)
+		}
+		if req.Content != nil {
+			sets = append(sets, "content = ?")
+			args = append(args, *req.Content)
+		}
+		if req.Slug != nil && *req.Slug != before.Slug {
+			if _, err := r.allocateSlug(ctx, tx, *req.Slug, "", id); err != nil {
+				return nil, err
+			}
+			sets = append(sets, "slug = ?")
+			args = append(args, *req.Slug)
+		}
+		now := time.Now().UTC()
+		if req.Published != nil {
+			after := before
+			if *req.Published {
+				after.SetStatus(models.PostPublished, nil, now)
+			} else {
+				after.SetStatus(models.PostDraft, nil, now)
+			}
+			sets = append(sets, "status = ?", "published = ?", "publish_at = ?")
+			args = append(args, after.Status, after.Published, after.PublishAt)
+		}
+		sets = append(sets, "updated_at = ?")
+		args = append(args, now, id)
+
+		query := r.dialect.Rebind(`
+			UPDATE posts SET ` + strings.Join(sets, ", ") + `
+			WHERE id = ? AND deleted_at IS NULL
+			RETURNING ` + postColumns)
+		if err := r.getIn(ctx, tx, "PostRepository.Update", &post, query, args...); err != nil {
+			if req.Slug != nil && isSlugViolation(err) {
+				return nil, fmt.Errorf("%w: %s", ErrSlugTaken, *req.Slug)
+			}
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityPost, id, audit.ActionUpdate, &before, &post)
+	})
+	if err != nil {
+		return nil, mapQueryError(ctx, "PostRepository.Update", err)
+	}
+	return &post, nil
+}
+
+// get scans a single post with scany, reporting a missing row as sql.ErrNoRows
+func (r *PostRepository) get(ctx context.Context, op string, post *models.Post, query string, args ...interface{}) error {
+	return r.getIn(ctx, r.db, op, post, query, args...)
+}
+
+// getIn is get running on db, e.g. a transaction
+func (r *PostRepository) getIn(ctx context.Context, db database.DBTX, op string, post *models.Post, query string, args ...interface{}) error {
+	ctx, cancel := queryContext(ctx, db)
+	defer cancel()
+
+	err := sqlscan.Get(ctx, db, post, query, args...)
+	if sqlscan.NotFound(err) {
+		return sql.ErrNoRows
+	}
+	return mapQueryError(ctx, op, err)
+}
+
+// selectPosts scans all posts returned by query with scany
+func (r *PostRepository) selectPosts(ctx context.Context, op, query string, args ...interface{}) ([]models.Post, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	posts := []models.Post{}
+	err := sqlscan.Select(ctx, r.db, &posts, query, args...)
+	return posts, mapQueryError(ctx, op, err)
 }
 
-// TODO: Implement Delete method (standard SQL)
+// Delete is DeleteContext with context.Background()
 func (r *PostRepository) Delete(id int) error {
-	// TODO: Delete post from database
-	// - Delete from posts table by ID
-	// - Return error if post doesn't exist
-	// Note: Delete operations typically don't need scany since no data is returned
-	return fmt.Errorf("TODO: implement Delete method")
+	return r.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext soft deletes the post with the given ID or returns sql.ErrNoRows
+func (r *PostRepository) DeleteContext(ctx context.Context, id int) error {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	now := time.Now().UTC()
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		var before, after models.Post
+		err := r.getIn(ctx, tx, "PostRepository.Delete", &before, r.dialect.Rebind(`
+			SELECT `+postColumns+` FROM posts WHERE id = ? AND deleted_at IS NULL`), id)
+		if err != nil {
+			return nil, err
+		}
+		err = r.getIn(ctx, tx, "PostRepository.Delete", &after, r.dialect.Rebind(`
+			UPDATE posts SET deleted_at = ?, updated_at = ?
+			WHERE id = ? AND deleted_at IS NULL
+			RETURNING `+postColumns), now, now, id)
+		if err != nil {
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityPost, id, audit.ActionDelete, &before, &after)
+	})
+	return mapQueryError(ctx, "PostRepository.Delete", err)
+}
+
+// Restore is RestoreContext with context.Background()
+func (r *PostRepository) Restore(id int) (*models.Post, error) {
+	return r.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext undoes a soft delete and returns the post, or sql.ErrNoRows
+// if there is no deleted post with the given ID
+func (r *PostRepository) RestoreContext(ctx context.Context, id int) (*models.Post, error) {
+	query := r.dialect.Rebind(`
+		UPDATE posts SET deleted_at = NULL, updated_at = ?
+		WHERE id = ? AND deleted_at IS NOT NULL
+		RETURNING ` + postColumns)
+
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var post models.Post
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		var before models.Post
+		err := r.getIn(ctx, tx, "PostRepository.Restore", &before, r.dialect.Rebind(`
+			SELECT `+postColumns+` FROM posts WHERE id = ? AND deleted_at IS NOT NULL`), id)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.getIn(ctx, tx, "PostRepository.Restore", &post, query, time.Now().UTC(), id); err != nil {
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityPost, id, audit.ActionRestore, &before, &post)
+	})
+	if err != nil {
+		return nil, mapQueryError(ctx, "PostRepository.Restore", err)
+	}
+	return &post, nil
+}
+
+// PurgeDeletedBefore is PurgeDeletedBeforeContext with context.Background()
+func (r *PostRepository) PurgeDeletedBefore(before time.Time) (int64, error) {
+	return r.PurgeDeletedBeforeContext(context.Background(), before)
+}
+
+// PurgeDeletedBeforeContext permanently removes posts soft deleted before the
+// given time, together with their comments, and returns the number of posts
+// removed
+func (r *PostRepository) PurgeDeletedBeforeContext(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	const doomed = `SELECT id FROM posts WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+	cutoff := before.UTC()
+	var purged int64
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		posts := []models.Post{}
+		err := sqlscan.Select(ctx, tx, &posts, r.dialect.Rebind(`
+			SELECT `+postColumns+` FROM posts WHERE id IN (`+doomed+`)`), cutoff)
+		if err != nil {
+			return nil, err
+		}
+		comments, err := cascadedComments(ctx, tx, r.dialect, `post_id IN (`+doomed+`)`, cutoff)
+		if err != nil {
+			return nil, err
+		}
+
+		ids, err := returningIDs(ctx, tx, r.dialect.Rebind(`
+			DELETE FROM posts WHERE deleted_at IS NOT NULL AND deleted_at < ?
+			RETURNING id`), cutoff)
+		if err != nil {
+			return nil, err
+		}
+		purged = int64(len(ids))
+
+		entries, err := purgeEntries(ctx, audit.EntityPost, posts, postID, ids)
+		if err != nil {
+			return nil, err
+		}
+		commentEntries, err := cascadeEntries(ctx, audit.EntityComment, comments, commentID)
+		if err != nil {
+			return nil, err
+		}
+		return append(entries, commentEntries...), nil
+	})
+	if err != nil {
+		return 0, mapQueryError(ctx, "PostRepository.PurgeDeletedBefore", err)
+	}
+	return purged, nil
 }
 
-// TODO: Implement Count method (standard SQL)
+// Count is CountContext with context.Background()
 func (r *PostRepository) Count() (int, error) {
-	// TODO: Count total number of posts
-	// - Return count of posts in database
-	// - Can use standard QueryRow.Scan() for single values like count
-	return 0, fmt.Errorf("TODO: implement Count method")
+	return r.CountContext(context.Background())
+}
+
+// CountContext returns the number of posts that are not deleted
+func (r *PostRepository) CountContext(ctx context.Context) (int, error) {
+	return r.count(ctx, "PostRepository.Count", `SELECT COUNT(*) FROM posts WHERE deleted_at IS NULL`)
 }
 
-// TODO: Implement CountByUserID method (standard SQL)
+// CountByUserID is CountByUserIDContext with context.Background()
 func (r *PostRepository) CountByUserID(userID int) (int, error) {
-	// TODO: Count posts by user ID
-	// - Return count of posts for specific user
-	// - Use standard QueryRow.Scan() for single integer result
-	return 0, fmt.Errorf("TODO: implement CountByUserID method")
+	return r.CountByUserIDContext(context.Background(), userID)
+}
+
+// CountByUserIDContext returns the number of posts of a user that are not deleted
+func (r *PostRepository) CountByUserIDContext(ctx context.Context, userID int) (int, error) {
+	query := r.dialect.Rebind(`SELECT COUNT(*) FROM posts WHERE user_id = ? AND deleted_at IS NULL`)
+	return r.count(ctx, "PostRepository.CountByUserID", query, userID)
+}
+
+func (r *PostRepository) count(ctx context.Context, op, query string, args ...interface{}) (int, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&count)
+	return count, mapQueryError(ctx, op, err)
 }
@@ -0,0 +1,279 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+)
+
+func TestPostRepository_SoftDelete(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	repo := NewPostRepository(db.Conn)
+
+	user, err := userRepo.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	post, err := repo.Create(&models.CreatePostRequest{
+		UserID:    user.ID,
+		Title:     "Soft deleted post",
+		Content:   "Some content",
+		Published: true,
+	})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	if err := repo.Delete(post.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if _, err := repo.GetByID(post.ID); err == nil {
+		t.Error("GetByID() should not find a deleted post")
+	}
+	if count, _ := repo.CountByUserID(user.ID); count != 0 {
+		t.Errorf("CountByUserID() = %d, want 0", count)
+	}
+
+	published, err := repo.GetPublished(IncludeDeleted())
+	if err != nil {
+		t.Fatalf("GetPublished(IncludeDeleted()) failed: %v", err)
+	}
+	if len(published) != 1 || !published[0].IsDeleted() {
+		t.Errorf("GetPublished(IncludeDeleted()) = %v, want the deleted post", published)
+	}
+
+	search := NewSearchService(repo.db)
+	found, err := search.SearchPosts(t.Context(), SearchFilters{Query: "soft"})
+	if err != nil {
+		t.Fatalf("SearchPosts() failed: %v", err)
+	}
+	if len(found) != 0 {
+		t.Errorf("SearchPosts() = %v, want no deleted posts", found)
+	}
+	found, err = search.SearchPosts(t.Context(), SearchFilters{Query: "soft", IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("SearchPosts(IncludeDeleted) failed: %v", err)
+	}
+	if len(found) != 1 {
+		t.Errorf("SearchPosts(IncludeDeleted) returned %d posts, want 1", len(found))
+	}
+
+	restored, err := repo.Restore(post.ID)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if restored.IsDeleted() || restored.Title != post.Title {
+		t.Errorf("Restore() = %+v, want the live post", restored)
+	}
+}
+
+func TestPostRepository_Categories(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	users := NewUserRepository(db.Conn)
+	repo := NewPostRepository(db.Conn)
+	categories := NewCategoryRepository(db.Gorm)
+
+	user, err := users.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	var ids []int
+	for _, name := range []string{"Go", "Databases", "Testing"} {
+		category := (&models.CreateCategoryRequest{Name: name}).ToCategory()
+		if err := categories.Create(category); err != nil {
+			t.Fatalf("Failed to create category: %v", err)
+		}
+		ids = append(ids, int(category.ID))
+	}
+	goID, dbID, testingID := ids[0], ids[1], ids[2]
+
+	newPost := func(title string) *models.Post {
+		post, err := repo.Create(&models.CreatePostRequest{UserID: user.ID, Title: title})
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		return post
+	}
+	both := newPost("Go and databases")
+	onlyGo := newPost("Only about Go")
+
+	if err := repo.SetCategories(both.ID, []int{goID, dbID, goID}); err != nil {
+		t.Fatalf("SetCategories() failed: %v", err)
+	}
+	if err := repo.AddCategories(onlyGo.ID, goID); err != nil {
+		t.Fatalf("AddCategories() failed: %v", err)
+	}
+	if err := repo.AddCategories(onlyGo.ID, goID); err != nil {
+		t.Errorf("AddCategories() of an existing category failed: %v", err)
+	}
+	if err := repo.AddCategories(99999, goID); err != sql.ErrNoRows {
+		t.Errorf("AddCategories() on a missing post error = %v, want sql.ErrNoRows", err)
+	}
+
+	got, err := repo.GetCategories(both.ID)
+	if err != nil {
+		t.Fatalf("GetCategories() failed: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "Databases" || got[1].Name != "Go" {
+		t.Errorf("GetCategories() = %v, want Databases and Go", got)
+	}
+
+	anyPosts, err := repo.GetByCategories([]int{goID, dbID}, MatchAnyCategory)
+	if err != nil {
+		t.Fatalf("GetByCategories(any) failed: %v", err)
+	}
+	if len(anyPosts) != 2 {
+		t.Errorf("GetByCategories(any) returned %d posts, want 2", len(anyPosts))
+	}
+	allPosts, err := repo.GetByCategories([]int{goID, dbID}, MatchAllCategories)
+	if err != nil {
+		t.Fatalf("GetByCategories(all) failed: %v", err)
+	}
+	if len(allPosts) != 1 || allPosts[0].ID != both.ID {
+		t.Errorf("GetByCategories(all) = %v, want only %q", allPosts, both.Title)
+	}
+
+	search := NewSearchService(db.Conn)
+	found, err := search.SearchPosts(t.Context(), SearchFilters{
+		CategoryIDs:   []int{goID, dbID},
+		CategoryMatch: MatchAllCategories,
+	})
+	if err != nil {
+		t.Fatalf("SearchPosts(categories) failed: %v", err)
+	}
+	if len(found) != 1 || found[0].ID != both.ID {
+		t.Errorf("SearchPosts(categories) = %v, want only %q", found, both.Title)
+	}
+
+	if err := repo.SetCategories(both.ID, []int{testingID}); err != nil {
+		t.Fatalf("SetCategories() failed: %v", err)
+	}
+	if err := repo.RemoveCategories(onlyGo.ID, goID, dbID); err != nil {
+		t.Fatalf("RemoveCategories() failed: %v", err)
+	}
+	anyPosts, err = repo.GetByCategories([]int{goID, dbID}, MatchAnyCategory)
+	if err != nil || len(anyPosts) != 0 {
+		t.Errorf("GetByCategories() after changes = %v, %v; want none", anyPosts, err)
+	}
+
+	// An unknown category violates the foreign key and changes nothing
+	if err := repo.SetCategories(both.ID, []int{99999}); err == nil {
+		t.Error("SetCategories() with an unknown category should fail")
+	}
+	if got, _ := repo.GetCategories(both.ID); len(got) != 1 || got[0].Name != "Testing" {
+		t.Errorf("GetCategories() after failed SetCategories() = %v, want Testing", got)
+	}
+}
+
+func TestPostRepository_Monitored(t *testing.T) {
+	t.Parallel()
+	testDB := dbtest.New(t)
+	db := database.NewMonitoredDB(testDB.SQL, database.NewMonitor(0))
+	users := NewUserRepository(db)
+	repo := NewPostRepository(db)
+
+	user, err := users.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	post, err := repo.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Monitored post"})
+	if err != nil {
+		t.Fatalf("Failed to create post: %v", err)
+	}
+	category := (&models.CreateCategoryRequest{Name: "Go"}).ToCategory()
+	if err := NewCategoryRepository(testDB.Gorm).Create(category); err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+	if err := repo.SetCategories(post.ID, []int{int(category.ID)}); err != nil {
+		t.Fatalf("SetCategories() failed: %v", err)
+	}
+
+	// Writes through a monitored connection still run in a transaction
+	if err := repo.SetCategories(post.ID, []int{99999}); err == nil {
+		t.Fatal("SetCategories() with a missing category should fail")
+	}
+	got, err := repo.GetCategories(post.ID)
+	if err != nil {
+		t.Fatalf("GetCategories() failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != category.ID {
+		t.Errorf("GetCategories() after a failed SetCategories() = %v, want Go", got)
+	}
+
+	if got := db.Monitor.Latency(); got.Count == 0 || got.Errors == 0 {
+		t.Errorf("Latency() = %+v, want queries and the failed insert recorded", got)
+	}
+}
+
+func TestPostRepository_RawTx(t *testing.T) {
+	t.Parallel()
+	testDB := dbtest.New(t)
+	category := (&models.CreateCategoryRequest{Name: "Go"}).ToCategory()
+	if err := NewCategoryRepository(testDB.Gorm).Create(category); err != nil {
+		t.Fatalf("Failed to create category: %v", err)
+	}
+
+	ctx := context.Background()
+	tx, err := testDB.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("BeginTx() failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	for name, conn := range map[string]database.DBTX{
+		"sql.Tx":      tx,
+		"WithDialect": database.WithDialect(tx, database.DialectSQLite),
+	} {
+		users := NewUserRepository(conn)
+		repo := NewPostRepository(conn)
+
+		user, err := users.Create(&models.CreateUserRequest{Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatalf("%s: failed to create user: %v", name, err)
+		}
+		post, err := repo.Create(&models.CreatePostRequest{UserID: user.ID, Title: name + " post"})
+		if err != nil {
+			t.Fatalf("%s: failed to create post: %v", name, err)
+		}
+		if err := repo.SetCategories(post.ID, []int{int(category.ID)}); err != nil {
+			t.Fatalf("%s: SetCategories() failed: %v", name, err)
+		}
+
+		// Multi-statement writes run in a savepoint of the caller's transaction
+		if err := repo.SetCategories(post.ID, []int{99999}); err == nil {
+			t.Fatalf("%s: SetCategories() with a missing category should fail", name)
+		}
+		got, err := repo.GetCategories(post.ID)
+		if err != nil {
+			t.Fatalf("%s: GetCategories() failed: %v", name, err)
+		}
+		if len(got) != 1 || got[0].ID != category.ID {
+			t.Errorf("%s: GetCategories() after a failed SetCategories() = %v, want Go", name, got)
+		}
+		if err := repo.Delete(post.ID); err != nil {
+			t.Errorf("%s: Delete() failed: %v", name, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit() failed: %v", err)
+	}
+	count, err := NewPostRepository(testDB.SQL).Count()
+	if err != nil {
+		t.Fatalf("Count() failed: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Count() after committing = %d, want the deleted posts hidden", count)
+	}
+	count, err = NewUserRepository(testDB.SQL).Count()
+	if err != nil || count != 2 {
+		t.Errorf("users Count() after committing = %d, %v, want 2", count, err)
+	}
+}
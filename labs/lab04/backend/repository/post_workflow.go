@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"lab04-backend/audit"
+	"lab04-backend/database"
+	"lab04-backend/models"
+
+	"github.com/georgysavva/scany/v2/sqlscan"
+)
+
+// ErrSlugTaken is returned when a requested slug belongs to another post,
+// including a soft deleted one
+var ErrSlugTaken = errors.New("slug is already taken")
+
+// ErrInvalidTransition is returned when a post cannot move to the requested
+// publishing state
+var ErrInvalidTransition = errors.New("invalid post status transition")
+
+// maxSlugAttempts bounds how often CreateContext generates a slug again after
+// concurrent inserts took the previous ones
+const maxSlugAttempts = 10
+
+// isSlugViolation reports whether err is the unique index on posts.slug
+// rejecting a slug that another transaction committed after allocateSlug
+// found it free
+func isSlugViolation(err error) bool {
+	return database.IsUniqueViolation(err, "posts", "slug")
+}
+
+// allocateSlug returns the slug for a post. A requested slug is returned
+// unless another post than exceptID has it; otherwise the slug is generated
+// from title, with the first free suffix ("-2", "-3", ...) on a collision.
+func (r *PostRepository) allocateSlug(ctx context.Context, db database.DBTX, requested, title string, exceptID int) (string, error) {
+	base := requested
+	if base == "" {
+		base = models.Slugify(title)
+	}
+
+	// Slugs are only letters, digits and hyphens, so base holds no LIKE wildcards
+	taken := []string{}
+	err := sqlscan.Select(ctx, db, &taken, r.dialect.Rebind(`
+		SELECT slug FROM posts WHERE (slug = ? OR slug LIKE ?) AND id <> ?`),
+		base, base+"-%", exceptID)
+	if err != nil {
+		return "", err
+	}
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+
+	switch {
+	case !used[base]:
+		return base, nil
+	case requested != "":
+		return "", fmt.Errorf("%w: %s", ErrSlugTaken, requested)
+	}
+	for n := 2; ; n++ {
+		if slug := fmt.Sprintf("%s-%d", base, n); !used[slug] {
+			return slug, nil
+		}
+	}
+}
+
+// GetBySlug is GetBySlugContext with context.Background()
+func (r *PostRepository) GetBySlug(slug string) (*models.Post, error) {
+	return r.GetBySlugContext(context.Background(), slug)
+}
+
+// GetBySlugContext returns the post with the given slug or sql.ErrNoRows.
+// Soft deleted posts are not found; drafts are, so callers serving
+// permalinks should check the status.
+func (r *PostRepository) GetBySlugContext(ctx context.Context, slug string) (*models.Post, error) {
+	query := r.dialect.Rebind(`SELECT ` + postColumns + ` FROM posts WHERE slug = ? AND deleted_at IS NULL`)
+
+	var post models.Post
+	if err := r.get(ctx, "PostRepository.GetBySlug", &post, query, slug); err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
+// SetStatus is SetStatusContext with context.Background()
+func (r *PostRepository) SetStatus(id int, status models.PostStatus, publishAt *time.Time) (*models.Post, error) {
+	return r.SetStatusContext(context.Background(), id, status, publishAt)
+}
+
+// SetStatusContext moves a post through the publishing workflow:
+// draft → scheduled → published → archived, with drafts published directly,
+// and scheduled, published and archived posts sent back to draft. Scheduling
+// needs publishAt; publishing without it uses the current time. A move the
+// workflow does not allow fails with ErrInvalidTransition.
+func (r *PostRepository) SetStatusContext(ctx context.Context, id int, status models.PostStatus, publishAt *time.Time) (*models.Post, error) {
+	if status == models.PostScheduled && publishAt == nil {
+		return nil, errors.New("scheduled posts need a publish time")
+	}
+
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var post models.Post
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		var before models.Post
+		err := r.getIn(ctx, tx, "PostRepository.SetStatus", &before, r.dialect.Rebind(`
+			SELECT `+postColumns+` FROM posts WHERE id = ? AND deleted_at IS NULL`), id)
+		if err != nil {
+			return nil, err
+		}
+		if !before.Status.CanTransition(status) {
+			return nil, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, before.Status, status)
+		}
+
+		now := time.Now().UTC()
+		after := before
+		after.SetStatus(status, publishAt, now)
+		if err := after.Validate(); err != nil {
+			return nil, err
+		}
+
+		query := r.dialect.Rebind(`
+			UPDATE posts SET status = ?, published = ?, publish_at = ?, updated_at = ?
+			WHERE id = ? AND deleted_at IS NULL
+			RETURNING ` + postColumns)
+		err = r.getIn(ctx, tx, "PostRepository.SetStatus", &post, query,
+			after.Status, after.Published, after.PublishAt, now, id)
+		if err != nil {
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityPost, id, audit.ActionUpdate, &before, &post)
+	})
+	if err != nil {
+		return nil, mapQueryError(ctx, "PostRepository.SetStatus", err)
+	}
+	return &post, nil
+}
+
+// PublishDue is PublishDueContext with context.Background()
+func (r *PostRepository) PublishDue(now time.Time) ([]int, error) {
+	return r.PublishDueContext(context.Background(), now)
+}
+
+// PublishDueContext publishes the scheduled posts whose publish time is not
+// after now and returns their IDs
+func (r *PostRepository) PublishDueContext(ctx context.Context, now time.Time) ([]int, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var published []int
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		ids, err := returningIDs(ctx, tx, r.dialect.Rebind(`
+			UPDATE posts SET status = ?, published = ?, updated_at = ?
+			WHERE status = ? AND publish_at <= ? AND deleted_at IS NULL
+			RETURNING id`),
+			models.PostPublished, true, time.Now().UTC(), models.PostScheduled, now.UTC())
+		if err != nil {
+			return nil, err
+		}
+		published = ids
+
+		type state struct {
+			Status    models.PostStatus `json:"status"`
+			Published bool              `json:"published"`
+		}
+		entries := make([]audit.Entry, 0, len(ids))
+		for _, id := range ids {
+			entry, err := audit.NewEntry(ctx, audit.EntityPost, int64(id), audit.ActionUpdate,
+				state{models.PostScheduled, false}, state{models.PostPublished, true})
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		return entries, nil
+	})
+	if err != nil {
+		return nil, mapQueryError(ctx, "PostRepository.PublishDue", err)
+	}
+	return published, nil
+}
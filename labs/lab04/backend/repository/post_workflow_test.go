@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+)
+
+func TestPostRepository_Slugs(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	repo := NewPostRepository(db.Conn)
+
+	user, err := userRepo.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	create := func(title, slug string) (*models.Post, error) {
+		return repo.Create(&models.CreatePostRequest{UserID: user.ID, Title: title, Slug: slug})
+	}
+
+	first, err := create("Hello, World!", "")
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+	if first.Slug != "hello-world" || first.Status != models.PostDraft {
+		t.Errorf("Create() slug = %q, status = %q; want hello-world and draft", first.Slug, first.Status)
+	}
+
+	// A soft deleted post keeps its slug, so old links never point elsewhere
+	if err := repo.Delete(first.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	for _, want := range []string{"hello-world-2", "hello-world-3"} {
+		post, err := create("Hello world", "")
+		if err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		if post.Slug != want {
+			t.Errorf("Create() slug = %q, want %q", post.Slug, want)
+		}
+	}
+
+	if _, err := create("Another post", "hello-world-2"); !errors.Is(err, ErrSlugTaken) {
+		t.Errorf("Create() with a taken slug error = %v, want ErrSlugTaken", err)
+	}
+	custom, err := create("Another post", "custom-link")
+	if err != nil || custom.Slug != "custom-link" {
+		t.Fatalf("Create() with a free slug = %v, %v; want custom-link", custom, err)
+	}
+
+	t.Run("GetBySlug", func(t *testing.T) {
+		post, err := repo.GetBySlug("custom-link")
+		if err != nil || post.ID != custom.ID {
+			t.Fatalf("GetBySlug() = %v, %v; want post %d", post, err, custom.ID)
+		}
+		if _, err := repo.GetBySlug("hello-world"); !errors.Is(err, sql.ErrNoRows) {
+			t.Errorf("GetBySlug() of a deleted post error = %v, want sql.ErrNoRows", err)
+		}
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		title := "A new title"
+		post, err := repo.Update(custom.ID, &models.UpdatePostRequest{Title: &title})
+		if err != nil || post.Slug != "custom-link" {
+			t.Fatalf("Update(title) = %v, %v; want the slug kept", post, err)
+		}
+
+		slug := "hello-world-3"
+		if _, err := repo.Update(custom.ID, &models.UpdatePostRequest{Slug: &slug}); !errors.Is(err, ErrSlugTaken) {
+			t.Errorf("Update() to a taken slug error = %v, want ErrSlugTaken", err)
+		}
+		slug = "Not A Slug"
+		if _, err := repo.Update(custom.ID, &models.UpdatePostRequest{Slug: &slug}); err == nil {
+			t.Error("Update() to an invalid slug should fail")
+		}
+		slug = "renamed"
+		if post, err := repo.Update(custom.ID, &models.UpdatePostRequest{Slug: &slug}); err != nil || post.Slug != slug {
+			t.Errorf("Update(slug) = %v, %v; want renamed", post, err)
+		}
+	})
+}
+
+func TestPostRepository_Workflow(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	repo := NewPostRepository(db.Conn)
+
+	user, err := userRepo.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	post, err := repo.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Scheduled post", Content: "Soon"})
+	if err != nil {
+		t.Fatalf("Create() failed: %v", err)
+	}
+
+	if _, err := repo.SetStatus(post.ID, models.PostArchived, nil); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("SetStatus(draft to archived) error = %v, want ErrInvalidTransition", err)
+	}
+	if _, err := repo.SetStatus(post.ID, models.PostScheduled, nil); err == nil {
+		t.Error("SetStatus(scheduled) without a publish time should fail")
+	}
+
+	publishAt := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	scheduled, err := repo.SetStatus(post.ID, models.PostScheduled, &publishAt)
+	if err != nil {
+		t.Fatalf("SetStatus(scheduled) failed: %v", err)
+	}
+	if scheduled.Published || scheduled.PublishAt == nil || !scheduled.PublishAt.Equal(publishAt) {
+		t.Errorf("SetStatus(scheduled) = %+v, want unpublished until %v", scheduled, publishAt)
+	}
+
+	publisher := NewPublisher(repo, time.Second)
+	var notified []int
+	publisher.OnPublish = func(ids []int) { notified = ids }
+
+	publisher.now = func() time.Time { return publishAt.Add(-time.Minute) }
+	if ids, err := publisher.RunOnce(t.Context()); err != nil || len(ids) != 0 {
+		t.Fatalf("RunOnce() before the publish time = %v, %v; want nothing published", ids, err)
+	}
+
+	publisher.now = func() time.Time { return publishAt }
+	ids, err := publisher.RunOnce(t.Context())
+	if err != nil || len(ids) != 1 || ids[0] != post.ID || len(notified) != 1 {
+		t.Fatalf("RunOnce() at the publish time = %v, %v (notified %v); want post %d", ids, err, notified, post.ID)
+	}
+	published, err := repo.GetByID(post.ID)
+	if err != nil {
+		t.Fatalf("GetByID() failed: %v", err)
+	}
+	if published.Status != models.PostPublished || !published.Published || !published.PublishAt.Equal(publishAt) {
+		t.Errorf("GetByID() after publishing = %+v, want published at %v", published, publishAt)
+	}
+
+	archived, err := repo.SetStatus(post.ID, models.PostArchived, nil)
+	if err != nil || archived.Status != models.PostArchived || archived.Published {
+		t.Fatalf("SetStatus(archived) = %+v, %v; want archived and unpublished", archived, err)
+	}
+	if ids, err := repo.PublishDue(publishAt.Add(time.Hour)); err != nil || len(ids) != 0 {
+		t.Errorf("PublishDue() = %v, %v; want archived posts left alone", ids, err)
+	}
+}
+
+func TestPostRepository_ConcurrentSlugs(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewPostRepository(db.Conn)
+
+	user, err := NewUserRepository(db.Conn).Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	const writers = 8
+	create := func(slug string) []error {
+		errs := make([]error, writers)
+		var wg sync.WaitGroup
+		for i := range writers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[i] = repo.Create(&models.CreatePostRequest{UserID: user.ID, Title: "Same title", Slug: slug})
+			}()
+		}
+		wg.Wait()
+		return errs
+	}
+
+	// Generated slugs move on to the next suffix when another writer wins
+	for _, err := range create("") {
+		if err != nil {
+			t.Fatalf("Create() with a generated slug failed: %v", err)
+		}
+	}
+	posts, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll() failed: %v", err)
+	}
+	slugs := map[string]bool{}
+	for _, post := range posts {
+		slugs[post.Slug] = true
+	}
+	if len(slugs) != writers || !slugs["same-title"] || !slugs[fmt.Sprintf("same-title-%d", writers)] {
+		t.Errorf("slugs = %v, want same-title to same-title-%d", slugs, writers)
+	}
+
+	// Only one writer gets a requested slug
+	created := 0
+	for _, err := range create("contested") {
+		switch {
+		case err == nil:
+			created++
+		case !errors.Is(err, ErrSlugTaken):
+			t.Errorf("Create() with a contested slug error = %v, want ErrSlugTaken", err)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d writers got the contested slug, want 1", created)
+	}
+}
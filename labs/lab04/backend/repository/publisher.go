@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// DefaultPublishInterval is how often a Publisher looks for due posts when
+// no interval is given
+const DefaultPublishInterval = time.Minute
+
+// Publisher publishes scheduled posts in the background once their publish
+// time has come
+type Publisher struct {
+	posts    *PostRepository
+	interval time.Duration
+	now      func() time.Time
+
+	// OnPublish, when set, is called with the IDs of the posts each run
+	// published
+	OnPublish func(ids []int)
+	// OnError is called when a run fails; the default logs the error. The
+	// publisher keeps running and retries on the next tick.
+	OnError func(err error)
+}
+
+// NewPublisher creates a publisher checking posts every interval, or every
+// DefaultPublishInterval when interval <= 0
+func NewPublisher(posts *PostRepository, interval time.Duration) *Publisher {
+	if interval <= 0 {
+		interval = DefaultPublishInterval
+	}
+	return &Publisher{
+		posts:    posts,
+		interval: interval,
+		now:      time.Now,
+		OnError: func(err error) {
+			log.Printf("publisher: %v", err)
+		},
+	}
+}
+
+// RunOnce publishes the posts that are due now and returns their IDs
+func (p *Publisher) RunOnce(ctx context.Context) ([]int, error) {
+	ids, err := p.posts.PublishDueContext(ctx, p.now())
+	if err == nil && len(ids) > 0 && p.OnPublish != nil {
+		p.OnPublish(ids)
+	}
+	return ids, err
+}
+
+// Run publishes due posts right away and then on every tick, until ctx is
+// done
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := p.RunOnce(ctx); err != nil && ctx.Err() == nil && p.OnError != nil {
+			p.OnError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -4,102 +4,388 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
 
+	"lab04-backend/database"
+	"lab04-backend/filter"
 	"lab04-backend/models"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/georgysavva/scany/v2/sqlscan"
 )
 
 // SearchService handles dynamic search operations using Squirrel query builder
 // This service demonstrates SQUIRREL QUERY BUILDER approach for dynamic SQL
 type SearchService struct {
-	db   *sql.DB
-	psql squirrel.StatementBuilderType
+	db      database.DBTX
+	dialect database.Dialect
+	psql    squirrel.StatementBuilderType
 }
 
 // SearchFilters represents search parameters
 type SearchFilters struct {
-	Query        string // Search in title and content
-	UserID       *int   // Filter by user ID
-	Published    *bool  // Filter by published status
-	MinWordCount *int   // Minimum word count in content
-	Limit        int    // Results limit (default 50)
-	Offset       int    // Results offset (for pagination)
-	OrderBy      string // Order by field (title, created_at, updated_at)
-	OrderDir     string // Order direction (ASC, DESC)
-}
-
-// NewSearchService creates a new SearchService
-func NewSearchService(db *sql.DB) *SearchService {
+	Query        string            // Search in title and content
+	UserID       *int              // Filter by user ID
+	Published    *bool             // Filter by published status
+	Status       models.PostStatus // Filter by publishing workflow state
+	MinWordCount *int              // Minimum word count in content
+	Limit        int               // Results limit (default 50)
+	Offset       int               // Results offset (for pagination)
+	OrderBy      string            // Order by field (title, created_at, updated_at); Sort replaces it
+	OrderDir     string            // Order direction (ASC, DESC)
+	After        string            // Keyset cursor: return posts after this one (replaces Offset)
+	Before       string            // Keyset cursor: return posts before this one
+
+	CategoryIDs   []int         // Filter by categories
+	CategoryMatch CategoryMatch // Whether posts need any (default) or all of CategoryIDs
+
+	IncludeDeleted bool // Also return soft deleted posts
+
+	// Filters and Sort are checked against PostSchema, e.g. after
+	// PostSchema.Parse of ?filter=published:eq:true&sort=-created_at
+	Filters []filter.Condition
+	Sort    []filter.Sort
+}
+
+// NewSearchService creates a new SearchService using the placeholder format
+// of the connection's dialect
+func NewSearchService(db database.DBTX) *SearchService {
+	dialect := database.DialectOf(db)
 	return &SearchService{
-		db:   db,
-		psql: squirrel.StatementBuilder.PlaceholderFormat(squirrel.Dollar),
+		db:      db,
+		dialect: dialect,
+		psql:    dialect.StatementBuilder(),
 	}
 }
 
-// TODO: Implement SearchPosts method using Squirrel query builder
+const defaultSearchLimit = 50
+
+// sort returns Sort, or the sort order described by OrderBy and OrderDir.
+// An OrderBy PostSchema cannot sort by falls back to created_at.
+func (f SearchFilters) sort() []filter.Sort {
+	if len(f.Sort) > 0 {
+		return f.Sort
+	}
+	field := "created_at"
+	if _, err := PostSchema.OrderBy(filter.Sort{Field: f.OrderBy}); err == nil {
+		field = f.OrderBy
+	}
+	return []filter.Sort{{Field: field, Desc: !strings.EqualFold(f.OrderDir, "ASC")}}
+}
+
+// SearchPosts returns posts matching filters, built dynamically with Squirrel
+// and scanned with scany. When a cursor is set it returns the items of
+// SearchPostsPage.
 func (s *SearchService) SearchPosts(ctx context.Context, filters SearchFilters) ([]models.Post, error) {
-	// TODO: Build dynamic query using Squirrel instead of string concatenation
-	//
-	// Start with base query:
-	// query := s.psql.Select("id", "user_id", "title", "content", "published", "created_at", "updated_at").
-	//              From("posts")
-	//
-	// Add WHERE conditions dynamically:
-	// - If filters.Query: add ILIKE conditions for title and content
-	// - If filters.UserID: add user_id = ?
-	// - If filters.Published: add published = ?
-	// - If filters.MinWordCount: add word count condition
-	//
-	// Add ORDER BY dynamically:
-	// - Use OrderBy() and validate sort fields
-	//
-	// Add LIMIT/OFFSET:
-	// - Use Limit() and Offset()
-	//
-	// Build final SQL:
-	// sql, args, err := query.ToSql()
-	//
-	// Execute with scany:
-	// var posts []models.Post
-	// err = sqlscan.Select(ctx, s.db, &posts, sql, args...)
-	//
-	// This demonstrates the power of combining Squirrel (dynamic queries)
-	// with scany (automatic result mapping)
-
-	return nil, fmt.Errorf("TODO: implement SearchPosts with Squirrel query builder")
-}
-
-// TODO: Implement SearchUsers method using Squirrel
-func (s *SearchService) SearchUsers(ctx context.Context, nameQuery string, limit int) ([]models.User, error) {
-	// TODO: Build user search query with Squirrel
-	// query := s.psql.Select("id", "name", "email", "created_at", "updated_at").
-	//              From("users").
-	//              Where(squirrel.Like{"name": "%" + nameQuery + "%"}).
-	//              OrderBy("name").
-	//              Limit(uint64(limit))
-	//
-	// sql, args, err := query.ToSql()
-	// var users []models.User
-	// err = sqlscan.Select(ctx, s.db, &users, sql, args...)
-
-	return nil, fmt.Errorf("TODO: implement SearchUsers with Squirrel")
-}
-
-// TODO: Implement GetPostStats method using Squirrel with JOINs
+	if filters.After != "" || filters.Before != "" {
+		page, err := s.SearchPostsPage(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+		return page.Items, nil
+	}
+
+	orderBy, err := PostSchema.OrderBy(filters.sort()...)
+	if err != nil {
+		return nil, err
+	}
+	query := s.BuildDynamicQuery(s.psql.Select(postColumns).From("posts"), filters).OrderBy(orderBy...)
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	query = query.Limit(uint64(limit))
+	if filters.Offset > 0 {
+		query = query.Offset(uint64(filters.Offset))
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build search query: %w", err)
+	}
+
+	posts := []models.Post{}
+	if err := s.selectInto(ctx, "SearchService.SearchPosts", &posts, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
+
+// SearchPostsPage returns one page of posts matching filters using keyset
+// pagination on (created_at, id). Pages are ordered by creation time, newest
+// first unless OrderDir is ASC or Sort is created_at; Offset is ignored.
+func (s *SearchService) SearchPostsPage(ctx context.Context, filters SearchFilters) (*Page[models.Post], error) {
+	if filters.OrderBy != "" && filters.OrderBy != "created_at" {
+		return nil, fmt.Errorf("%w: cursors require ordering by created_at, not %q", ErrInvalidCursor, filters.OrderBy)
+	}
+	if len(filters.Sort) > 1 || len(filters.Sort) == 1 && filters.Sort[0].Field != "created_at" {
+		return nil, fmt.Errorf("%w: cursors require ordering by created_at, not %v", ErrInvalidCursor, filters.Sort)
+	}
+
+	page := PageRequest{Limit: filters.Limit, After: filters.After, Before: filters.Before}
+	k, err := page.keyset(filters.sort()[0].Desc, "")
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.BuildDynamicQuery(s.psql.Select(postColumns).From("posts"), filters)
+	if k.where != "" {
+		query = query.Where(k.where, k.args...)
+	}
+	query = query.OrderBy(k.order).Limit(uint64(k.limit + 1))
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build search query: %w", err)
+	}
+
+	posts := []models.Post{}
+	if err := s.selectInto(ctx, "SearchService.SearchPostsPage", &posts, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return newPage(posts, k, postCursor), nil
+}
+
+// PostSearchResult is a post found by full-text search
+type PostSearchResult struct {
+	models.Post
+	Rank    float64 `json:"rank" db:"rank"`       // bm25 score, lower is better; 0 for LIKE search
+	Snippet string  `json:"snippet" db:"snippet"` // Matching excerpt with terms wrapped in <mark>
+}
+
+const (
+	snippetOpen  = "<mark>"
+	snippetClose = "</mark>"
+	snippetWords = 12
+)
+
+// SearchPostsRanked searches title and content for filters.Query. On SQLite
+// built with FTS5 it uses the posts_fts index and orders results by bm25,
+// with title matches weighted higher; elsewhere it falls back to LIKE and
+// orders by creation time. Limit, Offset and the other filters apply; ranks
+// are not a stable key, so After and Before are rejected with
+// ErrInvalidCursor.
+func (s *SearchService) SearchPostsRanked(ctx context.Context, filters SearchFilters) ([]PostSearchResult, error) {
+	if strings.TrimSpace(filters.Query) == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if filters.After != "" || filters.Before != "" {
+		return nil, fmt.Errorf("%w: ranked search pages with Offset, not cursors", ErrInvalidCursor)
+	}
+
+	useFTS, err := s.FullTextAvailable(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !useFTS {
+		return s.searchPostsLike(ctx, filters)
+	}
+
+	match := ftsQuery(filters.Query)
+	terms := filters
+	terms.Query = "" // matched by FTS instead of LIKE
+
+	query := s.BuildDynamicQuery(
+		s.psql.Select(postColumns, "r.rank", "r.snippet").
+			From("posts").
+			JoinClause(`JOIN (
+				SELECT rowid, bm25(posts_fts, 10.0, 1.0) AS rank,
+					snippet(posts_fts, -1, ?, ?, '…', ?) AS snippet
+				FROM posts_fts WHERE posts_fts MATCH ?
+			) r ON r.rowid = posts.id`, snippetOpen, snippetClose, snippetWords, match),
+		terms,
+	).OrderBy("r.rank", "id")
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	query = query.Limit(uint64(limit))
+	if filters.Offset > 0 {
+		query = query.Offset(uint64(filters.Offset))
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build full-text query: %w", err)
+	}
+
+	results := []PostSearchResult{}
+	if err := s.selectInto(ctx, "SearchService.SearchPostsRanked", &results, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// FullTextAvailable reports whether the FTS5 index can be used
+func (s *SearchService) FullTextAvailable(ctx context.Context) (bool, error) {
+	if available, err := database.FTS5Available(ctx, s.db); err != nil || !available {
+		return false, err
+	}
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'posts_fts')`).Scan(&exists)
+	return exists, err
+}
+
+// searchPostsLike is the LIKE fallback of SearchPostsRanked
+func (s *SearchService) searchPostsLike(ctx context.Context, filters SearchFilters) ([]PostSearchResult, error) {
+	posts, err := s.SearchPosts(ctx, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]PostSearchResult, len(posts))
+	for i, post := range posts {
+		snippet := highlight(post.Content, filters.Query)
+		if !strings.Contains(snippet, snippetOpen) {
+			snippet = highlight(post.Title, filters.Query)
+		}
+		results[i] = PostSearchResult{Post: post, Snippet: snippet}
+	}
+	return results, nil
+}
+
+// ftsQuery quotes every word of a user query so that FTS5 operators and
+// punctuation are matched literally; the words are ANDed together
+func ftsQuery(q string) string {
+	words := strings.Fields(q)
+	for i, word := range words {
+		words[i] = `"` + strings.ReplaceAll(word, `"`, `""`) + `"`
+	}
+	return strings.Join(words, " ")
+}
+
+// highlight returns up to snippetWords words of text around the first
+// case-insensitive occurrence of q, with the occurrence wrapped in <mark>
+func highlight(text, q string) string {
+	loc := regexp.MustCompile(`(?i)` + regexp.QuoteMeta(q)).FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+
+	before := strings.Fields(text[:loc[0]])
+	after := strings.Fields(text[loc[1]:])
+	keepBefore := min(len(before), snippetWords/2)
+	keepAfter := min(len(after), snippetWords-keepBefore)
+
+	var b strings.Builder
+	if keepBefore < len(before) {
+		b.WriteString("…")
+	}
+	if keepBefore > 0 {
+		b.WriteString(strings.Join(before[len(before)-keepBefore:], " "))
+		if endsWithSpace(text[:loc[0]]) {
+			b.WriteString(" ")
+		}
+	}
+	b.WriteString(snippetOpen + text[loc[0]:loc[1]] + snippetClose)
+	if keepAfter > 0 {
+		if startsWithSpace(text[loc[1]:]) {
+			b.WriteString(" ")
+		}
+		b.WriteString(strings.Join(after[:keepAfter], " "))
+	}
+	if keepAfter < len(after) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+func endsWithSpace(s string) bool {
+	return strings.TrimRightFunc(s, unicode.IsSpace) != s
+}
+
+func startsWithSpace(s string) bool {
+	return strings.TrimLeftFunc(s, unicode.IsSpace) != s
+}
+
+// SearchUsers returns users whose name contains nameQuery, case-insensitively
+func (s *SearchService) SearchUsers(ctx context.Context, nameQuery string, limit int, opts ...ListOption) ([]models.User, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	query := s.psql.Select(userColumns).
+		From("users").
+		Where(s.dialect.ILike("name", "%"+database.EscapeLike(nameQuery)+"%")).
+		Where(applyListOptions(opts).notDeleted("deleted_at")).
+		OrderBy("name", "id").
+		Limit(uint64(limit))
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user search query: %w", err)
+	}
+
+	users := []models.User{}
+	if err := s.selectInto(ctx, "SearchService.SearchUsers", &users, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// FilterUsers returns the users matching q, ordered by q.Sort or by ID. The
+// fields are those of UserSchema.
+func (s *SearchService) FilterUsers(ctx context.Context, q filter.Query, limit int, opts ...ListOption) ([]models.User, error) {
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	query := s.psql.Select(userColumns).
+		From("users").
+		Where(applyListOptions(opts).notDeleted("deleted_at")).
+		Limit(uint64(limit))
+	if len(q.Sort) == 0 {
+		q.Sort = []filter.Sort{{Field: "id"}}
+	}
+	query, err := UserSchema.Apply(query, s.dialect, q)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build user filter query: %w", err)
+	}
+
+	users := []models.User{}
+	if err := s.selectInto(ctx, "SearchService.FilterUsers", &users, sqlStr, args...); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// GetPostStats returns aggregated statistics over posts that are not deleted.
+// TotalComments counts the approved, live comments on those posts.
 func (s *SearchService) GetPostStats(ctx context.Context) (*PostStats, error) {
-	// TODO: Build complex query with JOINs using Squirrel
-	// query := s.psql.Select(
-	//     "COUNT(p.id) as total_posts",
-	//     "COUNT(CASE WHEN p.published = true THEN 1 END) as published_posts",
-	//     "COUNT(DISTINCT p.user_id) as active_users",
-	//     "AVG(LENGTH(p.content)) as avg_content_length",
-	// ).From("posts p").
-	//   Join("users u ON p.user_id = u.id")
-	//
-	// This shows how Squirrel handles complex queries better than string building
+	query := s.psql.Select(
+		"COUNT(p.id) AS total_posts",
+		"COUNT(CASE WHEN p.published THEN 1 END) AS published_posts",
+		"COUNT(DISTINCT p.user_id) AS active_users",
+		"COALESCE(AVG(LENGTH(p.content)), 0) AS avg_content_length",
+		"COALESCE(SUM(c.comment_count), 0) AS total_comments",
+	).From("posts p").
+		Join("users u ON p.user_id = u.id").
+		LeftJoin(approvedCommentCounts("post_id", "c") + " ON c.post_id = p.id").
+		Where("p.deleted_at IS NULL AND u.deleted_at IS NULL")
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build stats query: %w", err)
+	}
 
-	return nil, fmt.Errorf("TODO: implement GetPostStats with Squirrel JOINs")
+	ctx, cancel := queryContext(ctx, s.db)
+	defer cancel()
+
+	var stats PostStats
+	if err := sqlscan.Get(ctx, s.db, &stats, sqlStr, args...); err != nil {
+		return nil, mapQueryError(ctx, "SearchService.GetPostStats", err)
+	}
+	return &stats, nil
 }
 
 // PostStats represents aggregated post statistics
@@ -108,62 +394,135 @@ type PostStats struct {
 	PublishedPosts   int     `db:"published_posts"`
 	ActiveUsers      int     `db:"active_users"`
 	AvgContentLength float64 `db:"avg_content_length"`
+	TotalComments    int     `db:"total_comments"`
+}
+
+// approvedCommentCounts returns a derived table, aliased as alias, with the
+// number of approved, live comments per value of column
+func approvedCommentCounts(column, alias string) string {
+	return "(SELECT " + column + ", COUNT(*) AS comment_count FROM comments" +
+		" WHERE status = '" + string(models.CommentApproved) + "' AND deleted_at IS NULL" +
+		" GROUP BY " + column + ") " + alias
 }
 
-// TODO: Implement BuildDynamicQuery helper method
+// BuildDynamicQuery adds the WHERE conditions described by filters to baseQuery
 func (s *SearchService) BuildDynamicQuery(baseQuery squirrel.SelectBuilder, filters SearchFilters) squirrel.SelectBuilder {
-	// TODO: Demonstrate how to build queries step by step with Squirrel
-	//
-	// query := baseQuery
-	//
-	// if filters.Query != "" {
-	//     searchTerm := "%" + filters.Query + "%"
-	//     query = query.Where(squirrel.Or{
-	//         squirrel.ILike{"title": searchTerm},
-	//         squirrel.ILike{"content": searchTerm},
-	//     })
-	// }
-	//
-	// if filters.UserID != nil {
-	//     query = query.Where(squirrel.Eq{"user_id": *filters.UserID})
-	// }
-	//
-	// if filters.Published != nil {
-	//     query = query.Where(squirrel.Eq{"published": *filters.Published})
-	// }
-	//
-	// This modular approach makes dynamic queries much cleaner
-	// than string concatenation used in manual SQL approaches
-
-	return baseQuery
-}
-
-// TODO: Implement GetTopUsers method using Squirrel with complex aggregation
+	query := baseQuery
+
+	if !filters.IncludeDeleted {
+		query = query.Where("deleted_at IS NULL")
+	}
+
+	if filters.Query != "" {
+		searchTerm := "%" + database.EscapeLike(filters.Query) + "%"
+		query = query.Where(squirrel.Or{
+			s.dialect.ILike("title", searchTerm),
+			s.dialect.ILike("content", searchTerm),
+		})
+	}
+
+	if filters.UserID != nil {
+		query = query.Where(squirrel.Eq{"user_id": *filters.UserID})
+	}
+
+	if filters.Published != nil {
+		query = query.Where(squirrel.Eq{"published": *filters.Published})
+	}
+
+	if filters.Status != "" {
+		query = query.Where(squirrel.Eq{"status": filters.Status})
+	}
+
+	if len(filters.CategoryIDs) > 0 {
+		query = query.Where(categoryFilter(filters.CategoryIDs, filters.CategoryMatch))
+	}
+
+	if filters.MinWordCount != nil {
+		// Words are counted as spaces + 1, which both SQLite and PostgreSQL can compute
+		query = query.Where(
+			"(LENGTH(TRIM(content)) - LENGTH(REPLACE(TRIM(content), ' ', '')) + 1) >= ?",
+			*filters.MinWordCount,
+		)
+	}
+
+	if len(filters.Filters) > 0 {
+		// An invalid condition surfaces as an error from ToSql
+		query = query.Where(PostSchema.Where(s.dialect, filters.Filters...))
+	}
+
+	return query
+}
+
+// GetTopUsers returns the users with the most posts, with the number of
+// approved comments each has written. Deleted users, posts and comments are
+// not counted.
 func (s *SearchService) GetTopUsers(ctx context.Context, limit int) ([]UserWithStats, error) {
-	// TODO: Build complex aggregation query with Squirrel
-	// query := s.psql.Select(
-	//     "u.id",
-	//     "u.name",
-	//     "u.email",
-	//     "COUNT(p.id) as post_count",
-	//     "COUNT(CASE WHEN p.published = true THEN 1 END) as published_count",
-	//     "MAX(p.created_at) as last_post_date",
-	// ).From("users u").
-	//   LeftJoin("posts p ON u.id = p.user_id").
-	//   GroupBy("u.id", "u.name", "u.email").
-	//   OrderBy("post_count DESC").
-	//   Limit(uint64(limit))
-	//
-	// Notice how Squirrel makes complex queries more readable
-	// compared to building SQL strings manually
-
-	return nil, fmt.Errorf("TODO: implement GetTopUsers with Squirrel aggregation")
-}
-
-// UserWithStats represents a user with post statistics
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+
+	query := s.psql.Select(
+		"u.id",
+		"u.name",
+		"u.email",
+		"u.created_at",
+		"u.updated_at",
+		"u.deleted_at",
+		"COUNT(p.id) AS post_count",
+		"COUNT(CASE WHEN p.published THEN 1 END) AS published_count",
+		"MAX(p.created_at) AS last_post_date",
+		"COALESCE(MAX(c.comment_count), 0) AS comment_count",
+	).From("users u").
+		LeftJoin("posts p ON u.id = p.user_id AND p.deleted_at IS NULL").
+		LeftJoin(approvedCommentCounts("user_id", "c")+" ON c.user_id = u.id").
+		Where("u.deleted_at IS NULL").
+		GroupBy("u.id", "u.name", "u.email", "u.created_at", "u.updated_at", "u.deleted_at").
+		OrderBy("post_count DESC", "u.id").
+		Limit(uint64(limit))
+
+	sqlStr, args, err := query.ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build top users query: %w", err)
+	}
+
+	var rows []struct {
+		models.User
+		PostCount      int            `db:"post_count"`
+		PublishedCount int            `db:"published_count"`
+		LastPostDate   sql.NullString `db:"last_post_date"`
+		CommentCount   int            `db:"comment_count"`
+	}
+	if err := s.selectInto(ctx, "SearchService.GetTopUsers", &rows, sqlStr, args...); err != nil {
+		return nil, err
+	}
+
+	users := make([]UserWithStats, len(rows))
+	for i, row := range rows {
+		users[i] = UserWithStats{
+			User:           row.User,
+			PostCount:      row.PostCount,
+			PublishedCount: row.PublishedCount,
+			LastPostDate:   row.LastPostDate.String,
+			CommentCount:   row.CommentCount,
+		}
+	}
+	return users, nil
+}
+
+// selectInto runs a search query under the per-query timeout and scans all
+// rows into dest with scany
+func (s *SearchService) selectInto(ctx context.Context, op string, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := queryContext(ctx, s.db)
+	defer cancel()
+
+	return mapQueryError(ctx, op, sqlscan.Select(ctx, s.db, dest, query, args...))
+}
+
+// UserWithStats represents a user with post and comment statistics
 type UserWithStats struct {
 	models.User
 	PostCount      int    `db:"post_count"`
 	PublishedCount int    `db:"published_count"`
 	LastPostDate   string `db:"last_post_date"`
+	CommentCount   int    `db:"comment_count"`
 }
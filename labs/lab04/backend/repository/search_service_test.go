@@ -1,9 +1,14 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"strings"
 	"testing"
 
 	"lab04-backend/database"
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
 )
 
 // TestSearchService tests the Squirrel query builder approach
@@ -153,3 +158,103 @@ func BenchmarkSquirrelVsManualSQL(b *testing.B) {
 		b.Skip("TODO: implement manual SQL benchmark")
 	})
 }
+
+// TestSearchPostsRanked runs with FTS5 when built with -tags sqlite_fts5 and
+// exercises the LIKE fallback otherwise
+func TestSearchPostsRanked(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	userRepo := NewUserRepository(db.Conn)
+	posts := NewPostRepository(db.Conn)
+	search := NewSearchService(db.Conn)
+	ctx := context.Background()
+
+	user, err := userRepo.Create(&models.CreateUserRequest{Name: "Author", Email: "author@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	var created []*models.Post
+	for _, req := range []models.CreatePostRequest{
+		{Title: "Cooking pasta at home", Content: "Boil water, add salt and cook the pasta."},
+		{Title: "Learning Golang basics", Content: "Golang has goroutines and channels."},
+		{Title: "Weekend notes", Content: "Spent the weekend reading about golang generics."},
+	} {
+		req.UserID = user.ID
+		post, err := posts.Create(&req)
+		if err != nil {
+			t.Fatalf("Failed to create post: %v", err)
+		}
+		created = append(created, post)
+	}
+	titleMatch, contentMatch := created[1], created[2]
+
+	fts, err := search.FullTextAvailable(ctx)
+	if err != nil {
+		t.Fatalf("FullTextAvailable() failed: %v", err)
+	}
+	t.Logf("full-text search available: %v", fts)
+
+	results, err := search.SearchPostsRanked(ctx, SearchFilters{Query: "golang"})
+	if err != nil {
+		t.Fatalf("SearchPostsRanked() failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("SearchPostsRanked() returned %d results, want 2", len(results))
+	}
+	for _, r := range results {
+		if !strings.Contains(strings.ToLower(r.Snippet), "<mark>golang</mark>") {
+			t.Errorf("snippet %q does not highlight the term", r.Snippet)
+		}
+	}
+	if fts && results[0].ID != titleMatch.ID {
+		t.Errorf("first result = %q, want the post matching in title and content", results[0].Title)
+	}
+
+	// Updates and deletes reach the index through triggers
+	if _, err := posts.Update(titleMatch.ID, &models.UpdatePostRequest{Content: ptr("Nothing relevant here.")}); err != nil {
+		t.Fatalf("Update() failed: %v", err)
+	}
+	if err := posts.Delete(contentMatch.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	results, err = search.SearchPostsRanked(ctx, SearchFilters{Query: "golang"})
+	if err != nil {
+		t.Fatalf("SearchPostsRanked() failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != titleMatch.ID {
+		t.Errorf("SearchPostsRanked() after changes = %+v, want only the title match", results)
+	}
+
+	// Query syntax is matched literally
+	if _, err := search.SearchPostsRanked(ctx, SearchFilters{Query: `"pasta" AND (salt`}); err != nil {
+		t.Errorf("SearchPostsRanked() with operators failed: %v", err)
+	}
+
+	// Ranked results page with Offset; cursors are rejected
+	for _, filters := range []SearchFilters{{Query: "golang", After: "x"}, {Query: "golang", Before: "x"}} {
+		if _, err := search.SearchPostsRanked(ctx, filters); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("SearchPostsRanked(%+v) error = %v, want ErrInvalidCursor", filters, err)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text, q, want string
+	}{
+		{"Hello Go world", "go", "Hello <mark>Go</mark> world"},
+		{"golang", "go", "<mark>go</mark>lang"},
+		{"one two three four five six seven eight nine ten eleven twelve go", "go",
+			"…seven eight nine ten eleven twelve <mark>go</mark>"},
+		{"nothing", "go", ""},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.text, tt.q); got != tt.want {
+			t.Errorf("highlight(%q, %q) = %q, want %q", tt.text, tt.q, got, tt.want)
+		}
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,113 @@
+package repository_test
+
+import (
+	"context"
+	"testing"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/repository"
+	"lab04-backend/seed"
+)
+
+// TestRepositories_Seeded checks the repositories against generated demo
+// data. It is an external test: seed builds on this package.
+func TestRepositories_Seeded(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	ctx := context.Background()
+	profile := seed.Profiles["small"]
+	fixture := seed.Generate(7, profile)
+
+	result, err := seed.Load(ctx, repository.NewUnitOfWorkManager(db.SQL, db.Gorm), fixture)
+	if err != nil {
+		t.Fatalf("seed.Load() error = %v", err)
+	}
+	posts := repository.NewPostRepository(db.Conn)
+
+	// Paging visits every post exactly once
+	seen := make(map[int]bool)
+	page := repository.PageRequest{Limit: 4}
+	for {
+		got, err := posts.GetPageContext(ctx, page)
+		if err != nil {
+			t.Fatalf("GetPageContext() error = %v", err)
+		}
+		for _, post := range got.Items {
+			if seen[post.ID] {
+				t.Errorf("post %d returned twice", post.ID)
+			}
+			seen[post.ID] = true
+		}
+		if got.NextCursor == "" {
+			break
+		}
+		page.After = got.NextCursor
+	}
+	if len(seen) != len(fixture.Posts) {
+		t.Errorf("paged through %d posts, want %d", len(seen), len(fixture.Posts))
+	}
+
+	for email, user := range result.Users {
+		if count, err := posts.CountByUserIDContext(ctx, user.ID); err != nil || count != profile.PostsPerUser {
+			t.Errorf("CountByUserID(%s) = %d, %v; want %d", email, count, err, profile.PostsPerUser)
+		}
+	}
+
+	for name, category := range result.Categories {
+		want := 0
+		for _, post := range fixture.Posts {
+			for _, c := range post.Categories {
+				if c == name {
+					want++
+				}
+			}
+		}
+		got, err := posts.GetByCategoriesContext(ctx, []int{int(category.ID)}, repository.MatchAnyCategory)
+		if err != nil || len(got) != want {
+			t.Errorf("GetByCategories(%s) = %d posts, %v; want %d", name, len(got), err, want)
+		}
+	}
+}
+
+// TestSearchPosts_Isolated shares one database, loaded from
+// testdata/blog.yaml, between subtests, each running in a transaction that
+// is rolled back when it ends
+func TestSearchPosts_Isolated(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	fixture, err := seed.LoadFile("testdata/blog.yaml")
+	if err != nil {
+		t.Fatalf("seed.LoadFile() error = %v", err)
+	}
+	result, err := seed.Load(context.Background(), repository.NewUnitOfWorkManager(db.SQL, db.Gorm), fixture)
+	if err != nil {
+		t.Fatalf("seed.Load() error = %v", err)
+	}
+	published, draft := result.Posts[1], result.Posts[2]
+	onlyPublished := true
+
+	t.Run("Delete", func(t *testing.T) {
+		isolated := db.Isolate(t)
+		if err := repository.NewPostRepository(isolated.Conn).Delete(published.ID); err != nil {
+			t.Fatalf("Delete() failed: %v", err)
+		}
+		posts, err := repository.NewSearchService(isolated.Conn).SearchPosts(t.Context(), repository.SearchFilters{Query: "machine"})
+		if err != nil {
+			t.Fatalf("SearchPosts() failed: %v", err)
+		}
+		if len(posts) != 1 || posts[0].ID != draft.ID {
+			t.Errorf("SearchPosts() after Delete() = %v, want only post %d", posts, draft.ID)
+		}
+	})
+
+	t.Run("Unaffected", func(t *testing.T) {
+		isolated := db.Isolate(t)
+		posts, err := repository.NewSearchService(isolated.Conn).SearchPosts(t.Context(), repository.SearchFilters{Query: "machine", Published: &onlyPublished})
+		if err != nil {
+			t.Fatalf("SearchPosts() failed: %v", err)
+		}
+		if len(posts) != 1 || posts[0].ID != published.ID {
+			t.Errorf("SearchPosts() = %v, want post %d, whose deletion was rolled back", posts, published.ID)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"lab04-backend/database"
+
+	"gorm.io/gorm"
+)
+
+// UnitOfWork groups repositories that share one transaction, so that work
+// spanning users, posts, comments and categories commits or rolls back together
+type UnitOfWork struct {
+	Tx         *database.Tx
+	Users      *UserRepository
+	Posts      *PostRepository
+	Comments   *CommentRepository
+	Search     *SearchService
+	Categories *CategoryRepository // nil when the manager has no GORM handle
+}
+
+// Savepoint runs fn in a nested savepoint of the unit of work. An error or
+// panic in fn only undoes the work done inside it.
+func (u *UnitOfWork) Savepoint(ctx context.Context, fn func(uow *UnitOfWork) error) error {
+	return u.Tx.Savepoint(ctx, func(tx *database.Tx) error {
+		return fn(u)
+	})
+}
+
+// UnitOfWorkManager starts units of work
+type UnitOfWorkManager struct {
+	txManager *database.TxManager
+	gormDB    *gorm.DB
+}
+
+// NewUnitOfWorkManager creates a manager for db. gormDB must use the same
+// database; pass nil if categories are not needed.
+func NewUnitOfWorkManager(db *sql.DB, gormDB *gorm.DB) *UnitOfWorkManager {
+	return &UnitOfWorkManager{txManager: database.NewTxManager(db), gormDB: gormDB}
+}
+
+// Do runs fn in a transaction. It commits when fn returns nil and rolls
+// back when fn returns an error or panics.
+func (m *UnitOfWorkManager) Do(ctx context.Context, fn func(uow *UnitOfWork) error) error {
+	return m.txManager.WithTx(ctx, func(tx *database.Tx) error {
+		uow := &UnitOfWork{
+			Tx:       tx,
+			Users:    NewUserRepository(tx),
+			Posts:    NewPostRepository(tx),
+			Comments: NewCommentRepository(tx),
+			Search:   NewSearchService(tx),
+		}
+		if m.gormDB != nil {
+			// Bind a GORM session to the same transaction, the way gorm.DB.Begin does
+			session := m.gormDB.Session(&gorm.Session{Context: ctx, NewDB: true})
+			session.Statement.ConnPool = tx
+			uow.Categories = NewCategoryRepository(session)
+		}
+		return fn(uow)
+	})
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"lab04-backend/dbtest"
+	"lab04-backend/models"
+)
+
+func setupUnitOfWork(t *testing.T) (*UnitOfWorkManager, *dbtest.DB) {
+	db := dbtest.New(t)
+	return NewUnitOfWorkManager(db.SQL, db.Gorm), db
+}
+
+// createAuthorWithPost creates a user, their first post and a category in one unit of work
+func createAuthorWithPost(uow *UnitOfWork, email string) error {
+	user, err := uow.Users.Create(&models.CreateUserRequest{Name: "Author", Email: email})
+	if err != nil {
+		return err
+	}
+	_, err = uow.Posts.Create(&models.CreatePostRequest{UserID: user.ID, Title: "First post", Content: "Hello"})
+	if err != nil {
+		return err
+	}
+	return uow.Categories.Create((&models.CreateCategoryRequest{Name: "Intro " + email}).ToCategory())
+}
+
+func TestUnitOfWork_Commit(t *testing.T) {
+	t.Parallel()
+	manager, db := setupUnitOfWork(t)
+	userRepo := NewUserRepository(db.Conn)
+
+	err := manager.Do(context.Background(), func(uow *UnitOfWork) error {
+		return createAuthorWithPost(uow, "author@example.com")
+	})
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+
+	user, err := userRepo.GetByEmail("author@example.com")
+	if err != nil {
+		t.Fatalf("GetByEmail() failed: %v", err)
+	}
+	posts, err := NewPostRepository(db.Conn).GetByUserID(user.ID)
+	if err != nil || len(posts) != 1 {
+		t.Errorf("GetByUserID() = %v, %v; want the first post", posts, err)
+	}
+}
+
+func TestUnitOfWork_Rollback(t *testing.T) {
+	t.Parallel()
+	manager, db := setupUnitOfWork(t)
+	userRepo := NewUserRepository(db.Conn)
+	ctx := context.Background()
+
+	errAbort := errors.New("abort")
+	err := manager.Do(ctx, func(uow *UnitOfWork) error {
+		if err := createAuthorWithPost(uow, "author@example.com"); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Do() error = %v, want %v", err, errAbort)
+	}
+
+	func() {
+		defer func() { recover() }()
+		manager.Do(ctx, func(uow *UnitOfWork) error {
+			createAuthorWithPost(uow, "panic@example.com")
+			panic("boom")
+		})
+	}()
+
+	for _, counter := range []func() (int, error){
+		userRepo.Count,
+		NewPostRepository(db.Conn).Count,
+	} {
+		if count, err := counter(); err != nil || count != 0 {
+			t.Errorf("Count() = %d, %v; want 0 after rollback", count, err)
+		}
+	}
+	if count, err := NewCategoryRepository(manager.gormDB).Count(); err != nil || count != 0 {
+		t.Errorf("categories Count() = %d, %v; want 0 after rollback", count, err)
+	}
+}
+
+func TestUnitOfWork_Savepoint(t *testing.T) {
+	t.Parallel()
+	manager, db := setupUnitOfWork(t)
+	userRepo := NewUserRepository(db.Conn)
+	ctx := context.Background()
+
+	err := manager.Do(ctx, func(uow *UnitOfWork) error {
+		if err := createAuthorWithPost(uow, "kept@example.com"); err != nil {
+			return err
+		}
+		// A duplicate email fails inside the savepoint without aborting the outer work
+		err := uow.Savepoint(ctx, func(uow *UnitOfWork) error {
+			return createAuthorWithPost(uow, "kept@example.com")
+		})
+		if err == nil {
+			t.Error("Savepoint() should fail on a duplicate email")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Do() failed: %v", err)
+	}
+
+	if count, _ := userRepo.Count(); count != 1 {
+		t.Errorf("Count() = %d, want 1", count)
+	}
+}
@@ -1,82 +1,369 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
+	"lab04-backend/audit"
+	"lab04-backend/database"
 	"lab04-backend/models"
 )
 
 // UserRepository handles database operations for users
 // This repository demonstrates MANUAL SQL approach with database/sql package
 type UserRepository struct {
-	db *sql.DB
+	db      database.DBTX
+	dialect database.Dialect
 }
 
 // NewUserRepository creates a new UserRepository
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db database.DBTX) *UserRepository {
+	return &UserRepository{db: db, dialect: database.DialectOf(db)}
 }
 
-// TODO: Implement Create method
+const userColumns = "id, name, email, created_at, updated_at, deleted_at"
+
+// Create is CreateContext with context.Background()
 func (r *UserRepository) Create(req *models.CreateUserRequest) (*models.User, error) {
-	// TODO: Create a new user in the database
-	// - Validate the request
-	// - Insert into users table
-	// - Return the created user with ID and timestamps
-	// Use RETURNING clause to get the generated ID and timestamps
-	return nil, fmt.Errorf("TODO: implement Create method")
+	return r.CreateContext(context.Background(), req)
 }
 
-// TODO: Implement GetByID method
+// CreateContext inserts a new user and returns it with its ID and timestamps
+func (r *UserRepository) CreateContext(ctx context.Context, req *models.CreateUserRequest) (*models.User, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	user := req.ToUser()
+	query := r.dialect.Rebind(`
+		INSERT INTO users (name, email, created_at, updated_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING ` + userColumns)
+
+	var created models.User
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		row := tx.QueryRowContext(ctx, query, user.Name, user.Email, user.CreatedAt, user.UpdatedAt)
+		if err := created.ScanRow(row); err != nil {
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityUser, created.ID, audit.ActionCreate, nil, &created)
+	})
+	if err != nil {
+		return nil, mapQueryError(ctx, "UserRepository.Create", fmt.Errorf("failed to create user: %w", err))
+	}
+	return &created, nil
+}
+
+// GetByID is GetByIDContext with context.Background()
 func (r *UserRepository) GetByID(id int) (*models.User, error) {
-	// TODO: Get user by ID from database
-	// - Query users table by ID
-	// - Return user or sql.ErrNoRows if not found
-	// - Handle scanning properly
-	return nil, fmt.Errorf("TODO: implement GetByID method")
+	return r.GetByIDContext(context.Background(), id)
+}
+
+// GetByIDContext returns the user with the given ID or sql.ErrNoRows.
+// Soft deleted users are not found.
+func (r *UserRepository) GetByIDContext(ctx context.Context, id int) (*models.User, error) {
+	return r.getOne(ctx, "UserRepository.GetByID", `id = ?`, id)
 }
 
-// TODO: Implement GetByEmail method
+// GetByEmail is GetByEmailContext with context.Background()
 func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
-	// TODO: Get user by email from database
-	// - Query users table by email
-	// - Return user or sql.ErrNoRows if not found
-	// - Handle scanning properly
-	return nil, fmt.Errorf("TODO: implement GetByEmail method")
+	return r.GetByEmailContext(context.Background(), email)
+}
+
+// GetByEmailContext returns the user with the given email or sql.ErrNoRows
+func (r *UserRepository) GetByEmailContext(ctx context.Context, email string) (*models.User, error) {
+	return r.getOne(ctx, "UserRepository.GetByEmail", `email = ?`, email)
+}
+
+// getOne returns the live user matching condition
+func (r *UserRepository) getOne(ctx context.Context, op, condition string, args ...interface{}) (*models.User, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	query := r.dialect.Rebind(`SELECT ` + userColumns + ` FROM users WHERE ` + condition + ` AND deleted_at IS NULL`)
+
+	var user models.User
+	if err := user.ScanRow(r.db.QueryRowContext(ctx, query, args...)); err != nil {
+		return nil, mapQueryError(ctx, op, err)
+	}
+	return &user, nil
 }
 
-// TODO: Implement GetAll method
-func (r *UserRepository) GetAll() ([]models.User, error) {
-	// TODO: Get all users from database
-	// - Query all users ordered by created_at
-	// - Return slice of users
-	// - Handle empty result properly
-	return nil, fmt.Errorf("TODO: implement GetAll method")
+// GetAll is GetAllContext with context.Background()
+func (r *UserRepository) GetAll(opts ...ListOption) ([]models.User, error) {
+	return r.GetAllContext(context.Background(), opts...)
 }
 
-// TODO: Implement Update method
+// GetAllContext returns all users ordered by creation time
+func (r *UserRepository) GetAllContext(ctx context.Context, opts ...ListOption) ([]models.User, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	o := applyListOptions(opts)
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userColumns+` FROM users
+		WHERE `+o.notDeleted("deleted_at")+`
+		ORDER BY created_at, id`)
+	if err != nil {
+		return nil, mapQueryError(ctx, "UserRepository.GetAll", err)
+	}
+	users, err := models.ScanUsers(rows)
+	return users, mapQueryError(ctx, "UserRepository.GetAll", err)
+}
+
+// GetPage is GetPageContext with context.Background()
+func (r *UserRepository) GetPage(page PageRequest, opts ...ListOption) (*Page[models.User], error) {
+	return r.GetPageContext(context.Background(), page, opts...)
+}
+
+// GetPageContext returns one page of users ordered by creation time, using
+// keyset pagination on (created_at, id)
+func (r *UserRepository) GetPageContext(ctx context.Context, page PageRequest, opts ...ListOption) (*Page[models.User], error) {
+	k, err := page.keyset(false, "")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	where := applyListOptions(opts).notDeleted("deleted_at")
+	if k.where != "" {
+		where += " AND " + k.where
+	}
+	query := r.dialect.Rebind(`
+		SELECT ` + userColumns + ` FROM users
+		WHERE ` + where + `
+		ORDER BY ` + k.order + `
+		LIMIT ?`)
+
+	rows, err := r.db.QueryContext(ctx, query, append(k.args, k.limit+1)...)
+	if err != nil {
+		return nil, mapQueryError(ctx, "UserRepository.GetPage", err)
+	}
+	users, err := models.ScanUsers(rows)
+	if err != nil {
+		return nil, mapQueryError(ctx, "UserRepository.GetPage", err)
+	}
+	return newPage(users, k, userCursor), nil
+}
+
+func userCursor(u models.User) Cursor {
+	return Cursor{CreatedAt: u.CreatedAt, ID: u.ID}
+}
+
+// Update is UpdateContext with context.Background()
 func (r *UserRepository) Update(id int, req *models.UpdateUserRequest) (*models.User, error) {
-	// TODO: Update user in database
-	// - Build dynamic UPDATE query based on non-nil fields in req
-	// - Update updated_at timestamp
-	// - Return updated user
-	// - Handle case where user doesn't exist
-	return nil, fmt.Errorf("TODO: implement Update method")
+	return r.UpdateContext(context.Background(), id, req)
 }
 
-// TODO: Implement Delete method
+// UpdateContext changes the non-nil fields of req and returns the updated user
+func (r *UserRepository) UpdateContext(ctx context.Context, id int, req *models.UpdateUserRequest) (*models.User, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var sets []string
+	var args []interface{}
+
+	if req.Name != nil {
+		sets = append(sets, "name = ?")
+		args = append(args, *req.Name)
+	}
+	if req.Email != nil {
+		sets = append(sets, "email = ?")
+		args = append(args, *req.Email)
+	}
+	sets = append(sets, "updated_at = ?")
+	args = append(args, time.Now().UTC(), id)
+
+	query := r.dialect.Rebind(`
+		UPDATE users SET ` + strings.Join(sets, ", ") + `
+		WHERE id = ? AND deleted_at IS NULL
+		RETURNING ` + userColumns)
+
+	var user models.User
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		var before models.User
+		row := tx.QueryRowContext(ctx, r.dialect.Rebind(`
+			SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NULL`), id)
+		if err := before.ScanRow(row); err != nil {
+			return nil, err
+		}
+		if err := user.ScanRow(tx.QueryRowContext(ctx, query, args...)); err != nil {
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityUser, id, audit.ActionUpdate, &before, &user)
+	})
+	if err != nil {
+		return nil, mapQueryError(ctx, "UserRepository.Update", err)
+	}
+	return &user, nil
+}
+
+// Delete is DeleteContext with context.Background()
 func (r *UserRepository) Delete(id int) error {
-	// TODO: Delete user from database
-	// - Delete from users table by ID
-	// - Return error if user doesn't exist
-	// - Consider cascading deletes for posts
-	return fmt.Errorf("TODO: implement Delete method")
+	return r.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext soft deletes the user with the given ID or returns sql.ErrNoRows.
+// The row is kept until PurgeDeletedBefore removes it, so the email stays taken.
+func (r *UserRepository) DeleteContext(ctx context.Context, id int) error {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	now := time.Now().UTC()
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		var before, after models.User
+		row := tx.QueryRowContext(ctx, r.dialect.Rebind(`
+			SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NULL`), id)
+		if err := before.ScanRow(row); err != nil {
+			return nil, err
+		}
+		row = tx.QueryRowContext(ctx, r.dialect.Rebind(`
+			UPDATE users SET deleted_at = ?, updated_at = ?
+			WHERE id = ? AND deleted_at IS NULL
+			RETURNING `+userColumns), now, now, id)
+		if err := after.ScanRow(row); err != nil {
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityUser, id, audit.ActionDelete, &before, &after)
+	})
+	return mapQueryError(ctx, "UserRepository.Delete", err)
+}
+
+// Restore is RestoreContext with context.Background()
+func (r *UserRepository) Restore(id int) (*models.User, error) {
+	return r.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext undoes a soft delete and returns the user, or sql.ErrNoRows
+// if there is no deleted user with the given ID
+func (r *UserRepository) RestoreContext(ctx context.Context, id int) (*models.User, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	query := r.dialect.Rebind(`
+		UPDATE users SET deleted_at = NULL, updated_at = ?
+		WHERE id = ? AND deleted_at IS NOT NULL
+		RETURNING ` + userColumns)
+
+	var user models.User
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		var before models.User
+		row := tx.QueryRowContext(ctx, r.dialect.Rebind(`
+			SELECT `+userColumns+` FROM users WHERE id = ? AND deleted_at IS NOT NULL`), id)
+		if err := before.ScanRow(row); err != nil {
+			return nil, err
+		}
+		if err := user.ScanRow(tx.QueryRowContext(ctx, query, time.Now().UTC(), id)); err != nil {
+			return nil, err
+		}
+		return auditEntry(ctx, audit.EntityUser, id, audit.ActionRestore, &before, &user)
+	})
+	if err != nil {
+		return nil, mapQueryError(ctx, "UserRepository.Restore", err)
+	}
+	return &user, nil
+}
+
+// PurgeDeletedBefore is PurgeDeletedBeforeContext with context.Background()
+func (r *UserRepository) PurgeDeletedBefore(before time.Time) (int64, error) {
+	return r.PurgeDeletedBeforeContext(context.Background(), before)
 }
 
-// TODO: Implement Count method
+// PurgeDeletedBeforeContext permanently removes users soft deleted before the
+// given time, together with their posts and comments, and returns the number
+// of users removed. Posts and comments removed with a user are recorded in
+// the audit log as purged too.
+func (r *UserRepository) PurgeDeletedBeforeContext(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	const doomed = `SELECT id FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ?`
+	cutoff := before.UTC()
+	var purged int64
+	err := withAudit(ctx, r.db, func(tx database.DBTX) ([]audit.Entry, error) {
+		rows, err := tx.QueryContext(ctx, r.dialect.Rebind(`
+			SELECT `+userColumns+` FROM users WHERE id IN (`+doomed+`)`), cutoff)
+		if err != nil {
+			return nil, err
+		}
+		users, err := models.ScanUsers(rows)
+		if err != nil {
+			return nil, err
+		}
+		rows, err = tx.QueryContext(ctx, r.dialect.Rebind(`
+			SELECT `+postColumns+` FROM posts WHERE user_id IN (`+doomed+`)`), cutoff)
+		if err != nil {
+			return nil, err
+		}
+		posts, err := models.ScanPosts(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments, err := cascadedComments(ctx, tx, r.dialect,
+			`user_id IN (`+doomed+`) OR post_id IN (SELECT id FROM posts WHERE user_id IN (`+doomed+`))`, cutoff, cutoff)
+		if err != nil {
+			return nil, err
+		}
+
+		ids, err := returningIDs(ctx, tx, r.dialect.Rebind(`
+			DELETE FROM users WHERE deleted_at IS NOT NULL AND deleted_at < ?
+			RETURNING id`), cutoff)
+		if err != nil {
+			return nil, err
+		}
+		purged = int64(len(ids))
+
+		entries, err := purgeEntries(ctx, audit.EntityUser, users, userID, ids)
+		if err != nil {
+			return nil, err
+		}
+		postEntries, err := cascadeEntries(ctx, audit.EntityPost, posts, postID)
+		if err != nil {
+			return nil, err
+		}
+		commentEntries, err := cascadeEntries(ctx, audit.EntityComment, comments, commentID)
+		if err != nil {
+			return nil, err
+		}
+		return append(append(entries, postEntries...), commentEntries...), nil
+	})
+	if err != nil {
+		return 0, mapQueryError(ctx, "UserRepository.PurgeDeletedBefore", err)
+	}
+	return purged, nil
+}
+
+// Count is CountContext with context.Background()
 func (r *UserRepository) Count() (int, error) {
-	// TODO: Count total number of users
-	// - Return count of users in database
-	return 0, fmt.Errorf("TODO: implement Count method")
+	return r.CountContext(context.Background())
+}
+
+// CountContext returns the number of users that are not deleted
+func (r *UserRepository) CountContext(ctx context.Context) (int, error) {
+	ctx, cancel := queryContext(ctx, r.db)
+	defer cancel()
+
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users WHERE deleted_at IS NULL`).Scan(&count)
+	return count, mapQueryError(ctx, "UserRepository.Count", err)
+}
+
+// expectAffected returns sql.ErrNoRows when a statement changed no rows
+func expectAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
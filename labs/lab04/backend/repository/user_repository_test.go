@@ -1,48 +1,20 @@
 package repository
 
 import (
-	"os"
+	"context"
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
-	"lab04-backend/database"
+	"lab04-backend/dbtest"
 	"lab04-backend/models"
 )
 
-func setupTestDB(t *testing.T) (*UserRepository, func()) {
-	// Create test database
-	testDB := "./test_user_repo.db"
-	config := &database.Config{
-		DatabasePath:    testDB,
-		MaxOpenConns:    5,
-		MaxIdleConns:    1,
-		ConnMaxLifetime: 0,
-		ConnMaxIdleTime: 0,
-	}
-
-	db, err := database.InitDBWithConfig(config)
-	if err != nil {
-		t.Fatalf("Failed to initialize test database: %v", err)
-	}
-
-	err = database.RunMigrations(db)
-	if err != nil {
-		t.Fatalf("Failed to migrate test database: %v", err)
-	}
-
-	repo := NewUserRepository(db)
-
-	// Return cleanup function
-	cleanup := func() {
-		database.CloseDB(db)
-		os.Remove(testDB)
-	}
-
-	return repo, cleanup
-}
-
 func TestUserRepository_Create(t *testing.T) {
-	repo, cleanup := setupTestDB(t)
-	defer cleanup()
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
 
 	req := &models.CreateUserRequest{
 		Name:  "John Doe",
@@ -80,8 +52,9 @@ func TestUserRepository_Create(t *testing.T) {
 }
 
 func TestUserRepository_GetByID(t *testing.T) {
-	repo, cleanup := setupTestDB(t)
-	defer cleanup()
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -124,8 +97,9 @@ func TestUserRepository_GetByID(t *testing.T) {
 }
 
 func TestUserRepository_GetByEmail(t *testing.T) {
-	repo, cleanup := setupTestDB(t)
-	defer cleanup()
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -160,8 +134,9 @@ func TestUserRepository_GetByEmail(t *testing.T) {
 }
 
 func TestUserRepository_GetAll(t *testing.T) {
-	repo, cleanup := setupTestDB(t)
-	defer cleanup()
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
 
 	// Test empty database
 	users, err := repo.GetAll()
@@ -199,8 +174,9 @@ func TestUserRepository_GetAll(t *testing.T) {
 }
 
 func TestUserRepository_Update(t *testing.T) {
-	repo, cleanup := setupTestDB(t)
-	defer cleanup()
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -250,8 +226,9 @@ func TestUserRepository_Update(t *testing.T) {
 }
 
 func TestUserRepository_Delete(t *testing.T) {
-	repo, cleanup := setupTestDB(t)
-	defer cleanup()
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
 
 	// Create a user first
 	req := &models.CreateUserRequest{
@@ -283,9 +260,114 @@ func TestUserRepository_Delete(t *testing.T) {
 	}
 }
 
+func TestUserRepository_SoftDelete(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
+
+	kept, err := repo.Create(&models.CreateUserRequest{Name: "Kept", Email: "kept@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+	deleted, err := repo.Create(&models.CreateUserRequest{Name: "Deleted", Email: "deleted@example.com"})
+	if err != nil {
+		t.Fatalf("Failed to create user: %v", err)
+	}
+
+	if err := repo.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := repo.Delete(deleted.ID); err != sql.ErrNoRows {
+		t.Errorf("Delete() twice error = %v, want sql.ErrNoRows", err)
+	}
+
+	users, err := repo.GetAll()
+	if err != nil {
+		t.Fatalf("GetAll() failed: %v", err)
+	}
+	if len(users) != 1 || users[0].ID != kept.ID {
+		t.Errorf("GetAll() = %v, want only the kept user", users)
+	}
+
+	users, err = repo.GetAll(IncludeDeleted())
+	if err != nil {
+		t.Fatalf("GetAll(IncludeDeleted()) failed: %v", err)
+	}
+	if len(users) != 2 || !users[1].IsDeleted() {
+		t.Errorf("GetAll(IncludeDeleted()) = %v, want both users with the second deleted", users)
+	}
+
+	if _, err := repo.Restore(kept.ID); err != sql.ErrNoRows {
+		t.Errorf("Restore() of a live user error = %v, want sql.ErrNoRows", err)
+	}
+	restored, err := repo.Restore(deleted.ID)
+	if err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if restored.IsDeleted() {
+		t.Error("Restore() returned a user that is still deleted")
+	}
+
+	if err := repo.Delete(deleted.ID); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	purged, err := repo.PurgeDeletedBefore(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeletedBefore() failed: %v", err)
+	}
+	if purged != 0 {
+		t.Errorf("PurgeDeletedBefore(an hour ago) = %d, want 0", purged)
+	}
+	purged, err = repo.PurgeDeletedBefore(time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("PurgeDeletedBefore() failed: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeDeletedBefore(now) = %d, want 1", purged)
+	}
+	if _, err := repo.Restore(deleted.ID); err != sql.ErrNoRows {
+		t.Errorf("Restore() of a purged user error = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestUserRepository_QueryTimeout(t *testing.T) {
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
+
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	_, err := repo.CountContext(ctx)
+	if !IsQueryTimeout(err) {
+		t.Fatalf("CountContext() error = %v, want a *QueryTimeoutError", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("errors.Is(%v, context.DeadlineExceeded) = false", err)
+	}
+
+	// The driver's error stays reachable
+	wrapped := &QueryTimeoutError{Op: "UserRepository.Count", Err: sql.ErrConnDone}
+	if !errors.Is(wrapped, context.DeadlineExceeded) || !errors.Is(wrapped, sql.ErrConnDone) {
+		t.Errorf("QueryTimeoutError should unwrap to both context.DeadlineExceeded and its cause")
+	}
+
+	if _, err := repo.GetByIDContext(ctx, 1); !IsQueryTimeout(err) {
+		t.Errorf("GetByIDContext() error = %v, want a *QueryTimeoutError", err)
+	}
+
+	// Cancellation by the caller is not a timeout
+	canceled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, err := repo.GetAllContext(canceled); err == nil || IsQueryTimeout(err) {
+		t.Errorf("GetAllContext() with a canceled context error = %v, want context.Canceled", err)
+	}
+}
+
 func TestUserRepository_Count(t *testing.T) {
-	repo, cleanup := setupTestDB(t)
-	defer cleanup()
+	t.Parallel()
+	db := dbtest.New(t)
+	repo := NewUserRepository(db.Conn)
 
 	// Test count with empty database
 	count, err := repo.Count()
@@ -0,0 +1,153 @@
+package seed
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encoding of a fixture file
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// FormatOf returns the format of a fixture file from its extension
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("unknown fixture format %q, want .json, .yaml or .yml", filepath.Ext(path))
+	}
+}
+
+// Decode reads a fixture and checks its references
+func Decode(r io.Reader, format Format) (*Fixture, error) {
+	var f Fixture
+	var err error
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&f)
+	case FormatYAML:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		err = decoder.Decode(&f)
+	default:
+		return nil, fmt.Errorf("unknown fixture format %q", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode fixture: %w", err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Encode writes the fixture
+func (f *Fixture) Encode(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(f)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(f); err != nil {
+			return err
+		}
+		return encoder.Close()
+	default:
+		return fmt.Errorf("unknown fixture format %q", format)
+	}
+}
+
+// LoadFile reads a fixture from a .json, .yaml or .yml file
+func LoadFile(path string) (*Fixture, error) {
+	format, err := FormatOf(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Decode(file, format)
+}
+
+// WriteFile writes the fixture to a .json, .yaml or .yml file
+func (f *Fixture) WriteFile(path string) error {
+	format, err := FormatOf(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := f.Encode(file, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Validate checks that emails and category names are unique and that posts
+// and comments only refer to users and categories of the fixture
+func (f *Fixture) Validate() error {
+	users := make(map[string]bool, len(f.Users))
+	for _, user := range f.Users {
+		if users[user.Email] {
+			return fmt.Errorf("duplicate user %q", user.Email)
+		}
+		users[user.Email] = true
+	}
+	categories := make(map[string]bool, len(f.Categories))
+	for _, category := range f.Categories {
+		if categories[category.Name] {
+			return fmt.Errorf("duplicate category %q", category.Name)
+		}
+		categories[category.Name] = true
+	}
+
+	var checkComments func(title string, comments []CommentFixture) error
+	checkComments = func(title string, comments []CommentFixture) error {
+		for _, comment := range comments {
+			if !users[comment.Author] {
+				return fmt.Errorf("comment on %q: unknown author %q", title, comment.Author)
+			}
+			if err := checkComments(title, comment.Replies); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, post := range f.Posts {
+		if !users[post.Author] {
+			return fmt.Errorf("post %q: unknown author %q", post.Title, post.Author)
+		}
+		for _, name := range post.Categories {
+			if !categories[name] {
+				return fmt.Errorf("post %q: unknown category %q", post.Title, name)
+			}
+		}
+		if err := checkComments(post.Title, post.Comments); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"lab04-backend/models"
+	"lab04-backend/repository"
+)
+
+// Result holds the rows created by Load
+type Result struct {
+	Users      map[string]*models.User     // By email
+	Categories map[string]*models.Category // By name
+	Posts      []*models.Post              // In fixture order
+	Comments   int
+}
+
+// Load inserts the fixture in a single unit of work, so either all of it
+// is created or nothing is. The manager needs a GORM handle when the
+// fixture has categories.
+func Load(ctx context.Context, manager *repository.UnitOfWorkManager, f *Fixture) (*Result, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	var result *Result
+	err := manager.Do(ctx, func(uow *repository.UnitOfWork) error {
+		result = &Result{
+			Users:      make(map[string]*models.User, len(f.Users)),
+			Categories: make(map[string]*models.Category, len(f.Categories)),
+		}
+		return load(ctx, uow, f, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func load(ctx context.Context, uow *repository.UnitOfWork, f *Fixture, result *Result) error {
+	for _, u := range f.Users {
+		user, err := uow.Users.CreateContext(ctx, &models.CreateUserRequest{Name: u.Name, Email: u.Email})
+		if err != nil {
+			return fmt.Errorf("user %q: %w", u.Email, err)
+		}
+		result.Users[u.Email] = user
+	}
+
+	if len(f.Categories) > 0 && uow.Categories == nil {
+		return errors.New("seeding categories needs a unit of work manager with a GORM handle")
+	}
+	for _, c := range f.Categories {
+		category := (&models.CreateCategoryRequest{Name: c.Name, Description: c.Description, Color: c.Color}).ToCategory()
+		if err := uow.Categories.CreateContext(ctx, category); err != nil {
+			return fmt.Errorf("category %q: %w", c.Name, err)
+		}
+		result.Categories[c.Name] = category
+	}
+
+	for _, p := range f.Posts {
+		post, err := uow.Posts.CreateContext(ctx, &models.CreatePostRequest{
+			UserID:    result.Users[p.Author].ID,
+			Title:     p.Title,
+			Content:   p.Content,
+			Published: p.Published,
+		})
+		if err != nil {
+			return fmt.Errorf("post %q: %w", p.Title, err)
+		}
+		result.Posts = append(result.Posts, post)
+
+		if len(p.Categories) > 0 {
+			ids := make([]int, len(p.Categories))
+			for i, name := range p.Categories {
+				ids[i] = int(result.Categories[name].ID)
+			}
+			if err := uow.Posts.SetCategoriesContext(ctx, post.ID, ids); err != nil {
+				return fmt.Errorf("categories of post %q: %w", p.Title, err)
+			}
+		}
+
+		if err := loadComments(ctx, uow, result, post.ID, nil, p.Comments); err != nil {
+			return fmt.Errorf("comments of post %q: %w", p.Title, err)
+		}
+	}
+	return nil
+}
+
+// loadComments creates comments and, depth first, their replies
+func loadComments(ctx context.Context, uow *repository.UnitOfWork, result *Result, postID int, parentID *int, comments []CommentFixture) error {
+	for _, c := range comments {
+		comment, err := uow.Comments.CreateContext(ctx, &models.CreateCommentRequest{
+			PostID:   postID,
+			UserID:   result.Users[c.Author].ID,
+			ParentID: parentID,
+			Content:  c.Content,
+		})
+		if err != nil {
+			return err
+		}
+		result.Comments++
+		if err := loadComments(ctx, uow, result, postID, &comment.ID, c.Replies); err != nil {
+			return err
+		}
+	}
+	return nil
+}
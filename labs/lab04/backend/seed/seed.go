@@ -0,0 +1,196 @@
+// Package seed fills a lab04 database with demo data. Data comes from a
+// Fixture, which is either generated deterministically from a seed and a
+// size Profile or read from a YAML or JSON file.
+package seed
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+)
+
+// Profile sets how much data Generate produces
+type Profile struct {
+	Name                 string
+	Users                int
+	PostsPerUser         int
+	Categories           int
+	MaxCategoriesPerPost int
+	CommentsPerPost      int
+}
+
+// Profiles are the predefined sizes, by name
+var Profiles = map[string]Profile{
+	"small":  {Name: "small", Users: 5, PostsPerUser: 3, Categories: 4, MaxCategoriesPerPost: 2, CommentsPerPost: 2},
+	"medium": {Name: "medium", Users: 50, PostsPerUser: 10, Categories: 12, MaxCategoriesPerPost: 3, CommentsPerPost: 5},
+	"large":  {Name: "large", Users: 500, PostsPerUser: 20, Categories: 30, MaxCategoriesPerPost: 4, CommentsPerPost: 10},
+}
+
+// ProfileByName returns a predefined profile
+func ProfileByName(name string) (Profile, error) {
+	profile, ok := Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(Profiles))
+		for n := range Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Profile{}, fmt.Errorf("unknown profile %q, want one of %s", name, strings.Join(names, ", "))
+	}
+	return profile, nil
+}
+
+// Fixture is a set of demo data. Posts refer to users by email and to
+// categories by name, so fixture files can be written by hand.
+type Fixture struct {
+	Users      []UserFixture     `json:"users" yaml:"users"`
+	Categories []CategoryFixture `json:"categories,omitempty" yaml:"categories,omitempty"`
+	Posts      []PostFixture     `json:"posts,omitempty" yaml:"posts,omitempty"`
+}
+
+// UserFixture describes a user
+type UserFixture struct {
+	Name  string `json:"name" yaml:"name"`
+	Email string `json:"email" yaml:"email"`
+}
+
+// CategoryFixture describes a category
+type CategoryFixture struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Color       string `json:"color,omitempty" yaml:"color,omitempty"`
+}
+
+// PostFixture describes a post with its categories and comments
+type PostFixture struct {
+	Author     string           `json:"author" yaml:"author"` // Email of a user in the fixture
+	Title      string           `json:"title" yaml:"title"`
+	Content    string           `json:"content" yaml:"content"`
+	Published  bool             `json:"published" yaml:"published"`
+	Categories []string         `json:"categories,omitempty" yaml:"categories,omitempty"` // Category names
+	Comments   []CommentFixture `json:"comments,omitempty" yaml:"comments,omitempty"`
+}
+
+// CommentFixture describes a comment and its replies
+type CommentFixture struct {
+	Author  string           `json:"author" yaml:"author"` // Email of a user in the fixture
+	Content string           `json:"content" yaml:"content"`
+	Replies []CommentFixture `json:"replies,omitempty" yaml:"replies,omitempty"`
+}
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "Dmitry", "Elena", "Farid", "Grace", "Hiro", "Irina", "Jamal", "Kira", "Leo"}
+	lastNames  = []string{"Ivanova", "Smith", "Garcia", "Petrov", "Kim", "Novak", "Okafor", "Rossi", "Tanaka", "Weber"}
+	topics     = []string{"Go", "Flutter", "Databases", "Testing", "Concurrency", "Security", "DevOps", "Design", "Networking", "Performance"}
+	titles     = []string{"Getting started with %s", "%s in practice", "Ten tips for %s", "What I learned about %s", "A deep dive into %s"}
+	words      = strings.Fields(`lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod
+		tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud
+		exercitation ullamco laboris nisi aliquip ex ea commodo consequat`)
+)
+
+// Generate returns a fixture of the given size. The same seed and profile
+// always produce the same fixture.
+func Generate(seed uint64, profile Profile) *Fixture {
+	g := &generator{rng: rand.New(rand.NewPCG(seed, seed))}
+	f := &Fixture{}
+
+	for i := 0; i < profile.Users; i++ {
+		first, last := pick(g.rng, firstNames), pick(g.rng, lastNames)
+		f.Users = append(f.Users, UserFixture{
+			Name:  first + " " + last,
+			Email: fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), i+1),
+		})
+	}
+
+	for i := 0; i < profile.Categories; i++ {
+		name := topics[i%len(topics)]
+		if i >= len(topics) {
+			name = fmt.Sprintf("%s %d", name, i/len(topics)+1)
+		}
+		f.Categories = append(f.Categories, CategoryFixture{
+			Name:        name,
+			Description: "Posts about " + name,
+			Color:       fmt.Sprintf("#%06x", g.rng.IntN(0x1000000)),
+		})
+	}
+
+	for _, user := range f.Users {
+		for i := 0; i < profile.PostsPerUser; i++ {
+			f.Posts = append(f.Posts, g.post(f, user.Email, profile))
+		}
+	}
+	return f
+}
+
+type generator struct {
+	rng *rand.Rand
+}
+
+func (g *generator) post(f *Fixture, author string, profile Profile) PostFixture {
+	post := PostFixture{
+		Author:    author,
+		Title:     fmt.Sprintf(pick(g.rng, titles), pick(g.rng, topics)),
+		Content:   g.paragraph(3 + g.rng.IntN(5)),
+		Published: g.rng.IntN(10) < 7,
+	}
+
+	if len(f.Categories) > 0 && profile.MaxCategoriesPerPost > 0 {
+		n := 1 + g.rng.IntN(min(profile.MaxCategoriesPerPost, len(f.Categories)))
+		for _, i := range g.rng.Perm(len(f.Categories))[:n] {
+			post.Categories = append(post.Categories, f.Categories[i].Name)
+		}
+	}
+
+	// Build a flat list where some comments answer an earlier one, then nest it
+	type flatComment struct {
+		comment CommentFixture
+		parent  int // Index of the parent, -1 for a top-level comment
+	}
+	var flat []flatComment
+	for i := 0; i < profile.CommentsPerPost; i++ {
+		parent := -1
+		if i > 0 && g.rng.IntN(10) < 3 {
+			parent = g.rng.IntN(i)
+		}
+		flat = append(flat, flatComment{
+			comment: CommentFixture{Author: pick(g.rng, f.Users).Email, Content: g.sentence()},
+			parent:  parent,
+		})
+	}
+	var nest func(parent int) []CommentFixture
+	nest = func(parent int) []CommentFixture {
+		var comments []CommentFixture
+		for i, c := range flat {
+			if c.parent == parent {
+				c.comment.Replies = nest(i)
+				comments = append(comments, c.comment)
+			}
+		}
+		return comments
+	}
+	post.Comments = nest(-1)
+	return post
+}
+
+// sentence returns a capitalised sentence of 5 to 12 words
+func (g *generator) sentence() string {
+	n := 5 + g.rng.IntN(8)
+	s := make([]string, n)
+	for i := range s {
+		s[i] = pick(g.rng, words)
+	}
+	return strings.ToUpper(s[0][:1]) + strings.Join(s, " ")[1:] + "."
+}
+
+func (g *generator) paragraph(sentences int) string {
+	s := make([]string, sentences)
+	for i := range s {
+		s[i] = g.sentence()
+	}
+	return strings.Join(s, " ")
+}
+
+func pick[T any](rng *rand.Rand, items []T) T {
+	return items[rng.IntN(len(items))]
+}
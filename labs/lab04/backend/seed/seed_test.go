@@ -0,0 +1,126 @@
+package seed
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/repository"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGenerate(t *testing.T) {
+	profile := Profiles["small"]
+	a, b := Generate(42, profile), Generate(42, profile)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("Generate() with the same seed should return the same fixture")
+	}
+	if reflect.DeepEqual(a, Generate(43, profile)) {
+		t.Error("Generate() with another seed should return another fixture")
+	}
+
+	if len(a.Users) != profile.Users || len(a.Categories) != profile.Categories {
+		t.Errorf("Generate() made %d users and %d categories, want %d and %d",
+			len(a.Users), len(a.Categories), profile.Users, profile.Categories)
+	}
+	if want := profile.Users * profile.PostsPerUser; len(a.Posts) != want {
+		t.Errorf("Generate() made %d posts, want %d", len(a.Posts), want)
+	}
+	if err := a.Validate(); err != nil {
+		t.Errorf("Generate() made an invalid fixture: %v", err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	fixture := Generate(7, Profiles["small"])
+	for _, format := range []Format{FormatJSON, FormatYAML} {
+		var buf bytes.Buffer
+		if err := fixture.Encode(&buf, format); err != nil {
+			t.Fatalf("Encode(%s) error = %v", format, err)
+		}
+		decoded, err := Decode(&buf, format)
+		if err != nil {
+			t.Fatalf("Decode(%s) error = %v", format, err)
+		}
+		if !reflect.DeepEqual(fixture, decoded) {
+			t.Errorf("Decode(%s) did not return the encoded fixture", format)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"unknown author": `
+users:
+  - {name: Alice, email: alice@example.com}
+posts:
+  - {author: bob@example.com, title: Hello world, content: Hi}
+`,
+		"unknown category": `
+users:
+  - {name: Alice, email: alice@example.com}
+posts:
+  - {author: alice@example.com, title: Hello world, content: Hi, categories: [Go]}
+`,
+		"unknown field": `
+users:
+  - {name: Alice, email: alice@example.com, age: 30}
+`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Decode(strings.NewReader(input), FormatYAML); err == nil {
+				t.Error("Decode() should fail")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	db, err := database.InitDBWithConfig(&database.Config{DatabasePath: filepath.Join(t.TempDir(), "seed.db"), MaxOpenConns: 5})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	defer database.CloseDB(db)
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	gormDB, err := gorm.Open(sqlite.New(sqlite.Config{Conn: db}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open GORM: %v", err)
+	}
+	manager := repository.NewUnitOfWorkManager(db, gormDB)
+
+	fixture := Generate(1, Profiles["small"])
+	result, err := Load(context.Background(), manager, fixture)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(result.Posts) != len(fixture.Posts) {
+		t.Errorf("Load() created %d posts, want %d", len(result.Posts), len(fixture.Posts))
+	}
+
+	post := result.Posts[0]
+	categories, err := repository.NewPostRepository(db).GetCategories(post.ID)
+	if err != nil || len(categories) != len(fixture.Posts[0].Categories) {
+		t.Errorf("GetCategories() = %v, %v; want the fixture categories", categories, err)
+	}
+	if count, _ := repository.NewUserRepository(db).Count(); count != len(fixture.Users) {
+		t.Errorf("Count() = %d, want %d users", count, len(fixture.Users))
+	}
+
+	// Loading the same fixture again fails on duplicate emails and leaves
+	// nothing behind
+	if _, err := Load(context.Background(), manager, fixture); err == nil {
+		t.Fatal("Load() of duplicate users should fail")
+	}
+	if count, _ := repository.NewUserRepository(db).Count(); count != len(fixture.Users) {
+		t.Errorf("Count() after a failed Load() = %d, want %d", count, len(fixture.Users))
+	}
+}
@@ -0,0 +1,163 @@
+package transfer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxNDJSONLine is the longest NDJSON line a reader accepts
+const maxNDJSONLine = 16 << 20
+
+// rowReader decodes one row at a time. A row that cannot be decoded is
+// returned as a *RowError and the reader moves on to the next one; any other
+// error, including io.EOF, ends the stream.
+type rowReader interface {
+	next(v record) (line int, err error)
+}
+
+func newReader(r io.Reader, format Format, v record) (rowReader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r, v.columns())
+	case FormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64<<10), maxNDJSONLine)
+		return &ndjsonReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvReader struct {
+	r      *csv.Reader
+	header []string
+}
+
+// newCSVReader reads the header row and checks it only names known columns.
+// Missing columns read as empty values.
+func newCSVReader(r io.Reader, columns []string) (*csvReader, error) {
+	cr := csv.NewReader(r)
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing CSV header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	known := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		known[column] = true
+	}
+	header = append([]string(nil), header...)
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(column))
+		if !known[header[i]] {
+			return nil, fmt.Errorf("unknown CSV column %q, want %s", column, strings.Join(columns, ", "))
+		}
+	}
+	return &csvReader{r: cr, header: header}, nil
+}
+
+func (c *csvReader) next(v record) (int, error) {
+	values, err := c.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return parseErr.StartLine, &RowError{Line: parseErr.StartLine, Err: parseErr.Err}
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	row := make(map[string]string, len(values))
+	for i, value := range values {
+		row[c.header[i]] = value
+	}
+	if err := v.fromCSV(row); err != nil {
+		return line, &RowError{Line: line, Key: v.key(), Err: err}
+	}
+	return line, nil
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (n *ndjsonReader) next(v record) (int, error) {
+	for n.scanner.Scan() {
+		n.line++
+		data := bytes.TrimSpace(n.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v); err != nil {
+			return n.line, &RowError{Line: n.line, Err: err}
+		}
+		if decoder.More() {
+			return n.line, &RowError{Line: n.line, Err: errors.New("more than one JSON value on the line")}
+		}
+		return n.line, nil
+	}
+	if err := n.scanner.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read line %d: %w", n.line+1, err)
+	}
+	return 0, io.EOF
+}
+
+// rowWriter encodes one row at a time
+type rowWriter interface {
+	write(v record) error
+	// flush writes buffered rows and reports any write error
+	flush() error
+}
+
+// newWriter returns a writer for format. CSV files start with the header of
+// v, even when no rows follow.
+func newWriter(w io.Writer, format Format, v record) (rowWriter, error) {
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(v.columns()); err != nil {
+			return nil, err
+		}
+		return &csvWriter{w: cw}, nil
+	case FormatNDJSON:
+		bw := bufio.NewWriter(w)
+		encoder := json.NewEncoder(bw)
+		encoder.SetEscapeHTML(false)
+		return &ndjsonWriter{w: bw, encoder: encoder}, nil
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) write(v record) error { return c.w.Write(v.toCSV()) }
+
+func (c *csvWriter) flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type ndjsonWriter struct {
+	w       *bufio.Writer
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) write(v record) error { return n.encoder.Encode(v) }
+func (n *ndjsonWriter) flush() error         { return n.w.Flush() }
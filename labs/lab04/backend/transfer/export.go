@@ -0,0 +1,138 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"lab04-backend/database"
+)
+
+// aggregateSeparator joins category names in the export query; unlike
+// CategorySeparator it cannot appear in a name typed by a user
+const aggregateSeparator = "\x1f"
+
+// Exporter writes live rows, i.e. rows that are not soft deleted, one at a
+// time as they are read from the database. Exports are long running, so
+// they are bounded by the context only and not by the per-query timeout.
+type Exporter struct {
+	db      database.DBTX
+	dialect database.Dialect
+}
+
+// NewExporter creates an exporter reading from db
+func NewExporter(db database.DBTX) *Exporter {
+	return &Exporter{db: db, dialect: database.DialectOf(db)}
+}
+
+// Export writes all live rows of entity to w and returns their number
+func (e *Exporter) Export(ctx context.Context, entity Entity, w io.Writer, format Format) (int, error) {
+	switch entity {
+	case EntityUsers:
+		return e.ExportUsers(ctx, w, format)
+	case EntityPosts:
+		return e.ExportPosts(ctx, w, format)
+	case EntityCategories:
+		return e.ExportCategories(ctx, w, format)
+	default:
+		return 0, fmt.Errorf("unknown entity %q", entity)
+	}
+}
+
+// ExportUsers writes the live users ordered by ID
+func (e *Exporter) ExportUsers(ctx context.Context, w io.Writer, format Format) (int, error) {
+	query := `SELECT name, email FROM users WHERE deleted_at IS NULL ORDER BY id`
+	return exportRows(ctx, e, w, format, query, func(rows *sql.Rows, rec *UserRecord) error {
+		return rows.Scan(&rec.Name, &rec.Email)
+	})
+}
+
+// ExportCategories writes the live categories with the name of their parent,
+// parents before their children and siblings in their sort order. The first
+// sibling has sort order 0, which an import reads as "after the last
+// sibling", so it is written first. The parents of live categories are
+// live, since categories with children cannot be deleted.
+func (e *Exporter) ExportCategories(ctx context.Context, w io.Writer, format Format) (int, error) {
+	query := `
+		SELECT c.name, COALESCE(c.description, ''), COALESCE(c.color, ''), c.active,
+			COALESCE(p.name, ''), c.sort_order
+		FROM categories c
+		LEFT JOIN categories p ON p.id = c.parent_id
+		WHERE c.deleted_at IS NULL
+		ORDER BY c.depth, c.sort_order, c.name`
+	return exportRows(ctx, e, w, format, query, func(rows *sql.Rows, rec *CategoryRecord) error {
+		var active bool
+		if err := rows.Scan(&rec.Name, &rec.Description, &rec.Color, &active, &rec.Parent, &rec.SortOrder); err != nil {
+			return err
+		}
+		rec.Active = &active
+		return nil
+	})
+}
+
+// ExportPosts writes the live posts of live users ordered by ID, with the
+// names of their live categories
+func (e *Exporter) ExportPosts(ctx context.Context, w io.Writer, format Format) (int, error) {
+	query := `
+		SELECT u.email, p.title, COALESCE(p.content, ''), p.published, COALESCE(pc.names, ''),
+			COALESCE(p.slug, ''), p.status, p.publish_at
+		FROM posts p
+		JOIN users u ON u.id = p.user_id AND u.deleted_at IS NULL
+		LEFT JOIN (
+			SELECT pc.post_id, ` + e.dialect.StringAgg("c.name", aggregateSeparator) + ` AS names
+			FROM post_categories pc
+			JOIN categories c ON c.id = pc.category_id AND c.deleted_at IS NULL
+			GROUP BY pc.post_id
+		) pc ON pc.post_id = p.id
+		WHERE p.deleted_at IS NULL
+		ORDER BY p.id`
+	return exportRows(ctx, e, w, format, query, func(rows *sql.Rows, rec *PostRecord) error {
+		var names string
+		err := rows.Scan(&rec.Author, &rec.Title, &rec.Content, &rec.Published, &names,
+			&rec.Slug, &rec.Status, &rec.PublishAt)
+		if err != nil {
+			return err
+		}
+		if names != "" {
+			rec.Categories = strings.Split(names, aggregateSeparator)
+			sort.Strings(rec.Categories)
+		}
+		return nil
+	})
+}
+
+// exportRows runs query and writes each row scanned by scan
+func exportRows[R any, T interface {
+	*R
+	record
+}](ctx context.Context, e *Exporter, w io.Writer, format Format, query string, scan func(*sql.Rows, T) error) (int, error) {
+	out, err := newWriter(w, format, T(new(R)))
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := e.db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to export: %w", err)
+	}
+	defer rows.Close()
+
+	n := 0
+	for rows.Next() {
+		rec := T(new(R))
+		if err := scan(rows, rec); err != nil {
+			return n, fmt.Errorf("failed to export: %w", err)
+		}
+		if err := out.write(rec); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return n, fmt.Errorf("failed to export: %w", err)
+	}
+	return n, out.flush()
+}
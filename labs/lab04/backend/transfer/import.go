@@ -0,0 +1,370 @@
+package transfer
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"lab04-backend/models"
+	"lab04-backend/repository"
+
+	"gorm.io/gorm"
+)
+
+// DefaultBatchSize is the number of rows an import inserts per transaction
+const DefaultBatchSize = 500
+
+// RowError reports why a row of an import file was skipped
+type RowError struct {
+	Line int    // line of the row in the file
+	Key  string // email, title or name of the row, when it could be decoded
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("line %d (%s): %v", e.Line, e.Key, e.Err)
+	}
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Report summarises an import
+type Report struct {
+	Entity   Entity
+	Rows     int // rows read from the file
+	Imported int
+	Errors   []*RowError // one per skipped row, in file order
+}
+
+// Failed returns the number of skipped rows
+func (r *Report) Failed() int {
+	return len(r.Errors)
+}
+
+// Importer inserts rows in batches. Each batch is one transaction and each
+// row a savepoint within it, so a row that fails, e.g. on a duplicate email,
+// is reported and skipped without undoing the rest of its batch.
+type Importer struct {
+	manager   *repository.UnitOfWorkManager
+	batchSize int
+}
+
+// NewImporter creates an importer committing every batchSize rows, or
+// DefaultBatchSize when batchSize <= 0. Importing categories, or posts with
+// categories, needs a manager with a GORM handle.
+func NewImporter(manager *repository.UnitOfWorkManager, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Importer{manager: manager, batchSize: batchSize}
+}
+
+// Import reads entity rows from r
+func (im *Importer) Import(ctx context.Context, entity Entity, r io.Reader, format Format) (*Report, error) {
+	switch entity {
+	case EntityUsers:
+		return im.ImportUsers(ctx, r, format)
+	case EntityPosts:
+		return im.ImportPosts(ctx, r, format)
+	case EntityCategories:
+		return im.ImportCategories(ctx, r, format)
+	default:
+		return nil, fmt.Errorf("unknown entity %q", entity)
+	}
+}
+
+// ImportUsers creates a user for each row, validated with
+// CreateUserRequest.Validate
+func (im *Importer) ImportUsers(ctx context.Context, r io.Reader, format Format) (*Report, error) {
+	validate := func(rec *UserRecord) error {
+		return rec.request().Validate()
+	}
+	insert := func(ctx context.Context, uow *repository.UnitOfWork, rec *UserRecord) error {
+		_, err := uow.Users.CreateContext(ctx, rec.request())
+		return err
+	}
+	rows, err := newReader(r, format, &UserRecord{})
+	if err != nil {
+		return &Report{Entity: EntityUsers}, err
+	}
+	return importRows(ctx, im, EntityUsers, rows, validate, insert)
+}
+
+// ImportCategories creates a category for each row, validated with
+// CreateCategoryRequest.Validate. Parents must exist or be in the file; the
+// file is read in full first so that parents are imported before their
+// children whatever the order of the rows.
+func (im *Importer) ImportCategories(ctx context.Context, r io.Reader, format Format) (*Report, error) {
+	validate := func(rec *CategoryRecord) error {
+		if rec.Parent == rec.Name {
+			return errors.New("a category cannot be its own parent")
+		}
+		return rec.request().Validate()
+	}
+	insert := func(ctx context.Context, uow *repository.UnitOfWork, rec *CategoryRecord) error {
+		if uow.Categories == nil {
+			return errors.New("importing categories needs a unit of work manager with a GORM handle")
+		}
+		category := rec.request().ToCategory()
+		category.SortOrder = rec.SortOrder
+		if rec.Parent != "" {
+			parent, err := uow.Categories.FindByNameContext(ctx, rec.Parent)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("unknown parent category %q", rec.Parent)
+			}
+			if err != nil {
+				return err
+			}
+			category.ParentID = &parent.ID
+		}
+		if err := uow.Categories.CreateContext(ctx, category); err != nil {
+			return err
+		}
+		if !rec.active() {
+			// GORM inserts the column default for a false Active, so save it again
+			category.Active = false
+			return uow.Categories.UpdateContext(ctx, category)
+		}
+		return nil
+	}
+	rows, err := newReader(r, format, &CategoryRecord{})
+	if err != nil {
+		return &Report{Entity: EntityCategories}, err
+	}
+	if rows, err = parentsFirst(rows); err != nil {
+		return &Report{Entity: EntityCategories}, err
+	}
+	return importRows(ctx, im, EntityCategories, rows, validate, insert)
+}
+
+// bufferedRow is a row read ahead by parentsFirst
+type bufferedRow struct {
+	line int
+	rec  CategoryRecord
+	err  error
+}
+
+// bufferedReader replays rows read ahead
+type bufferedReader struct {
+	rows []bufferedRow
+}
+
+func (b *bufferedReader) next(v record) (int, error) {
+	if len(b.rows) == 0 {
+		return 0, io.EOF
+	}
+	row := b.rows[0]
+	b.rows = b.rows[1:]
+	*v.(*CategoryRecord) = row.rec
+	return row.line, row.err
+}
+
+// parentsFirst reads all category rows and replays them ordered by their
+// depth in the file, so that a category comes after its parent. Rows whose
+// parent is not in the file come first; rows in a parent cycle are left
+// at depth 0 and fail with an unknown parent.
+func parentsFirst(rows rowReader) (rowReader, error) {
+	var buffered []bufferedRow
+	parents := map[string]string{}
+	for {
+		var rec CategoryRecord
+		line, err := rows.next(&rec)
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return nil, err
+		}
+		buffered = append(buffered, bufferedRow{line: line, rec: rec, err: err})
+		if err == nil && rec.Name != "" {
+			parents[rec.Name] = rec.Parent
+		}
+	}
+
+	// depth counts the ancestors of a category that are in the file
+	depth := func(name string) int {
+		d := 0
+		for parent := parents[name]; ; d++ {
+			grandparent, ok := parents[parent]
+			if !ok {
+				return d
+			}
+			if d == len(parents) {
+				return 0
+			}
+			parent = grandparent
+		}
+	}
+	depths := make(map[string]int, len(parents))
+	for name := range parents {
+		depths[name] = depth(name)
+	}
+	sort.SliceStable(buffered, func(i, j int) bool {
+		return depths[buffered[i].rec.Name] < depths[buffered[j].rec.Name]
+	})
+	return &bufferedReader{rows: buffered}, nil
+}
+
+// ImportPosts creates a post for each row, validated with
+// CreatePostRequest.Validate once its author is known. Authors and
+// categories must already exist.
+func (im *Importer) ImportPosts(ctx context.Context, r io.Reader, format Format) (*Report, error) {
+	authors := map[string]int{}
+	categories := map[string]int{}
+
+	validate := func(rec *PostRecord) error {
+		if rec.Author == "" {
+			return errors.New("author is required")
+		}
+		return nil
+	}
+	insert := func(ctx context.Context, uow *repository.UnitOfWork, rec *PostRecord) error {
+		userID, ok := authors[rec.Author]
+		if !ok {
+			user, err := uow.Users.GetByEmailContext(ctx, rec.Author)
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("unknown author %q", rec.Author)
+			}
+			if err != nil {
+				return err
+			}
+			userID = user.ID
+			authors[rec.Author] = userID
+		}
+
+		categoryIDs := make([]int, 0, len(rec.Categories))
+		for _, name := range rec.Categories {
+			id, ok := categories[name]
+			if !ok {
+				if uow.Categories == nil {
+					return errors.New("importing post categories needs a unit of work manager with a GORM handle")
+				}
+				category, err := uow.Categories.FindByNameContext(ctx, name)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("unknown category %q", name)
+				}
+				if err != nil {
+					return err
+				}
+				id = int(category.ID)
+				categories[name] = id
+			}
+			categoryIDs = append(categoryIDs, id)
+		}
+
+		req := &models.CreatePostRequest{
+			UserID:    userID,
+			Title:     rec.Title,
+			Content:   rec.Content,
+			Published: rec.Published,
+			Slug:      rec.Slug,
+			Status:    rec.Status,
+			PublishAt: rec.PublishAt,
+		}
+		if err := req.Validate(); err != nil {
+			return err
+		}
+		post, err := uow.Posts.CreateContext(ctx, req)
+		if err != nil || len(categoryIDs) == 0 {
+			return err
+		}
+		return uow.Posts.SetCategoriesContext(ctx, post.ID, categoryIDs)
+	}
+	rows, err := newReader(r, format, &PostRecord{})
+	if err != nil {
+		return &Report{Entity: EntityPosts}, err
+	}
+	return importRows(ctx, im, EntityPosts, rows, validate, insert)
+}
+
+type pendingRow[T any] struct {
+	line int
+	rec  T
+}
+
+// importRows streams rows, validates each one and inserts the valid rows in
+// batches. It returns the report so far with any error that stopped the
+// import; rows of committed batches stay imported.
+func importRows[R any, T interface {
+	*R
+	record
+}](ctx context.Context, im *Importer, entity Entity, rows rowReader,
+	validate func(T) error, insert func(context.Context, *repository.UnitOfWork, T) error) (*Report, error) {
+
+	report := &Report{Entity: entity}
+	defer func() {
+		// Decode and validation errors are reported before those of their batch
+		sort.SliceStable(report.Errors, func(i, j int) bool {
+			return report.Errors[i].Line < report.Errors[j].Line
+		})
+	}()
+
+	batch := make([]pendingRow[T], 0, im.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		var imported int
+		var failed []*RowError
+		err := im.manager.Do(ctx, func(uow *repository.UnitOfWork) error {
+			for _, row := range batch {
+				err := uow.Savepoint(ctx, func(uow *repository.UnitOfWork) error {
+					return insert(ctx, uow, row.rec)
+				})
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if err != nil {
+					failed = append(failed, &RowError{Line: row.line, Key: row.rec.key(), Err: err})
+					continue
+				}
+				imported++
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("batch starting at line %d: %w", batch[0].line, err)
+		}
+		report.Imported += imported
+		report.Errors = append(report.Errors, failed...)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		rec := T(new(R))
+		line, err := rows.next(rec)
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			report.Errors = append(report.Errors, rowErr)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+
+		report.Rows++
+		if err := validate(rec); err != nil {
+			report.Errors = append(report.Errors, &RowError{Line: line, Key: rec.key(), Err: err})
+			continue
+		}
+		if batch = append(batch, pendingRow[T]{line: line, rec: rec}); len(batch) == im.batchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, flush()
+}
@@ -0,0 +1,199 @@
+// Package transfer imports and exports users, posts and categories as CSV or
+// NDJSON (one JSON object per line), for moving blog content between
+// environments. Rows are streamed, so large tables are never held in memory.
+//
+// Records refer to each other by natural keys rather than IDs: posts name
+// their author by email and their categories by name, and categories name
+// their parent.
+package transfer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"lab04-backend/models"
+)
+
+// Format is the encoding of an import or export file
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+)
+
+// FormatOf returns the format of a file from its extension
+func FormatOf(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("unknown format %q, want .csv, .ndjson or .jsonl", filepath.Ext(path))
+	}
+}
+
+// Entity names the table being imported or exported
+type Entity string
+
+const (
+	EntityUsers      Entity = "users"
+	EntityPosts      Entity = "posts"
+	EntityCategories Entity = "categories"
+)
+
+// ParseEntity converts a table name into an Entity
+func ParseEntity(name string) (Entity, error) {
+	switch entity := Entity(strings.ToLower(name)); entity {
+	case EntityUsers, EntityPosts, EntityCategories:
+		return entity, nil
+	default:
+		return "", fmt.Errorf("unknown entity %q, want users, posts or categories", name)
+	}
+}
+
+// record is one row of an import or export file
+type record interface {
+	// columns returns the CSV header
+	columns() []string
+	// key identifies the row in error reports
+	key() string
+	fromCSV(row map[string]string) error
+	toCSV() []string
+}
+
+// UserRecord is a user in an import or export file
+type UserRecord struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (r *UserRecord) columns() []string { return []string{"name", "email"} }
+func (r *UserRecord) key() string       { return r.Email }
+
+func (r *UserRecord) fromCSV(row map[string]string) error {
+	r.Name, r.Email = row["name"], row["email"]
+	return nil
+}
+
+func (r *UserRecord) toCSV() []string { return []string{r.Name, r.Email} }
+
+func (r *UserRecord) request() *models.CreateUserRequest {
+	return &models.CreateUserRequest{Name: r.Name, Email: r.Email}
+}
+
+// PostRecord is a post in an import or export file. In CSV, categories are
+// separated by CategorySeparator and the publish time is RFC 3339. An empty
+// status follows Published and an empty slug is generated from the title.
+type PostRecord struct {
+	Author     string            `json:"author"`
+	Title      string            `json:"title"`
+	Content    string            `json:"content"`
+	Published  bool              `json:"published"`
+	Categories []string          `json:"categories,omitempty"`
+	Slug       string            `json:"slug,omitempty"`
+	Status     models.PostStatus `json:"status,omitempty"`
+	PublishAt  *time.Time        `json:"publish_at,omitempty"`
+}
+
+// CategorySeparator separates the category names of a post in CSV
+const CategorySeparator = "|"
+
+func (r *PostRecord) columns() []string {
+	return []string{"author", "title", "content", "published", "categories", "slug", "status", "publish_at"}
+}
+
+func (r *PostRecord) key() string { return r.Title }
+
+func (r *PostRecord) fromCSV(row map[string]string) error {
+	r.Author, r.Title, r.Content = row["author"], row["title"], row["content"]
+	published, err := parseBool(row["published"], false)
+	if err != nil {
+		return fmt.Errorf("published: %w", err)
+	}
+	r.Published = published
+	r.Slug, r.Status = row["slug"], models.PostStatus(row["status"])
+	r.PublishAt = nil
+	if value := strings.TrimSpace(row["publish_at"]); value != "" {
+		at, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return fmt.Errorf("publish_at: %w", err)
+		}
+		r.PublishAt = &at
+	}
+	r.Categories = nil
+	for _, name := range strings.Split(row["categories"], CategorySeparator) {
+		if name = strings.TrimSpace(name); name != "" {
+			r.Categories = append(r.Categories, name)
+		}
+	}
+	return nil
+}
+
+func (r *PostRecord) toCSV() []string {
+	var publishAt string
+	if r.PublishAt != nil {
+		publishAt = r.PublishAt.UTC().Format(time.RFC3339Nano)
+	}
+	return []string{r.Author, r.Title, r.Content, strconv.FormatBool(r.Published),
+		strings.Join(r.Categories, CategorySeparator), r.Slug, string(r.Status), publishAt}
+}
+
+// CategoryRecord is a category in an import or export file. Active defaults
+// to true when it is left out. Parent is the name of the parent category,
+// empty for a root; a zero SortOrder places the category after its last
+// sibling.
+type CategoryRecord struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Color       string `json:"color,omitempty"`
+	Active      *bool  `json:"active,omitempty"`
+	Parent      string `json:"parent,omitempty"`
+	SortOrder   int    `json:"sort_order,omitempty"`
+}
+
+func (r *CategoryRecord) columns() []string {
+	return []string{"name", "description", "color", "active", "parent", "sort_order"}
+}
+
+func (r *CategoryRecord) key() string { return r.Name }
+
+func (r *CategoryRecord) fromCSV(row map[string]string) error {
+	r.Name, r.Description, r.Color = row["name"], row["description"], row["color"]
+	active, err := parseBool(row["active"], true)
+	if err != nil {
+		return fmt.Errorf("active: %w", err)
+	}
+	r.Active = &active
+	r.Parent, r.SortOrder = strings.TrimSpace(row["parent"]), 0
+	if value := strings.TrimSpace(row["sort_order"]); value != "" {
+		if r.SortOrder, err = strconv.Atoi(value); err != nil {
+			return fmt.Errorf("sort_order: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *CategoryRecord) toCSV() []string {
+	return []string{r.Name, r.Description, r.Color, strconv.FormatBool(r.active()), r.Parent, strconv.Itoa(r.SortOrder)}
+}
+
+func (r *CategoryRecord) active() bool {
+	return r.Active == nil || *r.Active
+}
+
+func (r *CategoryRecord) request() *models.CreateCategoryRequest {
+	return &models.CreateCategoryRequest{Name: r.Name, Description: r.Description, Color: r.Color}
+}
+
+// parseBool parses a CSV boolean, returning def for an empty value
+func parseBool(value string, def bool) (bool, error) {
+	if value = strings.TrimSpace(value); value == "" {
+		return def, nil
+	}
+	return strconv.ParseBool(value)
+}
@@ -0,0 +1,215 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"lab04-backend/database"
+	"lab04-backend/repository"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) (*sql.DB, *repository.UnitOfWorkManager) {
+	t.Helper()
+	db, err := database.InitDBWithConfig(&database.Config{DatabasePath: filepath.Join(t.TempDir(), "transfer.db"), MaxOpenConns: 5})
+	if err != nil {
+		t.Fatalf("InitDBWithConfig() error = %v", err)
+	}
+	t.Cleanup(func() { database.CloseDB(db) })
+	if err := database.RunMigrations(db); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+	gormDB, err := gorm.Open(sqlite.New(sqlite.Config{Conn: db}), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to open GORM: %v", err)
+	}
+	return db, repository.NewUnitOfWorkManager(db, gormDB)
+}
+
+func TestImport(t *testing.T) {
+	db, manager := setupTestDB(t)
+	importer := NewImporter(manager, 2)
+	ctx := context.Background()
+
+	users := `name,email
+Alice,alice@example.com
+Bob,not-an-email
+Broken
+Carol,carol@example.com
+Alice Again,alice@example.com
+`
+	report, err := importer.ImportUsers(ctx, strings.NewReader(users), FormatCSV)
+	if err != nil {
+		t.Fatalf("ImportUsers() error = %v", err)
+	}
+	if report.Rows != 5 || report.Imported != 2 || report.Failed() != 3 {
+		t.Fatalf("ImportUsers() read %d, imported %d and failed %v; want 5, 2 and 3", report.Rows, report.Imported, report.Errors)
+	}
+	for i, want := range []string{"line 3 (not-an-email)", "line 4: wrong number of fields", "line 6 (alice@example.com)"} {
+		if !strings.Contains(report.Errors[i].Error(), want) {
+			t.Errorf("error %d = %v, want %q", i, report.Errors[i], want)
+		}
+	}
+
+	categories := `{"name": "Go", "color": "#00add8"}
+{"name": "Archive", "active": false}
+
+{"name": "X"}
+{"name": "Extra", "unknown": 1}
+`
+	report, err = importer.ImportCategories(ctx, strings.NewReader(categories), FormatNDJSON)
+	if err != nil {
+		t.Fatalf("ImportCategories() error = %v", err)
+	}
+	if report.Imported != 2 || report.Failed() != 2 || report.Errors[0].Line != 4 || report.Errors[1].Line != 5 {
+		t.Fatalf("ImportCategories() = %+v, want 2 imported and errors on lines 4 and 5", report)
+	}
+
+	posts := `{"author": "alice@example.com", "title": "Hello world", "content": "Hi", "published": true, "categories": ["Go", "Archive"], "slug": "hello", "publish_at": "2025-07-16T09:00:00Z"}
+{"author": "nobody@example.com", "title": "Orphan post"}
+{"author": "alice@example.com", "title": "Tagged wrong", "categories": ["Rust"]}
+{"author": "alice@example.com", "title": "Empty", "published": true}
+`
+	report, err = importer.ImportPosts(ctx, strings.NewReader(posts), FormatNDJSON)
+	if err != nil {
+		t.Fatalf("ImportPosts() error = %v", err)
+	}
+	if report.Imported != 1 || report.Failed() != 3 {
+		t.Fatalf("ImportPosts() imported %d and failed %v, want 1 and 3", report.Imported, report.Errors)
+	}
+	for i, want := range []string{"unknown author", "unknown category", "published posts must have content"} {
+		if !strings.Contains(report.Errors[i].Error(), want) {
+			t.Errorf("error %d = %v, want %q", i, report.Errors[i], want)
+		}
+	}
+
+	t.Run("Export", func(t *testing.T) {
+		exporter := NewExporter(db)
+
+		var buf bytes.Buffer
+		n, err := exporter.ExportPosts(ctx, &buf, FormatCSV)
+		if err != nil || n != 1 {
+			t.Fatalf("ExportPosts() = %d, %v; want 1 post", n, err)
+		}
+		want := "author,title,content,published,categories,slug,status,publish_at\n" +
+			"alice@example.com,Hello world,Hi,true,Archive|Go,hello,published,2025-07-16T09:00:00Z\n"
+		if buf.String() != want {
+			t.Errorf("ExportPosts() wrote %q, want %q", buf.String(), want)
+		}
+
+		buf.Reset()
+		if _, err := exporter.ExportCategories(ctx, &buf, FormatNDJSON); err != nil {
+			t.Fatalf("ExportCategories() error = %v", err)
+		}
+		if !strings.Contains(buf.String(), `{"name":"Archive","color":"#007bff","active":false,"sort_order":1}`) {
+			t.Errorf("ExportCategories() wrote %q, want Archive inactive", buf.String())
+		}
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	source, sourceManager := setupTestDB(t)
+	target, targetManager := setupTestDB(t)
+	ctx := context.Background()
+
+	seed := map[Entity]string{
+		EntityUsers: "name,email\nAlice,alice@example.com\n\"Bob, Jr.\",bob@example.com\n",
+		EntityCategories: "name,description,color,active,parent,sort_order\n" +
+			"Generics,,,true,Go,2\nGo,\"The Go language, and tools\",#00add8,true,,\nTesting,,,false,Go,1\n",
+		EntityPosts: "author,title,content,published,categories\nbob@example.com,Multi-line post,\"line one\nline two\",true,Go\n",
+	}
+	order := []Entity{EntityUsers, EntityCategories, EntityPosts}
+	for _, entity := range order {
+		if _, err := NewImporter(sourceManager, 0).Import(ctx, entity, strings.NewReader(seed[entity]), FormatCSV); err != nil {
+			t.Fatalf("Import(%s) error = %v", entity, err)
+		}
+	}
+
+	for _, format := range []Format{FormatCSV, FormatNDJSON} {
+		for _, entity := range order {
+			var exported bytes.Buffer
+			if _, err := NewExporter(source).Export(ctx, entity, &exported, format); err != nil {
+				t.Fatalf("Export(%s, %s) error = %v", entity, format, err)
+			}
+			data := exported.String()
+
+			if format == FormatCSV {
+				report, err := NewImporter(targetManager, 0).Import(ctx, entity, &exported, format)
+				if err != nil || report.Failed() > 0 {
+					t.Fatalf("Import(%s, %s) = %+v, %v", entity, format, report, err)
+				}
+			}
+
+			var reexported bytes.Buffer
+			if _, err := NewExporter(target).Export(ctx, entity, &reexported, format); err != nil {
+				t.Fatalf("Export(%s, %s) from the target error = %v", entity, format, err)
+			}
+			if reexported.String() != data {
+				t.Errorf("%s %s differ after the round trip:\n%s\nwant:\n%s", entity, format, reexported.String(), data)
+			}
+		}
+	}
+}
+
+func TestImportCategories_Hierarchy(t *testing.T) {
+	db, manager := setupTestDB(t)
+	ctx := context.Background()
+
+	// Children come before their parents, and one parent is missing
+	categories := `{"name": "Channels", "parent": "Concurrency"}
+{"name": "Concurrency", "parent": "Go", "sort_order": 5}
+{"name": "Orphan", "parent": "Missing"}
+{"name": "Go"}
+{"name": "Loop", "parent": "Loop"}
+`
+	report, err := NewImporter(manager, 0).ImportCategories(ctx, strings.NewReader(categories), FormatNDJSON)
+	if err != nil {
+		t.Fatalf("ImportCategories() error = %v", err)
+	}
+	if report.Imported != 3 || report.Failed() != 2 {
+		t.Fatalf("ImportCategories() imported %d and failed %v, want 3 and 2", report.Imported, report.Errors)
+	}
+	for i, want := range []string{"line 3 (Orphan): unknown parent category", "line 5 (Loop): a category cannot be its own parent"} {
+		if !strings.Contains(report.Errors[i].Error(), want) {
+			t.Errorf("error %d = %v, want %q", i, report.Errors[i], want)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := NewExporter(db).ExportCategories(ctx, &buf, FormatCSV); err != nil {
+		t.Fatalf("ExportCategories() error = %v", err)
+	}
+	want := "name,description,color,active,parent,sort_order\n" +
+		"Go,,#007bff,true,,0\n" +
+		"Concurrency,,#007bff,true,Go,5\n" +
+		"Channels,,#007bff,true,Concurrency,0\n"
+	if buf.String() != want {
+		t.Errorf("ExportCategories() wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]Format{"users.csv": FormatCSV, "posts.NDJSON": FormatNDJSON, "a.jsonl": FormatNDJSON}
+	for path, want := range tests {
+		if got, err := FormatOf(path); err != nil || got != want {
+			t.Errorf("FormatOf(%q) = %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if _, err := FormatOf("users.xml"); err == nil {
+		t.Error("FormatOf(users.xml) should fail")
+	}
+}
+
+func TestImport_BadHeader(t *testing.T) {
+	_, manager := setupTestDB(t)
+	_, err := NewImporter(manager, 0).ImportUsers(context.Background(), strings.NewReader("name,mail\nAlice,a@example.com\n"), FormatCSV)
+	if err == nil || !strings.Contains(err.Error(), `"mail"`) {
+		t.Errorf("ImportUsers() with an unknown column error = %v", err)
+	}
+}